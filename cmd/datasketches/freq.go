@@ -0,0 +1,168 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/apache/datasketches-go/frequencies"
+)
+
+// newFreqFlags returns the flags of a freq command with its --type flag.
+func newFreqFlags(name string) (*flag.FlagSet, *string) {
+	fs := newFlagSet(name)
+	return fs, fs.String("type", "string", "type of the items: string or long")
+}
+
+// parseFreqFlags parses the flags of a freq command and checks its --type flag.
+func parseFreqFlags(fs *flag.FlagSet, itemType *string, args []string) error {
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *itemType != "string" && *itemType != "long" {
+		return fmt.Errorf("invalid --type %q, must be string or long", *itemType)
+	}
+	return nil
+}
+
+func freqBuild(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, itemType := newFreqFlags("freq build")
+	lgMaxMap := fs.Int("lg-max-map-size", 10, "log2 of the maximum size of the hash map of the sketch")
+	if err := parseFreqFlags(fs, itemType, args); err != nil {
+		return err
+	}
+	if *itemType == "long" {
+		sketch, err := frequencies.NewLongsSketchWithMaxMapSize(1 << *lgMaxMap)
+		if err != nil {
+			return err
+		}
+		err = forEachLine(stdin, fs.Args(), func(line string) error {
+			item, err := strconv.ParseInt(line, 10, 64)
+			if err != nil {
+				return err
+			}
+			return sketch.Update(item)
+		})
+		if err != nil {
+			return err
+		}
+		return writeImage(stdout, sketch.ToSlice())
+	}
+	sketch, err := frequencies.NewItemsSketchWithMaxMapSize[string](1<<*lgMaxMap, frequencies.StringItemsSketchOp{})
+	if err != nil {
+		return err
+	}
+	if err := forEachLine(stdin, fs.Args(), sketch.Update); err != nil {
+		return err
+	}
+	return writeImage(stdout, sketch.ToSlice())
+}
+
+func freqMerge(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, itemType := newFreqFlags("freq merge")
+	if err := parseFreqFlags(fs, itemType, args); err != nil {
+		return err
+	}
+	images, err := readImages(fs.Args())
+	if err != nil {
+		return err
+	}
+	if *itemType == "long" {
+		var result *frequencies.LongsSketch
+		for i, image := range images {
+			sketch, err := frequencies.NewLongsSketchFromSlice(image)
+			if err != nil {
+				return fmt.Errorf("%s: %w", fs.Arg(i), err)
+			}
+			if result == nil {
+				result = sketch
+			} else if result, err = result.Merge(sketch); err != nil {
+				return err
+			}
+		}
+		return writeImage(stdout, result.ToSlice())
+	}
+	var result *frequencies.ItemsSketch[string]
+	for i, image := range images {
+		sketch, err := frequencies.NewItemsSketchFromSlice[string](image, frequencies.StringItemsSketchOp{})
+		if err != nil {
+			return fmt.Errorf("%s: %w", fs.Arg(i), err)
+		}
+		if result == nil {
+			result = sketch
+		} else if result, err = result.Merge(sketch); err != nil {
+			return err
+		}
+	}
+	return writeImage(stdout, result.ToSlice())
+}
+
+func freqTop(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, itemType := newFreqFlags("freq top")
+	n := fs.Int("n", 20, "maximum number of items to print, 0 for all")
+	noFalseNegatives := fs.Bool("no-false-negatives", false, "include every item whose upper bound exceeds the threshold")
+	if err := parseFreqFlags(fs, itemType, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("exactly one sketch file is required")
+	}
+	images, err := readImages(fs.Args())
+	if err != nil {
+		return err
+	}
+	errorType := frequencies.ErrorTypeEnum.NoFalsePositives
+	if *noFalseNegatives {
+		errorType = frequencies.ErrorTypeEnum.NoFalseNegatives
+	}
+	if *itemType == "long" {
+		sketch, err := frequencies.NewLongsSketchFromSlice(images[0])
+		if err != nil {
+			return err
+		}
+		rows, err := sketch.GetFrequentItems(errorType)
+		if err != nil {
+			return err
+		}
+		for i, row := range rows {
+			if i == *n && *n > 0 {
+				break
+			}
+			fmt.Fprintf(stdout, "%d\t%d\t%d\t%d\n", row.GetItem(), row.GetEstimate(), row.GetLowerBound(), row.GetUpperBound())
+		}
+		return nil
+	}
+	sketch, err := frequencies.NewItemsSketchFromSlice[string](images[0], frequencies.StringItemsSketchOp{})
+	if err != nil {
+		return err
+	}
+	rows, err := sketch.GetFrequentItems(errorType)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		if i == *n && *n > 0 {
+			break
+		}
+		fmt.Fprintf(stdout, "%s\t%d\t%d\t%d\n", row.GetItem(), row.GetEstimate(), row.GetLowerBound(), row.GetUpperBound())
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apache/datasketches-go/hll"
+)
+
+// hllTypeFlag parses the --type flag of the hll commands: 4, 6 or 8 bits per register.
+func hllTypeFlag(bits int) (hll.TgtHllType, error) {
+	switch bits {
+	case 4:
+		return hll.TgtHllTypeHll4, nil
+	case 6:
+		return hll.TgtHllTypeHll6, nil
+	case 8:
+		return hll.TgtHllTypeHll8, nil
+	default:
+		return 0, fmt.Errorf("invalid --type %d, must be 4, 6 or 8", bits)
+	}
+}
+
+func hllBuild(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("hll build")
+	lgK := fs.Int("lgk", 12, "log2 of the number of registers, between 4 and 21")
+	bits := fs.Int("type", 4, "bits per register: 4, 6 or 8")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	tgtHllType, err := hllTypeFlag(*bits)
+	if err != nil {
+		return err
+	}
+	sketch, err := hll.NewHllSketch(*lgK, tgtHllType)
+	if err != nil {
+		return err
+	}
+	if err := forEachLine(stdin, fs.Args(), sketch.UpdateString); err != nil {
+		return err
+	}
+	image, err := sketch.ToCompactSlice()
+	if err != nil {
+		return err
+	}
+	return writeImage(stdout, image)
+}
+
+func hllMerge(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("hll merge")
+	lgMaxK := fs.Int("lgk", 12, "log2 of the maximum number of registers of the result")
+	bits := fs.Int("type", 4, "bits per register of the result: 4, 6 or 8")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	tgtHllType, err := hllTypeFlag(*bits)
+	if err != nil {
+		return err
+	}
+	images, err := readImages(fs.Args())
+	if err != nil {
+		return err
+	}
+	union, err := hll.NewUnion(*lgMaxK)
+	if err != nil {
+		return err
+	}
+	for i, image := range images {
+		sketch, err := hll.NewHllSketchFromSlice(image, true)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fs.Arg(i), err)
+		}
+		if err := union.UpdateSketch(sketch); err != nil {
+			return err
+		}
+	}
+	result, err := union.GetResult(tgtHllType)
+	if err != nil {
+		return err
+	}
+	image, err := result.ToCompactSlice()
+	if err != nil {
+		return err
+	}
+	return writeImage(stdout, image)
+}
+
+func hllEstimate(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("hll estimate")
+	numStdDev := fs.Int("stddev", 2, "number of standard deviations of the bounds: 1, 2 or 3")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	images, err := readImages(fs.Args())
+	if err != nil {
+		return err
+	}
+	for i, image := range images {
+		sketch, err := hll.NewHllSketchFromSlice(image, true)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fs.Arg(i), err)
+		}
+		est, err := sketch.GetEstimate()
+		if err != nil {
+			return err
+		}
+		lb, err := sketch.GetLowerBound(*numStdDev)
+		if err != nil {
+			return err
+		}
+		ub, err := sketch.GetUpperBound(*numStdDev)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s\t%.0f\t%.0f\t%.0f\n", fs.Arg(i), est, lb, ub)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/apache/datasketches-go/kll"
+)
+
+// kllSketch is the part of the kll sketches used by the kll commands.
+type kllSketch[C comparable] interface {
+	Update(item C)
+	GetQuantiles(ranks []float64, inclusive bool) ([]C, error)
+	ToSlice() ([]byte, error)
+}
+
+// kllItems describes how the sketches of a kll command are created and merged, and how its items
+// are read.
+type kllItems[C comparable, S kllSketch[C]] struct {
+	newSketch func(k uint16) (S, error)
+	fromSlice func(image []byte) (S, error)
+	merge     func(sketch S, other S) error
+	parse     func(line string) (C, error)
+}
+
+// kllItemsOf returns the kllItems of an ItemsSketch whose items are serialized by op.
+func kllItemsOf[C comparable](op kll.ItemSketchOp[C], parse func(line string) (C, error)) kllItems[C, *kll.ItemsSketch[C]] {
+	return kllItems[C, *kll.ItemsSketch[C]]{
+		newSketch: func(k uint16) (*kll.ItemsSketch[C], error) { return kll.NewItemsSketch[C](k, op) },
+		fromSlice: func(image []byte) (*kll.ItemsSketch[C], error) { return kll.NewItemsSketchFromSlice[C](image, op) },
+		merge: func(sketch *kll.ItemsSketch[C], other *kll.ItemsSketch[C]) error {
+			return sketch.Merge(other)
+		},
+		parse: parse,
+	}
+}
+
+var (
+	kllStrings = kllItemsOf[string](kll.StringItemsSketchOp{}, func(line string) (string, error) { return line, nil })
+	kllLongs   = kllItemsOf[int64](kll.Int64ItemsSketchOp{}, func(line string) (int64, error) { return strconv.ParseInt(line, 10, 64) })
+	kllDoubles = kllItems[float64, *kll.DoublesSketch]{
+		newSketch: func(k uint16) (*kll.DoublesSketch, error) { return kll.NewDoublesSketch(k) },
+		fromSlice: func(image []byte) (*kll.DoublesSketch, error) { return kll.NewDoublesSketchFromSlice(image) },
+		merge: func(sketch *kll.DoublesSketch, other *kll.DoublesSketch) error {
+			return sketch.Merge(other)
+		},
+		parse: func(line string) (float64, error) { return strconv.ParseFloat(line, 64) },
+	}
+)
+
+// newKllFlags returns the flags of a kll command with its --type flag.
+func newKllFlags(name string) (*flag.FlagSet, *string) {
+	fs := newFlagSet(name)
+	return fs, fs.String("type", "double", "type of the items: string, long or double")
+}
+
+// kllRunner runs a kll command for the items given by the --type flag. Its functions only differ by
+// the instantiation of the generic function they call.
+type kllRunner struct {
+	strings func(kllItems[string, *kll.ItemsSketch[string]]) error
+	longs   func(kllItems[int64, *kll.ItemsSketch[int64]]) error
+	doubles func(kllItems[float64, *kll.DoublesSketch]) error
+}
+
+func (r kllRunner) run(itemType string) error {
+	switch itemType {
+	case "string":
+		return r.strings(kllStrings)
+	case "long":
+		return r.longs(kllLongs)
+	case "double":
+		return r.doubles(kllDoubles)
+	default:
+		return fmt.Errorf("invalid --type %q, must be string, long or double", itemType)
+	}
+}
+
+func kllBuild(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, itemType := newKllFlags("kll build")
+	k := fs.Uint("k", 200, "parameter controlling the accuracy and the size of the sketch, between 8 and 65535")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *k > 65535 {
+		return fmt.Errorf("invalid --k %d, must be at most 65535", *k)
+	}
+	return kllRunner{
+		strings: func(items kllItems[string, *kll.ItemsSketch[string]]) error {
+			return kllBuildItems(items, uint16(*k), stdin, fs.Args(), stdout)
+		},
+		longs: func(items kllItems[int64, *kll.ItemsSketch[int64]]) error {
+			return kllBuildItems(items, uint16(*k), stdin, fs.Args(), stdout)
+		},
+		doubles: func(items kllItems[float64, *kll.DoublesSketch]) error {
+			return kllBuildItems(items, uint16(*k), stdin, fs.Args(), stdout)
+		},
+	}.run(*itemType)
+}
+
+func kllBuildItems[C comparable, S kllSketch[C]](items kllItems[C, S], k uint16, stdin io.Reader, files []string,
+	stdout io.Writer) error {
+	sketch, err := items.newSketch(k)
+	if err != nil {
+		return err
+	}
+	err = forEachLine(stdin, files, func(line string) error {
+		item, err := items.parse(line)
+		if err != nil {
+			return err
+		}
+		sketch.Update(item)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	image, err := sketch.ToSlice()
+	if err != nil {
+		return err
+	}
+	return writeImage(stdout, image)
+}
+
+func kllMerge(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, itemType := newKllFlags("kll merge")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	return kllRunner{
+		strings: func(items kllItems[string, *kll.ItemsSketch[string]]) error {
+			return kllMergeItems(items, fs.Args(), stdout)
+		},
+		longs: func(items kllItems[int64, *kll.ItemsSketch[int64]]) error {
+			return kllMergeItems(items, fs.Args(), stdout)
+		},
+		doubles: func(items kllItems[float64, *kll.DoublesSketch]) error {
+			return kllMergeItems(items, fs.Args(), stdout)
+		},
+	}.run(*itemType)
+}
+
+func kllMergeItems[C comparable, S kllSketch[C]](items kllItems[C, S], files []string, stdout io.Writer) error {
+	sketches, err := kllReadSketches(items, files)
+	if err != nil {
+		return err
+	}
+	for _, sketch := range sketches[1:] {
+		if err := items.merge(sketches[0], sketch); err != nil {
+			return err
+		}
+	}
+	image, err := sketches[0].ToSlice()
+	if err != nil {
+		return err
+	}
+	return writeImage(stdout, image)
+}
+
+func kllQuantiles(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, itemType := newKllFlags("kll quantiles")
+	ranksFlag := fs.String("ranks", "0,0.25,0.5,0.75,1", "comma-separated normalized ranks")
+	exclusive := fs.Bool("exclusive", false, "use the exclusive search criterion")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var ranks []float64
+	for _, s := range strings.Split(*ranksFlag, ",") {
+		rank, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return fmt.Errorf("invalid --ranks: %w", err)
+		}
+		ranks = append(ranks, rank)
+	}
+	return kllRunner{
+		strings: func(items kllItems[string, *kll.ItemsSketch[string]]) error {
+			return kllQuantilesItems(items, fs.Args(), ranks, !*exclusive, stdout)
+		},
+		longs: func(items kllItems[int64, *kll.ItemsSketch[int64]]) error {
+			return kllQuantilesItems(items, fs.Args(), ranks, !*exclusive, stdout)
+		},
+		doubles: func(items kllItems[float64, *kll.DoublesSketch]) error {
+			return kllQuantilesItems(items, fs.Args(), ranks, !*exclusive, stdout)
+		},
+	}.run(*itemType)
+}
+
+func kllQuantilesItems[C comparable, S kllSketch[C]](items kllItems[C, S], files []string, ranks []float64,
+	inclusive bool, stdout io.Writer) error {
+	sketches, err := kllReadSketches(items, files)
+	if err != nil {
+		return err
+	}
+	for i, sketch := range sketches {
+		quantiles, err := sketch.GetQuantiles(ranks, inclusive)
+		if err != nil {
+			return fmt.Errorf("%s: %w", files[i], err)
+		}
+		for j, quantile := range quantiles {
+			fmt.Fprintf(stdout, "%s\t%g\t%v\n", files[i], ranks[j], quantile)
+		}
+	}
+	return nil
+}
+
+func kllReadSketches[C comparable, S kllSketch[C]](items kllItems[C, S], files []string) ([]S, error) {
+	images, err := readImages(files)
+	if err != nil {
+		return nil, err
+	}
+	sketches := make([]S, len(images))
+	for i, image := range images {
+		if sketches[i], err = items.fromSlice(image); err != nil {
+			return nil, fmt.Errorf("%s: %w", files[i], err)
+		}
+	}
+	return sketches, nil
+}
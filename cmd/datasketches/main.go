@@ -0,0 +1,182 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command datasketches builds, merges and queries sketches from shell pipelines.
+//
+// Input items are read one per line, from the standard input or from the given files, and
+// sketches are written to the standard output as images compatible with the Java and C++
+// DataSketches libraries.
+//
+// Usage:
+//
+//	datasketches hll build [--lgk 12] [--type 4|6|8] [file ...] > out.sk
+//	datasketches hll merge [--lgk 12] [--type 4|6|8] a.sk b.sk ... > out.sk
+//	datasketches hll estimate [--stddev 2] file.sk
+//	datasketches freq build [--lg-max-map-size 10] [--type string|long] [file ...] > out.sk
+//	datasketches freq merge [--type string|long] a.sk b.sk ... > out.sk
+//	datasketches freq top [--n 20] [--type string|long] [--no-false-negatives] file.sk
+//	datasketches kll build [--k 200] [--type double|long|string] [file ...] > out.sk
+//	datasketches kll merge [--type double|long|string] a.sk b.sk ... > out.sk
+//	datasketches kll quantiles [--ranks 0.5,0.99] [--exclusive] [--type double|long|string] file.sk ...
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: datasketches <family> <command> [flags] [args]
+
+families and commands:
+  hll build | merge | estimate
+  freq build | merge | top
+  kll build | merge | quantiles
+
+run "datasketches <family> <command> -h" for the flags of a command
+`
+
+// command runs a command with its arguments, reading items from stdin when no file is given and
+// writing its output to stdout.
+type command func(args []string, stdin io.Reader, stdout io.Writer) error
+
+var commands = map[string]map[string]command{
+	"hll": {
+		"build":    hllBuild,
+		"merge":    hllMerge,
+		"estimate": hllEstimate,
+	},
+	"freq": {
+		"build": freqBuild,
+		"merge": freqMerge,
+		"top":   freqTop,
+	},
+	"kll": {
+		"build":     kllBuild,
+		"merge":     kllMerge,
+		"quantiles": kllQuantiles,
+	},
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]][os.Args[2]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s %s\n%s", os.Args[1], os.Args[2], usage)
+		os.Exit(2)
+	}
+	err := cmd(os.Args[3:], os.Stdin, os.Stdout)
+	var flagErr flagError
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case errors.As(err, &flagErr):
+		// the flag set already printed the error and the usage of the command
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "datasketches %s %s: %v\n", os.Args[1], os.Args[2], err)
+		os.Exit(1)
+	}
+}
+
+// flagError is an error parsing the flags of a command.
+type flagError struct {
+	err error
+}
+
+func (e flagError) Error() string {
+	return e.err.Error()
+}
+
+func (e flagError) Unwrap() error {
+	return e.err
+}
+
+// newFlagSet returns the flag set of a command, whose parse errors are returned by parseFlags
+// rather than exiting, so that main decides the exit code.
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// parseFlags parses the flags of a command and returns a flagError if they are invalid or if -h
+// was given.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return flagError{err}
+	}
+	return nil
+}
+
+// forEachLine calls fn with every line of the given files, or of stdin if there are no files. Line
+// terminators are not part of the lines.
+func forEachLine(stdin io.Reader, files []string, fn func(line string) error) error {
+	if len(files) == 0 {
+		return scanLines(stdin, fn)
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		err = scanLines(f, fn)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func scanLines(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// readImages reads the sketch images of the given files, at least one file is required.
+func readImages(files []string) ([][]byte, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no sketch file given")
+	}
+	images := make([][]byte, len(files))
+	for i, file := range files {
+		image, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		images[i] = image
+	}
+	return images, nil
+}
+
+// writeImage writes a sketch image to stdout.
+func writeImage(stdout io.Writer, image []byte) error {
+	_, err := stdout.Write(image)
+	return err
+}
@@ -0,0 +1,351 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apache/datasketches-go/frequencies"
+	"github.com/apache/datasketches-go/hll"
+	"github.com/apache/datasketches-go/kll"
+	"github.com/stretchr/testify/assert"
+)
+
+// numbers returns the lines of the integers from first to last.
+func numbers(first, last int) []string {
+	var lines []string
+	for i := first; i <= last; i++ {
+		lines = append(lines, strconv.Itoa(i))
+	}
+	return lines
+}
+
+// repeat returns n times the given line.
+func repeat(line string, n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = line
+	}
+	return lines
+}
+
+// read returns the content of a file written by a command.
+func read(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return data
+}
+
+// runCommand runs a command with the given standard input and returns its standard output.
+func runCommand(t *testing.T, family, name string, stdin string, args ...string) []byte {
+	t.Helper()
+	var stdout bytes.Buffer
+	err := commands[family][name](args, strings.NewReader(stdin), &stdout)
+	assert.NoError(t, err, "%s %s %v", family, name, args)
+	return stdout.Bytes()
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		family string
+		query  string
+		// flags are given to every command, and buildFlags to the build command only.
+		flags, buildFlags []string
+		// linesA is read from the standard input and linesB from a file.
+		linesA, linesB []string
+		// checkImage checks the image of a sketch built from lines.
+		checkImage func(t *testing.T, image []byte, lines []string)
+		// checkQuery checks the output of the query of the file holding the sketch of lines.
+		checkQuery func(t *testing.T, out string, file string, lines []string)
+	}{
+		{
+			family: "hll",
+			query:  "estimate",
+			flags:  []string{"--lgk", "10", "--type", "8"},
+			linesA: numbers(1, 1000),
+			linesB: numbers(501, 3000),
+			checkImage: func(t *testing.T, image []byte, lines []string) {
+				sketch, err := hll.NewHllSketchFromSlice(image, true)
+				assert.NoError(t, err)
+				assert.Equal(t, 10, sketch.GetLgConfigK())
+				assert.Equal(t, hll.TgtHllTypeHll8, sketch.GetTgtHllType())
+				est, err := sketch.GetEstimate()
+				assert.NoError(t, err)
+				assert.InEpsilon(t, float64(distinct(lines)), est, 0.1)
+			},
+			checkQuery: func(t *testing.T, out string, file string, lines []string) {
+				fields := strings.Split(strings.TrimSuffix(out, "\n"), "\t")
+				assert.Len(t, fields, 4)
+				assert.Equal(t, file, fields[0])
+				est, err := strconv.ParseFloat(fields[1], 64)
+				assert.NoError(t, err)
+				assert.InEpsilon(t, float64(distinct(lines)), est, 0.1)
+			},
+		},
+		{
+			family: "freq",
+			query:  "top",
+			flags:  []string{"--type", "string"},
+			linesA: append(numbers(1, 100), repeat("hot", 30)...),
+			linesB: append(numbers(51, 150), repeat("hot", 20)...),
+			checkImage: func(t *testing.T, image []byte, lines []string) {
+				sketch, err := frequencies.NewItemsSketchFromSlice[string](image, frequencies.StringItemsSketchOp{})
+				assert.NoError(t, err)
+				assert.Equal(t, int64(len(lines)), sketch.GetStreamLength())
+				est, err := sketch.GetEstimate("hot")
+				assert.NoError(t, err)
+				assert.Equal(t, int64(count(lines, "hot")), est)
+			},
+			checkQuery: func(t *testing.T, out string, file string, lines []string) {
+				n := count(lines, "hot")
+				assert.True(t, strings.HasPrefix(out, fmt.Sprintf("hot\t%d\t%d\t%d\n", n, n, n)), out)
+			},
+		},
+		{
+			family: "freq",
+			query:  "top",
+			flags:  []string{"--type", "long"},
+			linesA: append(numbers(1, 100), repeat("-7", 30)...),
+			linesB: append(numbers(51, 150), repeat("-7", 20)...),
+			checkImage: func(t *testing.T, image []byte, lines []string) {
+				sketch, err := frequencies.NewLongsSketchFromSlice(image)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(len(lines)), sketch.GetStreamLength())
+				est, err := sketch.GetEstimate(-7)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(count(lines, "-7")), est)
+			},
+			checkQuery: func(t *testing.T, out string, file string, lines []string) {
+				n := count(lines, "-7")
+				assert.True(t, strings.HasPrefix(out, fmt.Sprintf("-7\t%d\t%d\t%d\n", n, n, n)), out)
+			},
+		},
+		{
+			family: "kll",
+			query:  "quantiles",
+			flags:  []string{"--type", "double"},
+			linesA: numbers(1, 1000),
+			linesB: append(numbers(501, 1500), "0.5"),
+			checkImage: func(t *testing.T, image []byte, lines []string) {
+				// Doubles are sketched by a DoublesSketch, whose images are not readable as
+				// ItemsSketch[float64] images.
+				sketch, err := kll.NewDoublesSketchFromSlice(image)
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(len(lines)), sketch.GetN())
+				assert.Equal(t, uint16(200), sketch.GetK())
+				minItem, err := sketch.GetMinItem()
+				assert.NoError(t, err)
+				maxItem, err := sketch.GetMaxItem()
+				assert.NoError(t, err)
+				assert.Equal(t, minOf(lines, parseFloat), minItem)
+				assert.Equal(t, maxOf(lines, parseFloat), maxItem)
+			},
+			checkQuery: func(t *testing.T, out string, file string, lines []string) {
+				sketch, err := kll.NewDoublesSketchFromSlice(read(t, file))
+				assert.NoError(t, err)
+				quantiles, err := sketch.GetQuantiles([]float64{0, 1}, true)
+				assert.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("%s\t0\t%v\n%s\t1\t%v\n", file, quantiles[0], file, quantiles[1]), out)
+				assert.GreaterOrEqual(t, quantiles[0], minOf(lines, parseFloat))
+				assert.LessOrEqual(t, quantiles[1], maxOf(lines, parseFloat))
+			},
+		},
+		{
+			family:     "kll",
+			query:      "quantiles",
+			flags:      []string{"--type", "long"},
+			buildFlags: []string{"--k", "50"},
+			linesA:     numbers(1, 1000),
+			linesB:     numbers(-500, 100),
+			checkImage: func(t *testing.T, image []byte, lines []string) {
+				sketch, err := kll.NewItemsSketchFromSlice[int64](image, kll.Int64ItemsSketchOp{})
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(len(lines)), sketch.GetN())
+				assert.Equal(t, uint16(50), sketch.GetK())
+				minItem, err := sketch.GetMinItem()
+				assert.NoError(t, err)
+				maxItem, err := sketch.GetMaxItem()
+				assert.NoError(t, err)
+				assert.Equal(t, minOf(lines, parseInt), minItem)
+				assert.Equal(t, maxOf(lines, parseInt), maxItem)
+			},
+			checkQuery: func(t *testing.T, out string, file string, lines []string) {
+				sketch, err := kll.NewItemsSketchFromSlice[int64](read(t, file), kll.Int64ItemsSketchOp{})
+				assert.NoError(t, err)
+				quantiles, err := sketch.GetQuantiles([]float64{0, 1}, true)
+				assert.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("%s\t0\t%v\n%s\t1\t%v\n", file, quantiles[0], file, quantiles[1]), out)
+				assert.GreaterOrEqual(t, quantiles[0], minOf(lines, parseInt))
+				assert.LessOrEqual(t, quantiles[1], maxOf(lines, parseInt))
+			},
+		},
+		{
+			family: "kll",
+			query:  "quantiles",
+			flags:  []string{"--type", "string"},
+			linesA: numbers(1, 1000),
+			linesB: append(numbers(501, 1500), "", "zebra"),
+			checkImage: func(t *testing.T, image []byte, lines []string) {
+				sketch, err := kll.NewItemsSketchFromSlice[string](image, kll.StringItemsSketchOp{})
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(len(lines)), sketch.GetN())
+				minItem, err := sketch.GetMinItem()
+				assert.NoError(t, err)
+				maxItem, err := sketch.GetMaxItem()
+				assert.NoError(t, err)
+				assert.Equal(t, minOf(lines, parseString), minItem)
+				assert.Equal(t, maxOf(lines, parseString), maxItem)
+			},
+			checkQuery: func(t *testing.T, out string, file string, lines []string) {
+				sketch, err := kll.NewItemsSketchFromSlice[string](read(t, file), kll.StringItemsSketchOp{})
+				assert.NoError(t, err)
+				quantiles, err := sketch.GetQuantiles([]float64{0, 1}, true)
+				assert.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("%s\t0\t%v\n%s\t1\t%v\n", file, quantiles[0], file, quantiles[1]), out)
+				assert.GreaterOrEqual(t, quantiles[0], minOf(lines, parseString))
+				assert.LessOrEqual(t, quantiles[1], maxOf(lines, parseString))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.family+" "+strings.Join(tt.flags, " "), func(t *testing.T) {
+			dir := t.TempDir()
+			write := func(name string, data []byte) string {
+				path := filepath.Join(dir, name)
+				assert.NoError(t, os.WriteFile(path, data, 0o644))
+				return path
+			}
+			input := func(lines []string) string {
+				return strings.Join(lines, "\n") + "\n"
+			}
+			queryArgs := func(file string) []string {
+				args := tt.flags
+				switch tt.family {
+				case "hll":
+					return []string{file}
+				case "kll":
+					args = append(args[:len(args):len(args)], "--ranks", "0,1")
+				}
+				return append(args[:len(args):len(args)], file)
+			}
+
+			buildFlags := append(tt.flags[:len(tt.flags):len(tt.flags)], tt.buildFlags...)
+			a := write("a.sk", runCommand(t, tt.family, "build", input(tt.linesA), buildFlags...))
+			tt.checkImage(t, read(t, a), tt.linesA)
+
+			linesFile := write("b.txt", []byte(input(tt.linesB)))
+			b := write("b.sk", runCommand(t, tt.family, "build", "", append(buildFlags, linesFile)...))
+			tt.checkImage(t, read(t, b), tt.linesB)
+
+			linesAB := append(tt.linesA[:len(tt.linesA):len(tt.linesA)], tt.linesB...)
+			ab := write("ab.sk", runCommand(t, tt.family, "merge", "", append(tt.flags, a, b)...))
+			tt.checkImage(t, read(t, ab), linesAB)
+
+			tt.checkQuery(t, string(runCommand(t, tt.family, tt.query, "", queryArgs(a)...)), a, tt.linesA)
+			tt.checkQuery(t, string(runCommand(t, tt.family, tt.query, "", queryArgs(ab)...)), ab, linesAB)
+		})
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	var stdout bytes.Buffer
+	err := kllBuild([]string{"--type", "double"}, strings.NewReader("1\nnot a number\n"), &stdout)
+	assert.Error(t, err)
+	err = kllBuild([]string{"--type", "bool"}, strings.NewReader(""), &stdout)
+	assert.ErrorContains(t, err, "invalid --type")
+	err = freqBuild([]string{"--type", "long"}, strings.NewReader("1.5\n"), &stdout)
+	assert.Error(t, err)
+	err = hllBuild([]string{"--type", "5"}, strings.NewReader(""), &stdout)
+	assert.ErrorContains(t, err, "invalid --type")
+	err = hllMerge(nil, strings.NewReader(""), &stdout)
+	assert.ErrorContains(t, err, "no sketch file given")
+
+	// invalid flags are returned to main instead of exiting
+	for _, cmd := range []command{hllBuild, hllMerge, hllEstimate, freqBuild, freqMerge, freqTop, kllBuild, kllMerge, kllQuantiles} {
+		err = cmd([]string{"--no-such-flag"}, strings.NewReader(""), &stdout)
+		var flagErr flagError
+		assert.ErrorAs(t, err, &flagErr)
+		assert.ErrorContains(t, err, "no-such-flag")
+		err = cmd([]string{"-h"}, strings.NewReader(""), &stdout)
+		assert.ErrorIs(t, err, flag.ErrHelp)
+	}
+	assert.Zero(t, stdout.Len())
+
+	// a kll image of doubles is not a kll image of strings
+	image := runCommand(t, "kll", "build", "1\n2\n3\n", "--type", "double")
+	file := filepath.Join(t.TempDir(), "doubles.sk")
+	assert.NoError(t, os.WriteFile(file, image, 0o644))
+	err = kllQuantiles([]string{"--type", "string", file}, strings.NewReader(""), &stdout)
+	assert.ErrorContains(t, err, file)
+}
+
+func distinct(lines []string) int {
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		seen[line] = true
+	}
+	return len(seen)
+}
+
+func count(lines []string, item string) int {
+	n := 0
+	for _, line := range lines {
+		if line == item {
+			n++
+		}
+	}
+	return n
+}
+
+func parseFloat(line string) float64 {
+	v, _ := strconv.ParseFloat(line, 64)
+	return v
+}
+
+func parseInt(line string) int64 {
+	v, _ := strconv.ParseInt(line, 10, 64)
+	return v
+}
+
+func parseString(line string) string {
+	return line
+}
+
+func minOf[T int64 | float64 | string](lines []string, parse func(string) T) T {
+	result := parse(lines[0])
+	for _, line := range lines[1:] {
+		result = min(result, parse(line))
+	}
+	return result
+}
+
+func maxOf[T int64 | float64 | string](lines []string, parse func(string) T) T {
+	result := parse(lines[0])
+	for _, line := range lines[1:] {
+		result = max(result, parse(line))
+	}
+	return result
+}
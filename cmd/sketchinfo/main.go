@@ -0,0 +1,61 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command sketchinfo prints the family, serial version and preamble of serialized sketch images.
+//
+// Usage:
+//
+//	sketchinfo file.sk [file.sk ...]
+//
+// The exit status is 1 if a file cannot be read or is not a valid sketch image.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apache/datasketches-go/sketchinfo"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: sketchinfo file.sk [file.sk ...]")
+		os.Exit(2)
+	}
+	status := 0
+	for _, file := range os.Args[1:] {
+		if !inspectFile(file) {
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func inspectFile(file string) bool {
+	image, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	info, err := sketchinfo.Inspect(image)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		return false
+	}
+	fmt.Printf("%s:\n%s", file, info)
+	return info.Valid()
+}
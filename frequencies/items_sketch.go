@@ -26,10 +26,13 @@
 package frequencies
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/apache/datasketches-go/internal"
+	"io"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -59,6 +62,23 @@ type ItemSketchOp[C comparable] interface {
 	DeserializeManyFromSlice(slc []byte, offset int, length int) []C
 }
 
+// CheckedItemSketchOp is an optional interface of an ItemSketchOp, implemented by StringItemsSketchOp,
+// with which NewItemsSketchFromSlice and ItemsSketch.ReadFrom report the items of a corrupted image
+// as an error, DeserializeManyFromSlice being free to panic when slc does not hold them.
+type CheckedItemSketchOp[C comparable] interface {
+	// DeserializeManyFromSliceChecked returns the length items serialized at the given offset of slc,
+	// or an error if slc does not hold them.
+	DeserializeManyFromSliceChecked(slc []byte, offset int, length int) ([]C, error)
+}
+
+// ItemReader is an optional interface of an ItemSketchOp, implemented by StringItemsSketchOp, with
+// which ItemsSketch.ReadFrom reads the items one at a time from its reader.
+type ItemReader[C comparable] interface {
+	// ReadItem reads exactly one serialized item from r, it returns io.ErrUnexpectedEOF if r ends
+	// within the item.
+	ReadItem(r io.Reader) (C, error)
+}
+
 // NewItemsSketch constructs a new ItemsSketch with the given parameters.
 // this internal constructor is used when deserializing the sketch.
 //
@@ -111,7 +131,47 @@ func NewItemsSketchWithMaxMapSize[C comparable](maxMapSize int, operations ItemS
 // 0.75 times * maxMapSize. Both the ultimate accuracy and size of this sketch are a
 // function of maxMapSize.
 func NewItemsSketchFromSlice[C comparable](slc []byte, operations ItemSketchOp[C]) (*ItemsSketch[C], error) {
+	if err := checkImageBytes(slc, 8); err != nil { // count only
+		return nil, err
+	}
+	fis, activeItems, streamWeight, err := newItemsSketchFromPreamble[C](slc, operations)
+	if err != nil || activeItems == 0 {
+		return fis, err
+	}
+	preBytes := internal.FamilyEnum.Frequency.MaxPreLongs << 3
+
+	// Get countArray
+	countArray := make([]int64, activeItems)
+	reqBytes := preBytes + activeItems*8 // count Arr only
+	if len(slc) < reqBytes {
+		return nil, fmt.Errorf("possible Corruption: Insufficient bytes in array: %d, %d", len(slc), reqBytes)
+	}
+	for j := 0; j < activeItems; j++ {
+		countArray[j] = int64(binary.LittleEndian.Uint64(slc[preBytes+j<<3:]))
+	}
+	if err := checkCounts(countArray, streamWeight); err != nil {
+		return nil, err
+	}
+	// Get itemArray
+	itemsOffset := preBytes + (8 * activeItems)
+	itemArray, err := deserializeItems(operations, slc[itemsOffset:], 0, activeItems)
+	if err != nil {
+		return nil, fmt.Errorf("possible corruption: %w", err)
+	}
+	if err := fis.updateAll(itemArray, countArray); err != nil {
+		return nil, err
+	}
+	fis.streamWeight = streamWeight // override streamWeight due to updating
+	return fis, nil
+}
+
+// newItemsSketchFromPreamble checks the preamble of a serialized image and returns the sketch to be
+// updated with the active items of the image, their number and the stream weight of the image.
+func newItemsSketchFromPreamble[C comparable](slc []byte, operations ItemSketchOp[C]) (*ItemsSketch[C], int, int64, error) {
 	pre0, err := checkPreambleSize(slc) //make sure preamble will fit
+	if err != nil {
+		return nil, 0, 0, err
+	}
 	maxPreLongs := internal.FamilyEnum.Frequency.MaxPreLongs
 
 	preLongs := extractPreLongs(pre0)                     //Byte 0
@@ -125,58 +185,68 @@ func NewItemsSketchFromSlice[C comparable](slc []byte, operations ItemSketchOp[C
 	preLongsEq1 := (preLongs == 1) //Byte 0
 	preLongsEqMax := (preLongs == maxPreLongs)
 	if !preLongsEq1 && !preLongsEqMax {
-		return nil, fmt.Errorf("possible corruption: preLongs must be 1 or %d: %d", maxPreLongs, preLongs)
+		return nil, 0, 0, fmt.Errorf("possible corruption: preLongs must be 1 or %d: %d", maxPreLongs, preLongs)
 	}
 	if serVer != _SER_VER { //Byte 1
-		return nil, fmt.Errorf("possible corruption: ser ver must be %d: %d", _SER_VER, serVer)
+		return nil, 0, 0, fmt.Errorf("possible corruption: ser ver must be %d: %d", _SER_VER, serVer)
 	}
 	actFamID := internal.FamilyEnum.Frequency.Id //Byte 2
 	if familyID != actFamID {
-		return nil, fmt.Errorf("possible corruption: familyID must be %d: %d", actFamID, familyID)
+		return nil, 0, 0, fmt.Errorf("possible corruption: familyID must be %d: %d", actFamID, familyID)
 	}
 	if empty && !preLongsEq1 { //Byte 5 and Byte 0
-		return nil, fmt.Errorf("(preLongs == 1) ^ empty == true")
+		return nil, 0, 0, fmt.Errorf("(preLongs == 1) ^ empty == true")
+	}
+	if !empty && !preLongsEqMax {
+		return nil, 0, 0, fmt.Errorf("possible corruption: empty flag not set with preLongs: %d", preLongs)
+	}
+	if lgMaxMapSize > _LG_MAX_MAP_SIZE {
+		return nil, 0, 0, fmt.Errorf("possible corruption: lgMaxMapSize must be <= %d: %d", _LG_MAX_MAP_SIZE, lgMaxMapSize)
 	}
 	if empty {
-		return NewItemsSketchWithMaxMapSize[C](1<<_LG_MIN_MAP_SIZE, operations)
+		fis, err := NewItemsSketchWithMaxMapSize[C](1<<_LG_MIN_MAP_SIZE, operations)
+		return fis, 0, 0, err
 	}
 	// Get full preamble
 	preArr := make([]int64, preLongs)
 	for j := 0; j < preLongs; j++ {
 		preArr[j] = int64(binary.LittleEndian.Uint64(slc[j<<3:]))
 	}
+	activeItems := extractActiveItems(preArr[1])
+	lgCurMapSize, err = checkMapSizes(lgMaxMapSize, lgCurMapSize, activeItems)
+	if err != nil {
+		return nil, 0, 0, err
+	}
 
 	fis, err := NewItemsSketch[C](int(lgMaxMapSize), int(lgCurMapSize), operations)
 	if err != nil {
-		return nil, err
+		return nil, 0, 0, err
 	}
 	fis.streamWeight = 0 // update after
 	fis.offset = preArr[3]
+	return fis, activeItems, preArr[2], nil
+}
 
-	preBytes := preLongs << 3
-	activeItems := extractActiveItems(preArr[1])
-
-	// Get countArray
-	countArray := make([]int64, activeItems)
-	reqBytes := preBytes + activeItems*8 // count Arr only
-	if len(slc) < reqBytes {
-		return nil, fmt.Errorf("possible Corruption: Insufficient bytes in array: %d, %d", len(slc), reqBytes)
+// deserializeItems deserializes the items with DeserializeManyFromSliceChecked if operations is a
+// CheckedItemSketchOp, otherwise with DeserializeManyFromSlice.
+func deserializeItems[C comparable](operations ItemSketchOp[C], slc []byte, offset int, length int) ([]C, error) {
+	if checked, ok := operations.(CheckedItemSketchOp[C]); ok {
+		return checked.DeserializeManyFromSliceChecked(slc, offset, length)
 	}
-	for j := 0; j < activeItems; j++ {
-		countArray[j] = int64(binary.LittleEndian.Uint64(slc[preBytes+j<<3:]))
+	return operations.DeserializeManyFromSlice(slc, offset, length), nil
+}
+
+// updateAll updates the sketch with the given items and their counts.
+func (i *ItemsSketch[C]) updateAll(items []C, counts []int64) error {
+	if len(items) < len(counts) {
+		return fmt.Errorf("possible corruption: %d items for %d counts", len(items), len(counts))
 	}
-	// Get itemArray
-	itemsOffset := preBytes + (8 * activeItems)
-	itemArray := operations.DeserializeManyFromSlice(slc[itemsOffset:], 0, activeItems)
-	// update the sketch
-	for j := 0; j < activeItems; j++ {
-		err := fis.UpdateMany(itemArray[j], countArray[j])
-		if err != nil {
-			return nil, err
+	for j, count := range counts {
+		if err := i.UpdateMany(items[j], count); err != nil {
+			return err
 		}
 	}
-	fis.streamWeight = preArr[2] // override streamWeight due to updating
-	return fis, nil
+	return nil
 }
 
 // GetAprioriErrorItemsSketch returns the estimated a priori error given the maxMapSize for the sketch and the
@@ -395,52 +465,138 @@ func (i *ItemsSketch[C]) ToString() (string, error) {
 
 // ToSlice returns a slice representation of this sketch
 func (i *ItemsSketch[C]) ToSlice() []byte {
-	preLongs := 0
-	outBytes := 0
+	var buf bytes.Buffer
+	i.WriteTo(&buf) // a bytes.Buffer never fails to write
+	return buf.Bytes()
+}
+
+// WriteTo implements io.WriterTo, it writes the image of ToSlice to w without building it in memory:
+// the preamble is written first, then the counts by chunks of internal.StreamChunkItems longs and the
+// items, serialized by chunks of internal.StreamChunkItems items.
+func (i *ItemsSketch[C]) WriteTo(w io.Writer) (int64, error) {
+	cw := internal.NewCountingWriter(w)
 	empty := i.IsEmpty()
-	activeItems := i.GetNumActiveItems()
-	bytes := make([]byte, 0)
-	if empty {
-		preLongs = 1
-		outBytes = 8
-	} else {
-		preLongs = internal.FamilyEnum.Frequency.MaxPreLongs
-		bytes = i.hashMap.operations.SerializeManyToSlice(i.hashMap.getActiveKeys())
-		outBytes = ((preLongs + activeItems) << 3) + len(bytes)
-	}
-
-	outArr := make([]byte, outBytes)
-	pre0 := int64(0)
-	pre0 = insertPreLongs(int64(preLongs), pre0)                         //Byte 0
-	pre0 = insertSerVer(_SER_VER, pre0)                                  //Byte 1
-	pre0 = insertFamilyID(int64(internal.FamilyEnum.Frequency.Id), pre0) //Byte 2
-	pre0 = insertLgMaxMapSize(int64(i.lgMaxMapSize), pre0)               //Byte 3
-	pre0 = insertLgCurMapSize(int64(i.hashMap.lgLength), pre0)           //Byte 4
+	writePreamble(cw, empty, i.lgMaxMapSize, i.hashMap.lgLength, i.GetNumActiveItems(), i.streamWeight, i.offset)
 	if empty {
-		pre0 = insertFlags(_EMPTY_FLAG_MASK, pre0) //Byte 5
-	} else {
-		pre0 = insertFlags(0, pre0) //Byte 5
+		return cw.Count(), cw.Err()
+	}
+	writeActiveLongs(cw, i.hashMap.values, i.hashMap.states)
+	chunk := make([]C, 0, internal.StreamChunkItems)
+	for j := 0; j < len(i.hashMap.keys) && cw.Err() == nil; j++ {
+		if i.hashMap.states[j] > 0 { //isActive
+			chunk = append(chunk, i.hashMap.keys[j])
+			if len(chunk) == cap(chunk) {
+				cw.Write(i.hashMap.operations.SerializeManyToSlice(chunk))
+				chunk = chunk[:0]
+			}
+		}
+	}
+	if len(chunk) > 0 {
+		cw.Write(i.hashMap.operations.SerializeManyToSlice(chunk))
 	}
+	return cw.Count(), cw.Err()
+}
 
-	if empty {
-		binary.LittleEndian.PutUint64(outArr, uint64(pre0))
+// ReadFrom implements io.ReaderFrom, it replaces the content of the sketch with the image read from
+// r, deserialized with the ItemSketchOp of the sketch, see UnmarshalBinary. Unlike a plain
+// io.ReaderFrom, it reads exactly one image and leaves the rest of r unread, so that consecutive
+// images can be read from the same stream, io.EOF is returned if r is already at its end.
+// The preamble and the counts are read first, then the items are deserialized one at a time without
+// holding the image: with ReadItem if the ItemSketchOp implements ItemReader, otherwise it must
+// implement CheckedItemSketchOp and r must be a *bufio.Reader whose buffer holds the largest
+// serialized item, which is deserialized from the buffered bytes.
+func (i *ItemsSketch[C]) ReadFrom(r io.Reader) (int64, error) {
+	if i.hashMap == nil || i.hashMap.operations == nil {
+		return 0, fmt.Errorf("the sketch has no ItemSketchOp, it must be constructed with NewItemsSketch")
+	}
+	operations := i.hashMap.operations
+	cr := internal.NewCountingReader(r)
+	var readItem func() (C, error)
+	if itemReader, ok := operations.(ItemReader[C]); ok {
+		readItem = func() (C, error) {
+			return itemReader.ReadItem(cr)
+		}
+	} else if checked, ok := operations.(CheckedItemSketchOp[C]); ok {
+		readItem = func() (C, error) {
+			return internal.ReadItem(cr, func(buf []byte) (C, int, error) {
+				var item C
+				items, err := checked.DeserializeManyFromSliceChecked(buf, 0, 1)
+				if err != nil {
+					return item, 0, err
+				}
+				// the item is deserialized again from its own bytes, the buffer of the reader is not retained
+				size := len(operations.SerializeOneToSlice(items[0]))
+				if items, err = checked.DeserializeManyFromSliceChecked(slices.Clone(buf[:size]), 0, 1); err != nil {
+					return item, 0, err
+				}
+				return items[0], size, nil
+			})
+		}
 	} else {
-		pre := int64(0)
-		preArr := make([]int64, preLongs)
-		preArr[0] = pre0
-		preArr[1] = insertActiveItems(int64(activeItems), pre)
-		preArr[2] = int64(i.streamWeight)
-		preArr[3] = int64(i.offset)
-		for j := 0; j < preLongs; j++ {
-			binary.LittleEndian.PutUint64(outArr[j<<3:], uint64(preArr[j]))
+		return 0, fmt.Errorf("the ItemSketchOp must implement ItemReader or CheckedItemSketchOp")
+	}
+	preArr, err := readPreamble(cr)
+	if err != nil {
+		return cr.Count(), err
+	}
+	fis, activeItems, streamWeight, err := newItemsSketchFromPreamble[C](preArr, operations)
+	if err != nil {
+		return cr.Count(), err
+	}
+	countArray := make([]int64, activeItems)
+	if err := readLongs(cr, countArray); err != nil {
+		return cr.Count(), err
+	}
+	if err := checkCounts(countArray, streamWeight); err != nil {
+		return cr.Count(), err
+	}
+	for _, count := range countArray {
+		item, err := readItem()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return cr.Count(), err
 		}
-		preBytes := preLongs << 3
-		for j := 0; j < activeItems; j++ {
-			binary.LittleEndian.PutUint64(outArr[preBytes+j<<3:], uint64(i.hashMap.getActiveValues()[j]))
+		if err := fis.UpdateMany(item, count); err != nil {
+			return cr.Count(), err
 		}
-		copy(outArr[preBytes+(activeItems<<3):], bytes)
 	}
-	return outArr
+	if activeItems > 0 {
+		fis.streamWeight = streamWeight // override streamWeight due to updating
+	}
+	*i = *fis
+	return cr.Count(), nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, it returns the image of ToSlice.
+func (i *ItemsSketch[C]) MarshalBinary() ([]byte, error) {
+	return i.ToSlice(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, it replaces the content of the sketch with
+// the given image, deserialized with the ItemSketchOp of the sketch, which must then have been
+// constructed with NewItemsSketch or NewItemsSketchFromSlice.
+func (i *ItemsSketch[C]) UnmarshalBinary(data []byte) error {
+	if i.hashMap == nil || i.hashMap.operations == nil {
+		return fmt.Errorf("the sketch has no ItemSketchOp, it must be constructed with NewItemsSketch")
+	}
+	sketch, err := NewItemsSketchFromSlice[C](data, i.hashMap.operations)
+	if err != nil {
+		return err
+	}
+	*i = *sketch
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder, see MarshalBinary.
+func (i *ItemsSketch[C]) GobEncode() ([]byte, error) {
+	return i.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder, see UnmarshalBinary.
+func (i *ItemsSketch[C]) GobDecode(data []byte) error {
+	return i.UnmarshalBinary(data)
 }
 
 // Reset resets this sketch to a virgin state.
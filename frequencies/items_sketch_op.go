@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package frequencies
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"unsafe"
+
+	"github.com/apache/datasketches-go/internal"
+	"github.com/twmb/murmur3"
+)
+
+// StringItemsSketchOp is the ItemSketchOp of string items. Items are hashed with the 64-bit murmur3
+// hash of their bytes and the default update seed, and serialized as the Java ArrayOfStringsSerDe
+// does: each item is prefixed by its length in bytes as a 32-bit little-endian integer.
+type StringItemsSketchOp struct{}
+
+func (StringItemsSketchOp) Hash(item string) uint64 {
+	datum := unsafe.Slice(unsafe.StringData(item), len(item))
+	return murmur3.SeedSum64(internal.DEFAULT_UPDATE_SEED, datum)
+}
+
+func (StringItemsSketchOp) SerializeOneToSlice(item string) []byte {
+	out := make([]byte, 4+len(item))
+	binary.LittleEndian.PutUint32(out, uint32(len(item)))
+	copy(out[4:], item)
+	return out
+}
+
+func (StringItemsSketchOp) SerializeManyToSlice(items []string) []byte {
+	totalBytes := 0
+	for _, item := range items {
+		totalBytes += 4 + len(item)
+	}
+	out := make([]byte, totalBytes)
+	offset := 0
+	for _, item := range items {
+		binary.LittleEndian.PutUint32(out[offset:], uint32(len(item)))
+		offset += 4
+		offset += copy(out[offset:], item)
+	}
+	return out
+}
+
+// ReadItem implements ItemReader, see internal.ReadLengthPrefixed.
+func (StringItemsSketchOp) ReadItem(r io.Reader) (string, error) {
+	return internal.ReadLengthPrefixed(r)
+}
+
+// DeserializeManyFromSlice panics if slc does not hold the items, see DeserializeManyFromSliceChecked.
+func (op StringItemsSketchOp) DeserializeManyFromSlice(slc []byte, offset int, length int) []string {
+	items, err := op.DeserializeManyFromSliceChecked(slc, offset, length)
+	if err != nil {
+		panic(err)
+	}
+	return items
+}
+
+// DeserializeManyFromSliceChecked implements CheckedItemSketchOp.
+func (StringItemsSketchOp) DeserializeManyFromSliceChecked(slc []byte, offset int, length int) ([]string, error) {
+	if offset < 0 || length < 0 || length > (len(slc)-offset)/4 {
+		return nil, errors.New("insufficient bytes for the items")
+	}
+	items := make([]string, length)
+	for i := range items {
+		if offset+4 > len(slc) {
+			return nil, errors.New("insufficient bytes for the items")
+		}
+		itemLen := int(binary.LittleEndian.Uint32(slc[offset:]))
+		offset += 4
+		if itemLen > len(slc)-offset {
+			return nil, errors.New("insufficient bytes for the items")
+		}
+		items[i] = string(slc[offset : offset+itemLen])
+		offset += itemLen
+	}
+	return items, nil
+}
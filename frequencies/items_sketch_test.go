@@ -18,7 +18,13 @@
 package frequencies
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
 	"strconv"
 	"testing"
 	"unsafe"
@@ -28,58 +34,6 @@ import (
 	"github.com/twmb/murmur3"
 )
 
-type StringItemsSketchOp struct {
-}
-
-func (h StringItemsSketchOp) Hash(item string) uint64 {
-	datum := unsafe.Slice(unsafe.StringData(item), len(item))
-	return murmur3.SeedSum64(internal.DEFAULT_UPDATE_SEED, datum[:])
-}
-
-func (h StringItemsSketchOp) SerializeOneToSlice(item string) []byte {
-	panic("not implemented")
-}
-
-func (h StringItemsSketchOp) SerializeManyToSlice(item []string) []byte {
-	if len(item) == 0 {
-		return []byte{}
-	}
-	totalBytes := 0
-	numItems := len(item)
-	serialized2DArray := make([][]byte, numItems)
-	for i := 0; i < numItems; i++ {
-		serialized2DArray[i] = []byte(item[i])
-		totalBytes += len(serialized2DArray[i]) + 4
-	}
-	bytesOut := make([]byte, totalBytes)
-	offset := 0
-	for i := 0; i < numItems; i++ {
-		utf8len := len(serialized2DArray[i])
-		binary.LittleEndian.PutUint32(bytesOut[offset:], uint32(utf8len))
-		offset += 4
-		copy(bytesOut[offset:], serialized2DArray[i])
-		offset += utf8len
-	}
-	return bytesOut
-}
-
-func (h StringItemsSketchOp) DeserializeManyFromSlice(slc []byte, offset int, length int) []string {
-	if length == 0 {
-		return []string{}
-	}
-	array := make([]string, length)
-	offsetBytes := offset
-	for i := 0; i < length; i++ {
-		strLength := int(binary.LittleEndian.Uint32(slc[offsetBytes:]))
-		offsetBytes += 4
-		utf8Bytes := make([]byte, strLength)
-		copy(utf8Bytes, slc[offsetBytes:])
-		offsetBytes += strLength
-		array[i] = string(utf8Bytes)
-	}
-	return array
-}
-
 type StringPointerSketchOp struct {
 }
 
@@ -110,7 +64,7 @@ func (h IntItemsSketchOp) Hash(item int64) uint64 {
 }
 
 func (h IntItemsSketchOp) SerializeOneToSlice(item int64) []byte {
-	panic("not implemented")
+	return h.SerializeManyToSlice([]int64{item})
 }
 
 func (h IntItemsSketchOp) SerializeManyToSlice(item []int64) []byte {
@@ -127,8 +81,19 @@ func (h IntItemsSketchOp) SerializeManyToSlice(item []int64) []byte {
 }
 
 func (h IntItemsSketchOp) DeserializeManyFromSlice(slc []byte, offset int, length int) []int64 {
+	items, err := h.DeserializeManyFromSliceChecked(slc, offset, length)
+	if err != nil {
+		panic(err)
+	}
+	return items
+}
+
+func (h IntItemsSketchOp) DeserializeManyFromSliceChecked(slc []byte, offset int, length int) ([]int64, error) {
 	if length == 0 {
-		return []int64{}
+		return []int64{}, nil
+	}
+	if length > (len(slc)-offset)/8 {
+		return nil, errors.New("insufficient bytes for the items")
 	}
 	array := make([]int64, 0, length)
 	offsetBytes := offset
@@ -136,7 +101,7 @@ func (h IntItemsSketchOp) DeserializeManyFromSlice(slc []byte, offset int, lengt
 		array = append(array, int64(binary.LittleEndian.Uint64(slc[offsetBytes:])))
 		offsetBytes += 8
 	}
-	return array
+	return array, nil
 }
 
 func TestEmpty(t *testing.T) {
@@ -556,3 +521,177 @@ func BenchmarkItemSketch(b *testing.B) {
 		sketch.Update(int64(i))
 	}
 }
+
+func TestItemsSketchMarshalBinary(t *testing.T) {
+	sketch, err := NewItemsSketchWithMaxMapSize[string](1<<_LG_MIN_MAP_SIZE, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, sketch.UpdateMany(strconv.Itoa(i%10), int64(i)))
+	}
+	data, err := sketch.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, sketch.ToSlice(), data)
+
+	var buf bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(sketch))
+	decoded, err := NewItemsSketchWithMaxMapSize[string](1<<_LG_MIN_MAP_SIZE, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	assert.NoError(t, gob.NewDecoder(&buf).Decode(decoded))
+	assert.Equal(t, sketch.GetStreamLength(), decoded.GetStreamLength())
+	for i := 0; i < 10; i++ {
+		est1, err := sketch.GetEstimate(strconv.Itoa(i))
+		assert.NoError(t, err)
+		est2, err := decoded.GetEstimate(strconv.Itoa(i))
+		assert.NoError(t, err)
+		assert.Equal(t, est1, est2)
+	}
+
+	var noOp ItemsSketch[string]
+	assert.Error(t, noOp.UnmarshalBinary(data))
+}
+
+func TestItemsSketchWriteToReadFrom(t *testing.T) {
+	var sketches []*ItemsSketch[string]
+	for _, n := range []int{0, 10, 100000} {
+		sketch, err := NewItemsSketchWithMaxMapSize[string](1<<12, StringItemsSketchOp{})
+		assert.NoError(t, err)
+		for i := 0; i < n; i++ {
+			assert.NoError(t, sketch.UpdateMany(strconv.Itoa(i%5000), int64(1+i%7)))
+		}
+		sketches = append(sketches, sketch)
+	}
+
+	// the images are written one after another into a single compressed stream
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, sketch := range sketches {
+		written, err := sketch.WriteTo(zw)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(sketch.ToSlice())), written)
+	}
+	assert.NoError(t, zw.Close())
+
+	zr, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+	for _, sketch := range sketches {
+		decoded, err := NewItemsSketchWithMaxMapSize[string](1<<_LG_MIN_MAP_SIZE, StringItemsSketchOp{})
+		assert.NoError(t, err)
+		read, err := decoded.ReadFrom(zr)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(sketch.ToSlice())), read)
+		assert.Equal(t, sketch.GetStreamLength(), decoded.GetStreamLength())
+		assert.Equal(t, sketch.GetNumActiveItems(), decoded.GetNumActiveItems())
+		for i := 0; i < 5000; i += 7 {
+			est1, err := sketch.GetEstimate(strconv.Itoa(i))
+			assert.NoError(t, err)
+			est2, err := decoded.GetEstimate(strconv.Itoa(i))
+			assert.NoError(t, err)
+			assert.Equal(t, est1, est2)
+		}
+	}
+	decoded, err := NewItemsSketchWithMaxMapSize[string](1<<_LG_MIN_MAP_SIZE, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	read, err := decoded.ReadFrom(zr)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, int64(0), read)
+
+	slc := sketches[1].ToSlice()
+	for _, size := range []int{4, 16, len(slc) - 1} {
+		_, err = decoded.ReadFrom(bytes.NewReader(slc[:size]))
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	}
+
+	var noOp ItemsSketch[string]
+	_, err = noOp.ReadFrom(bytes.NewReader(nil))
+	assert.Error(t, err)
+}
+
+func TestItemsSketchReadFromBuffered(t *testing.T) {
+	// IntItemsSketchOp is not an ItemReader, its items are deserialized from the bytes buffered by a
+	// bufio.Reader, which are consumed up to the image
+	sketch, err := NewItemsSketchWithMaxMapSize[int64](1<<_LG_MIN_MAP_SIZE, IntItemsSketchOp{})
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, sketch.UpdateMany(int64(i%10), int64(1+i%3)))
+	}
+	slc := sketch.ToSlice()
+	tail := []byte("next image")
+	br := bufio.NewReader(io.MultiReader(bytes.NewReader(slc), bytes.NewReader(tail)))
+	decoded, err := NewItemsSketchWithMaxMapSize[int64](1<<_LG_MIN_MAP_SIZE, IntItemsSketchOp{})
+	assert.NoError(t, err)
+	read, err := decoded.ReadFrom(br)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(slc)), read)
+	assert.Equal(t, slc, decoded.ToSlice())
+	rest, err := io.ReadAll(br)
+	assert.NoError(t, err)
+	assert.Equal(t, tail, rest)
+
+	_, err = decoded.ReadFrom(bufio.NewReader(bytes.NewReader(slc[:len(slc)-1])))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	// without ItemReader the reader must be buffered
+	_, err = decoded.ReadFrom(bytes.NewReader(slc))
+	assert.Error(t, err)
+}
+
+// uncheckedIntOp only implements the methods of ItemSketchOp, not CheckedItemSketchOp.
+type uncheckedIntOp struct {
+	ItemSketchOp[int64]
+}
+
+func TestUncheckedItemSketchOp(t *testing.T) {
+	op := uncheckedIntOp{IntItemsSketchOp{}}
+	sketch, err := NewItemsSketchWithMaxMapSize[int64](1<<_LG_MIN_MAP_SIZE, op)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, sketch.UpdateMany(int64(i), int64(i+1)))
+	}
+	slc := sketch.ToSlice()
+	decoded, err := NewItemsSketchFromSlice[int64](slc, op)
+	assert.NoError(t, err)
+	assert.Equal(t, slc, decoded.ToSlice())
+
+	// the items cannot be sized on the buffered bytes without CheckedItemSketchOp
+	read, err := decoded.ReadFrom(bufio.NewReader(bytes.NewReader(slc)))
+	assert.Error(t, err)
+	assert.Equal(t, int64(0), read)
+}
+
+func TestInspectImage(t *testing.T) {
+	empty, err := NewLongsSketchWithMaxMapSize(1 << _LG_MIN_MAP_SIZE)
+	assert.NoError(t, err)
+	info, err := InspectImage(empty.ToSlice())
+	assert.NoError(t, err)
+	assert.True(t, info.Empty)
+	assert.False(t, info.FullPreamble)
+	assert.Equal(t, 8, info.PreambleBytes)
+
+	// the images of a LongsSketch and of an ItemsSketch of 4 byte strings have the same size
+	longs, err := NewLongsSketchWithMaxMapSize(1 << _LG_MIN_MAP_SIZE)
+	assert.NoError(t, err)
+	items, err := NewItemsSketchWithMaxMapSize[string](1<<_LG_MIN_MAP_SIZE, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, longs.UpdateMany(int64(i), int64(i+1)))
+		assert.NoError(t, items.UpdateMany("000"+strconv.Itoa(i), int64(i+1)))
+	}
+	longsSlc := longs.ToSlice()
+	itemsSlc := items.ToSlice()
+	assert.Equal(t, len(longsSlc), len(itemsSlc))
+	for _, slc := range [][]byte{longsSlc, itemsSlc} {
+		info, err = InspectImage(slc)
+		assert.NoError(t, err)
+		assert.True(t, info.FullPreamble)
+		assert.Equal(t, 4, info.ActiveItems)
+		assert.Equal(t, int64(10), info.StreamWeight)
+		assert.Equal(t, 32+4*8, info.ItemsOffsetBytes)
+	}
+
+	// counts that exceed the stream weight
+	binary.LittleEndian.PutUint64(itemsSlc[16:], 1)
+	info, err = InspectImage(itemsSlc)
+	assert.Error(t, err)
+	assert.Equal(t, 4, info.ActiveItems)
+	_, err = InspectImage(itemsSlc[:40])
+	assert.Error(t, err)
+}
@@ -18,9 +18,11 @@
 package frequencies
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math/bits"
 	"sort"
 	"strconv"
@@ -99,10 +101,53 @@ func NewLongsSketchWithMaxMapSize(maxMapSize int) (*LongsSketch, error) {
 //
 // slc is a byte slice representation of a sketch of this class.
 func NewLongsSketchFromSlice(slc []byte) (*LongsSketch, error) {
-	pre0, err := checkPreambleSize(slc)
+	if err := checkImageBytes(slc, 16); err != nil { //count + item
+		return nil, err
+	}
+	fls, activeItems, streamWeight, err := newLongsSketchFromPreamble(slc)
+	if err != nil || activeItems == 0 {
+		return fls, err
+	}
+	preBytes := internal.FamilyEnum.Frequency.MaxPreLongs << 3
+
+	// Get countArray
+	countArray := make([]int64, activeItems)
+	reqBytes := preBytes + 2*activeItems*8 //count Arr + Items Arr
+	if len(slc) < reqBytes {
+		return nil, fmt.Errorf("possible Corruption: Insufficient bytes in array: %d, %d", len(slc), reqBytes)
+	}
+	for i := 0; i < activeItems; i++ {
+		countArray[i] = int64(binary.LittleEndian.Uint64(slc[preBytes+(i<<3):]))
+	}
+	if err := checkCounts(countArray, streamWeight); err != nil {
+		return nil, err
+	}
+
+	// Get itemArray
+	itemsOffset := preBytes + (8 * activeItems)
+	itemArray := make([]int64, activeItems)
+	for i := 0; i < activeItems; i++ {
+		itemArray[i] = int64(binary.LittleEndian.Uint64(slc[itemsOffset+(i<<3):]))
+	}
+
+	// UpdateMany the sketch
+	for i := 0; i < activeItems && err == nil; i++ {
+		err = fls.UpdateMany(itemArray[i], countArray[i])
+	}
 	if err != nil {
 		return nil, err
 	}
+	fls.streamWeight = streamWeight //override streamWeight due to updating
+	return fls, nil
+}
+
+// newLongsSketchFromPreamble checks the preamble of a serialized image and returns the sketch to be
+// updated with the active items of the image, their number and the stream weight of the image.
+func newLongsSketchFromPreamble(slc []byte) (*LongsSketch, int, int64, error) {
+	pre0, err := checkPreambleSize(slc)
+	if err != nil {
+		return nil, 0, 0, err
+	}
 	maxPreLongs := internal.FamilyEnum.Frequency.MaxPreLongs
 	preLongs := extractPreLongs(pre0)
 	serVer := extractSerVer(pre0)
@@ -115,62 +160,45 @@ func NewLongsSketchFromSlice(slc []byte) (*LongsSketch, error) {
 	preLongsEq1 := preLongs == 1
 	preLongsEqMax := preLongs == maxPreLongs
 	if !preLongsEq1 && !preLongsEqMax {
-		return nil, fmt.Errorf("possible Corruption: PreLongs must be 1 or %d: %d", maxPreLongs, preLongs)
+		return nil, 0, 0, fmt.Errorf("possible Corruption: PreLongs must be 1 or %d: %d", maxPreLongs, preLongs)
 	}
 	if serVer != _SER_VER {
-		return nil, fmt.Errorf("possible Corruption: Ser Ver must be %d: %d", _SER_VER, serVer)
+		return nil, 0, 0, fmt.Errorf("possible Corruption: Ser Ver must be %d: %d", _SER_VER, serVer)
 	}
 	actFamID := internal.FamilyEnum.Frequency.Id
 	if familyID != actFamID {
-		return nil, fmt.Errorf("possible Corruption: FamilyID must be %d: %d", actFamID, familyID)
+		return nil, 0, 0, fmt.Errorf("possible Corruption: FamilyID must be %d: %d", actFamID, familyID)
 	}
 	if empty && !preLongsEq1 {
-		return nil, fmt.Errorf("possible Corruption: Empty Flag set incorrectly: %t", preLongsEq1)
+		return nil, 0, 0, fmt.Errorf("possible Corruption: Empty Flag set incorrectly: %t", preLongsEq1)
+	}
+	if !empty && !preLongsEqMax {
+		return nil, 0, 0, fmt.Errorf("possible Corruption: Empty Flag not set with PreLongs: %d", preLongs)
+	}
+	if lgMaxMapSize > _LG_MAX_MAP_SIZE {
+		return nil, 0, 0, fmt.Errorf("possible Corruption: lgMaxMapSize must be <= %d: %d", _LG_MAX_MAP_SIZE, lgMaxMapSize)
 	}
 	if empty {
-		return NewLongsSketch(lgMaxMapSize, _LG_MIN_MAP_SIZE)
+		fls, err := NewLongsSketch(lgMaxMapSize, _LG_MIN_MAP_SIZE)
+		return fls, 0, 0, err
 	}
 	// get full preamble
 	preArr := make([]int64, preLongs)
 	for i := 0; i < preLongs; i++ {
 		preArr[i] = int64(binary.LittleEndian.Uint64(slc[i<<3:]))
 	}
+	activeItems := extractActiveItems(preArr[1])
+	lgCurMapSize, err = checkMapSizes(lgMaxMapSize, lgCurMapSize, activeItems)
+	if err != nil {
+		return nil, 0, 0, err
+	}
 	fls, err := NewLongsSketch(lgMaxMapSize, lgCurMapSize)
 	if err != nil {
-		return nil, err
+		return nil, 0, 0, err
 	}
 	fls.streamWeight = 0 //update after
 	fls.offset = preArr[3]
-
-	preBytes := preLongs << 3
-	activeItems := extractActiveItems(preArr[1])
-
-	// Get countArray
-	countArray := make([]int64, activeItems)
-	reqBytes := preBytes + 2*activeItems*8 //count Arr + Items Arr
-	if len(slc) < reqBytes {
-		return nil, fmt.Errorf("possible Corruption: Insufficient bytes in array: %d, %d", len(slc), reqBytes)
-	}
-	for i := 0; i < activeItems; i++ {
-		countArray[i] = int64(binary.LittleEndian.Uint64(slc[preBytes+(i<<3):]))
-	}
-
-	// Get itemArray
-	itemsOffset := preBytes + (8 * activeItems)
-	itemArray := make([]int64, activeItems)
-	for i := 0; i < activeItems; i++ {
-		itemArray[i] = int64(binary.LittleEndian.Uint64(slc[itemsOffset+(i<<3):]))
-	}
-
-	// UpdateMany the sketch
-	for i := 0; i < activeItems && err == nil; i++ {
-		err = fls.UpdateMany(itemArray[i], countArray[i])
-	}
-	if err != nil {
-		return nil, err
-	}
-	fls.streamWeight = preArr[2] //override streamWeight due to updating
-	return fls, nil
+	return fls, activeItems, preArr[2], nil
 }
 
 // NewLongsSketchFromString returns a sketch instance of this class from the given string,
@@ -486,52 +514,90 @@ func (s *LongsSketch) ToString() (string, error) {
 
 // ToSlice returns a slice representation of this sketch
 func (s *LongsSketch) ToSlice() []byte {
-	empty := s.IsEmpty()
-	activeItems := s.GetNumActiveItems()
-	preLongs := 1
-	outBytes := 8
-	if !empty {
-		preLongs = internal.FamilyEnum.Frequency.MaxPreLongs //4
-		outBytes = (preLongs + (2 * activeItems)) << 3       //2 because both keys and values are longs
-	}
-	outArr := make([]byte, outBytes)
-
-	//build first preLong empty or not
-	pre0 := int64(0)
-	pre0 = insertPreLongs(int64(preLongs), pre0)                         //Byte 0
-	pre0 = insertSerVer(_SER_VER, pre0)                                  //Byte 1
-	pre0 = insertFamilyID(int64(internal.FamilyEnum.Frequency.Id), pre0) //Byte 2
-	pre0 = insertLgMaxMapSize(int64(s.lgMaxMapSize), pre0)               //Byte 3
-	pre0 = insertLgCurMapSize(int64(s.hashMap.lgLength), pre0)           //Byte 4
-	if empty {
-		pre0 = insertFlags(_EMPTY_FLAG_MASK, pre0) //Byte 5
-		binary.LittleEndian.PutUint64(outArr, uint64(pre0))
-		return outArr
-	}
-	pre := int64(0)
-	pre0 = insertFlags(0, pre0) //Byte 5
-	preArr := make([]int64, preLongs)
-	preArr[0] = pre0
-	preArr[1] = insertActiveItems(int64(activeItems), pre)
-	preArr[2] = s.streamWeight
-	preArr[3] = s.offset
+	var buf bytes.Buffer
+	buf.Grow(s.GetStorageBytes())
+	s.WriteTo(&buf) // a bytes.Buffer never fails to write
+	return buf.Bytes()
+}
 
-	for i := 0; i < preLongs; i++ {
-		binary.LittleEndian.PutUint64(outArr[i<<3:], uint64(preArr[i]))
-	}
+// WriteTo implements io.WriterTo, it writes the image of ToSlice to w without building it in memory:
+// the preamble is written first, then the counts and the items, by chunks of internal.StreamChunkItems
+// longs.
+func (s *LongsSketch) WriteTo(w io.Writer) (int64, error) {
+	cw := internal.NewCountingWriter(w)
+	writePreamble(cw, s.IsEmpty(), s.lgMaxMapSize, s.hashMap.lgLength, s.GetNumActiveItems(), s.streamWeight, s.offset)
+	if !s.IsEmpty() {
+		writeActiveLongs(cw, s.hashMap.values, s.hashMap.states)
+		writeActiveLongs(cw, s.hashMap.keys, s.hashMap.states)
+	}
+	return cw.Count(), cw.Err()
+}
 
-	preBytes := preLongs << 3
-	activeValues := s.hashMap.getActiveValues()
-	for i := 0; i < activeItems; i++ {
-		binary.LittleEndian.PutUint64(outArr[preBytes+(i<<3):], uint64(activeValues[i]))
+// ReadFrom implements io.ReaderFrom, it replaces the content of the sketch with the image read
+// from r, see NewLongsSketchFromSlice.
+// Unlike a plain io.ReaderFrom, it reads exactly one image and leaves the rest of r unread, so that
+// consecutive images can be read from the same stream, io.EOF is returned if r is already at its end.
+// Only the counts are buffered, the items are read by chunks and applied to the sketch.
+func (s *LongsSketch) ReadFrom(r io.Reader) (int64, error) {
+	cr := internal.NewCountingReader(r)
+	preArr, err := readPreamble(cr)
+	if err != nil {
+		return cr.Count(), err
+	}
+	fls, activeItems, streamWeight, err := newLongsSketchFromPreamble(preArr)
+	if err != nil {
+		return cr.Count(), err
+	}
+	countArray := make([]int64, activeItems)
+	if err := readLongs(cr, countArray); err != nil {
+		return cr.Count(), err
+	}
+	if err := checkCounts(countArray, streamWeight); err != nil {
+		return cr.Count(), err
+	}
+	itemArray := make([]int64, min(activeItems, internal.StreamChunkItems))
+	for i := 0; i < activeItems; i += len(itemArray) {
+		chunk := itemArray[:min(activeItems-i, len(itemArray))]
+		if err := readLongs(cr, chunk); err != nil {
+			return cr.Count(), err
+		}
+		for j, item := range chunk {
+			if err := fls.UpdateMany(item, countArray[i+j]); err != nil {
+				return cr.Count(), err
+			}
+		}
+	}
+	if activeItems > 0 {
+		fls.streamWeight = streamWeight //override streamWeight due to updating
 	}
+	*s = *fls
+	return cr.Count(), nil
+}
 
-	activeKeys := s.hashMap.getActiveKeys()
-	for i := 0; i < activeItems; i++ {
-		binary.LittleEndian.PutUint64(outArr[preBytes+((activeItems+i)<<3):], uint64(activeKeys[i]))
+// MarshalBinary implements encoding.BinaryMarshaler, it returns the image of ToSlice.
+func (s *LongsSketch) MarshalBinary() ([]byte, error) {
+	return s.ToSlice(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, it replaces the content of the sketch with
+// the given image, see NewLongsSketchFromSlice.
+func (s *LongsSketch) UnmarshalBinary(data []byte) error {
+	sketch, err := NewLongsSketchFromSlice(data)
+	if err != nil {
+		return err
 	}
+	*s = *sketch
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder, see MarshalBinary.
+func (s *LongsSketch) GobEncode() ([]byte, error) {
+	return s.MarshalBinary()
+}
 
-	return outArr
+// GobDecode implements gob.GobDecoder, see UnmarshalBinary.
+func (s *LongsSketch) GobDecode(data []byte) error {
+	return s.UnmarshalBinary(data)
 }
 
 // Reset resets this sketch to a virgin state.
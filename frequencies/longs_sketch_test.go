@@ -18,8 +18,12 @@
 package frequencies
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/binary"
+	"encoding/gob"
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 
@@ -595,3 +599,81 @@ func BenchmarkLongSketch(b *testing.B) {
 		sketch.Update(int64(i))
 	}
 }
+
+func TestLongsSketchMarshalBinary(t *testing.T) {
+	sketch, err := NewLongsSketchWithMaxMapSize(1 << _LG_MIN_MAP_SIZE)
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, sketch.UpdateMany(int64(i%10), int64(i)))
+	}
+	data, err := sketch.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, sketch.ToSlice(), data)
+
+	var buf bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(sketch))
+	var decoded LongsSketch
+	assert.NoError(t, gob.NewDecoder(&buf).Decode(&decoded))
+	assert.Equal(t, sketch.GetStreamLength(), decoded.GetStreamLength())
+	assert.Equal(t, sketch.GetNumActiveItems(), decoded.GetNumActiveItems())
+	for i := int64(0); i < 10; i++ {
+		est1, err := sketch.GetEstimate(i)
+		assert.NoError(t, err)
+		est2, err := decoded.GetEstimate(i)
+		assert.NoError(t, err)
+		assert.Equal(t, est1, est2)
+	}
+
+	assert.Error(t, decoded.UnmarshalBinary([]byte{1, 2}))
+}
+
+func TestLongsSketchWriteToReadFrom(t *testing.T) {
+	var sketches []*LongsSketch
+	for _, n := range []int{0, 10, 100000} {
+		sketch, err := NewLongsSketchWithMaxMapSize(1 << 12)
+		assert.NoError(t, err)
+		for i := 0; i < n; i++ {
+			assert.NoError(t, sketch.UpdateMany(int64(i%5000), int64(1+i%7)))
+		}
+		sketches = append(sketches, sketch)
+	}
+
+	// the images are written one after another into a single compressed stream
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, sketch := range sketches {
+		written, err := sketch.WriteTo(zw)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(sketch.ToSlice())), written)
+	}
+	assert.NoError(t, zw.Close())
+
+	zr, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+	for _, sketch := range sketches {
+		var decoded LongsSketch
+		read, err := decoded.ReadFrom(zr)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(sketch.ToSlice())), read)
+		assert.Equal(t, sketch.GetStreamLength(), decoded.GetStreamLength())
+		assert.Equal(t, sketch.GetMaximumError(), decoded.GetMaximumError())
+		assert.Equal(t, sketch.GetNumActiveItems(), decoded.GetNumActiveItems())
+		for i := int64(0); i < 5000; i += 7 {
+			est1, err := sketch.GetEstimate(i)
+			assert.NoError(t, err)
+			est2, err := decoded.GetEstimate(i)
+			assert.NoError(t, err)
+			assert.Equal(t, est1, est2)
+		}
+	}
+	var decoded LongsSketch
+	read, err := decoded.ReadFrom(zr)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, int64(0), read)
+
+	slc := sketches[1].ToSlice()
+	for _, size := range []int{4, 16, len(slc) - 1} {
+		_, err = decoded.ReadFrom(bytes.NewReader(slc[:size]))
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	}
+}
@@ -20,6 +20,9 @@ package frequencies
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
+
+	"github.com/apache/datasketches-go/internal"
 )
 
 const (
@@ -132,3 +135,157 @@ func extractActiveItems(pre1 int64) int {
 	mask := int64(0xFFFFFFFF)
 	return int(pre1 & mask)
 }
+
+// writePreamble writes the preamble of a serialized image, a single long for an empty sketch,
+// internal.FamilyEnum.Frequency.MaxPreLongs longs otherwise.
+func writePreamble(cw *internal.CountingWriter, empty bool, lgMaxMapSize int, lgCurMapSize int, activeItems int, streamWeight int64, offset int64) {
+	preLongs := 1
+	if !empty {
+		preLongs = internal.FamilyEnum.Frequency.MaxPreLongs
+	}
+	pre0 := int64(0)
+	pre0 = insertPreLongs(int64(preLongs), pre0)                         //Byte 0
+	pre0 = insertSerVer(_SER_VER, pre0)                                  //Byte 1
+	pre0 = insertFamilyID(int64(internal.FamilyEnum.Frequency.Id), pre0) //Byte 2
+	pre0 = insertLgMaxMapSize(int64(lgMaxMapSize), pre0)                 //Byte 3
+	pre0 = insertLgCurMapSize(int64(lgCurMapSize), pre0)                 //Byte 4
+	if empty {
+		pre0 = insertFlags(_EMPTY_FLAG_MASK, pre0) //Byte 5
+	} else {
+		pre0 = insertFlags(0, pre0) //Byte 5
+	}
+	preArr := make([]byte, preLongs<<3)
+	binary.LittleEndian.PutUint64(preArr, uint64(pre0))
+	if !empty {
+		binary.LittleEndian.PutUint64(preArr[8:], uint64(insertActiveItems(int64(activeItems), 0)))
+		binary.LittleEndian.PutUint64(preArr[16:], uint64(streamWeight))
+		binary.LittleEndian.PutUint64(preArr[24:], uint64(offset))
+	}
+	cw.Write(preArr)
+}
+
+// readPreamble reads the preamble of a serialized image, the first long and, if the image is not
+// empty, the remaining longs, and returns it as a slice, checked with checkPreambleSize.
+func readPreamble(cr *internal.CountingReader) ([]byte, error) {
+	maxPreLongs := internal.FamilyEnum.Frequency.MaxPreLongs
+	preArr := make([]byte, maxPreLongs<<3)
+	if err := cr.ReadFull(preArr[:8]); err != nil {
+		return nil, err
+	}
+	preLongs := extractPreLongs(int64(binary.LittleEndian.Uint64(preArr)))
+	if preLongs != maxPreLongs {
+		// an invalid number of preamble longs is reported by the deserializer
+		return preArr[:8], nil
+	}
+	if err := cr.ReadFull(preArr[8:]); err != nil {
+		return nil, err
+	}
+	return preArr, nil
+}
+
+// checkImageBytes checks, before any allocation, that a full image holds activeItemBytes for each of
+// the active items of its preamble. Other inconsistencies of the preamble are reported by the
+// deserializer.
+func checkImageBytes(slc []byte, activeItemBytes int) error {
+	maxPreLongs := internal.FamilyEnum.Frequency.MaxPreLongs
+	preBytes := maxPreLongs << 3
+	if len(slc) < preBytes || extractPreLongs(int64(binary.LittleEndian.Uint64(slc))) != maxPreLongs {
+		return nil
+	}
+	activeItems := extractActiveItems(int64(binary.LittleEndian.Uint64(slc[8:])))
+	reqBytes := preBytes + activeItems*activeItemBytes
+	if len(slc) < reqBytes {
+		return fmt.Errorf("possible corruption: insufficient bytes in array: %d, %d", len(slc), reqBytes)
+	}
+	return nil
+}
+
+// writeActiveLongs writes the entries of arr which are active in states, in index order, by chunks
+// of internal.StreamChunkItems longs.
+func writeActiveLongs(cw *internal.CountingWriter, arr []int64, states []int16) {
+	buf := make([]byte, 0, internal.StreamChunkItems<<3)
+	for i := 0; i < len(arr) && cw.Err() == nil; i++ {
+		if states[i] > 0 { //isActive
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(arr[i]))
+			if len(buf) == cap(buf) {
+				cw.Write(buf)
+				buf = buf[:0]
+			}
+		}
+	}
+	if len(buf) > 0 {
+		cw.Write(buf)
+	}
+}
+
+// readLongs fills arr with little endian longs read by chunks of internal.StreamChunkItems longs.
+func readLongs(cr *internal.CountingReader, arr []int64) error {
+	buf := make([]byte, min(len(arr), internal.StreamChunkItems)<<3)
+	for i := 0; i < len(arr); i += internal.StreamChunkItems {
+		chunk := buf[:min(len(arr)-i, internal.StreamChunkItems)<<3]
+		if err := cr.ReadFull(chunk); err != nil {
+			return err
+		}
+		for j := 0; j < len(chunk)>>3; j++ {
+			arr[i+j] = int64(binary.LittleEndian.Uint64(chunk[j<<3:]))
+		}
+	}
+	return nil
+}
+
+// ImageInfo is the preamble of a serialized image, see InspectImage.
+type ImageInfo struct {
+	// PreambleBytes is the size in bytes of the preamble announced by its first byte.
+	PreambleBytes int
+	Flags         int
+	Empty         bool
+	LgMaxMapSize  int
+	LgCurMapSize  int
+	// FullPreamble is true if ActiveItems, StreamWeight and Offset were read from a full preamble.
+	FullPreamble bool
+	ActiveItems  int
+	StreamWeight int64
+	Offset       int64
+	// ItemsOffsetBytes is the offset of the serialized items, which follow the counts of the active items.
+	ItemsOffsetBytes int
+}
+
+// InspectImage decodes and validates the preamble of a serialized image and the counts of its active
+// items. The image does not record whether it was serialized by a LongsSketch or by an ItemsSketch,
+// the items starting at ItemsOffsetBytes are checked by NewLongsSketchFromSlice or by
+// NewItemsSketchFromSlice with the ItemSketchOp of the sketch. The fields decoded before the image was
+// found invalid are returned along with the error.
+func InspectImage(image []byte) (ImageInfo, error) {
+	pre0, err := checkPreambleSize(image)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	flags := extractFlags(pre0)
+	info := ImageInfo{
+		PreambleBytes: extractPreLongs(pre0) << 3,
+		Flags:         flags,
+		Empty:         flags&_EMPTY_FLAG_MASK != 0,
+		LgMaxMapSize:  extractLgMaxMapSize(pre0),
+		LgCurMapSize:  extractLgCurMapSize(pre0),
+	}
+	maxPreLongs := internal.FamilyEnum.Frequency.MaxPreLongs
+	if info.PreambleBytes == maxPreLongs<<3 {
+		info.FullPreamble = true
+		info.ActiveItems = extractActiveItems(int64(binary.LittleEndian.Uint64(image[8:])))
+		info.StreamWeight = int64(binary.LittleEndian.Uint64(image[16:]))
+		info.Offset = int64(binary.LittleEndian.Uint64(image[24:]))
+		info.ItemsOffsetBytes = info.PreambleBytes + info.ActiveItems<<3
+	}
+	if err := checkImageBytes(image, 8); err != nil { // counts only
+		return info, err
+	}
+	// the preamble is checked the same way for both sketches
+	if _, _, _, err := newLongsSketchFromPreamble(image); err != nil {
+		return info, err
+	}
+	counts := make([]int64, info.ActiveItems)
+	for i := range counts {
+		counts[i] = int64(binary.LittleEndian.Uint64(image[info.PreambleBytes+i<<3:]))
+	}
+	return info, checkCounts(counts, info.StreamWeight)
+}
@@ -18,7 +18,9 @@
 package frequencies
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"testing"
@@ -142,7 +144,7 @@ func TestJavaCompat(t *testing.T) {
 			bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_long_n%d_java.sk", internal.JavaPath, n))
 			assert.NoError(t, err)
 			sketch, err := NewLongsSketchFromSlice(bytes)
-			if err != nil {
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -166,7 +168,7 @@ func TestJavaCompat(t *testing.T) {
 			bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_string_n%d_java.sk", internal.JavaPath, n))
 			assert.NoError(t, err)
 			sketch, err := NewItemsSketchFromSlice[string](bytes, StringItemsSketchOp{})
-			if err != nil {
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -188,7 +190,7 @@ func TestJavaCompat(t *testing.T) {
 		bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_string_utf8_java.sk", internal.JavaPath))
 		assert.NoError(t, err)
 		sketch, err := NewItemsSketchFromSlice[string](bytes, StringItemsSketchOp{})
-		if err != nil {
+		if !assert.NoError(t, err) {
 			return
 		}
 		assert.False(t, sketch.IsEmpty())
@@ -221,7 +223,7 @@ func TestJavaCompat(t *testing.T) {
 		bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_string_ascii_java.sk", internal.JavaPath))
 		assert.NoError(t, err)
 		sketch, err := NewItemsSketchFromSlice[string](bytes, StringItemsSketchOp{})
-		if err != nil {
+		if !assert.NoError(t, err) {
 			return
 		}
 		assert.False(t, sketch.IsEmpty())
@@ -249,7 +251,7 @@ func TestCppCompat(t *testing.T) {
 			bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_long_n%d_cpp.sk", internal.CppPath, n))
 			assert.NoError(t, err)
 			sketch, err := NewLongsSketchFromSlice(bytes)
-			if err != nil {
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -273,7 +275,7 @@ func TestCppCompat(t *testing.T) {
 			bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_string_n%d_cpp.sk", internal.CppPath, n))
 			assert.NoError(t, err)
 			sketch, err := NewItemsSketchFromSlice[string](bytes, StringItemsSketchOp{})
-			if err != nil {
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -295,7 +297,7 @@ func TestCppCompat(t *testing.T) {
 		bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_string_utf8_cpp.sk", internal.CppPath))
 		assert.NoError(t, err)
 		sketch, err := NewItemsSketchFromSlice[string](bytes, StringItemsSketchOp{})
-		if err != nil {
+		if !assert.NoError(t, err) {
 			return
 		}
 		assert.False(t, sketch.IsEmpty())
@@ -328,7 +330,7 @@ func TestCppCompat(t *testing.T) {
 		bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_string_ascii_cpp.sk", internal.CppPath))
 		assert.NoError(t, err)
 		sketch, err := NewItemsSketchFromSlice[string](bytes, StringItemsSketchOp{})
-		if err != nil {
+		if !assert.NoError(t, err) {
 			return
 		}
 		assert.False(t, sketch.IsEmpty())
@@ -348,3 +350,142 @@ func TestCppCompat(t *testing.T) {
 		assert.Equal(t, est, int64(4))
 	})
 }
+
+// addLongsFuzzSeeds adds the Java and C++ long images to the corpus.
+func addLongsFuzzSeeds(f *testing.F) {
+	for _, n := range []int{0, 1, 10, 100, 1000} {
+		bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_long_n%d_java.sk", internal.JavaPath, n))
+		assert.NoError(f, err)
+		f.Add(bytes)
+		bytes, err = os.ReadFile(fmt.Sprintf("%s/frequent_long_n%d_cpp.sk", internal.CppPath, n))
+		assert.NoError(f, err)
+		f.Add(bytes)
+	}
+}
+
+func FuzzLongsSketchFromSlice(f *testing.F) {
+	addLongsFuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		sketch, err := NewLongsSketchFromSlice(b)
+		if err != nil {
+			return
+		}
+		if _, err := sketch.GetFrequentItems(ErrorTypeEnum.NoFalseNegatives); err != nil {
+			t.Fatal(err)
+		}
+		other, err := NewLongsSketchWithMaxMapSize(64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := other.Merge(sketch); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewLongsSketchFromSlice(sketch.ToSlice()); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// addItemsFuzzSeeds adds the Java and C++ string images to the corpus.
+func addItemsFuzzSeeds(f *testing.F) {
+	for _, n := range []int{0, 1, 10, 100, 1000} {
+		bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_string_n%d_java.sk", internal.JavaPath, n))
+		assert.NoError(f, err)
+		f.Add(bytes)
+		bytes, err = os.ReadFile(fmt.Sprintf("%s/frequent_string_n%d_cpp.sk", internal.CppPath, n))
+		assert.NoError(f, err)
+		f.Add(bytes)
+	}
+	for _, name := range []string{"utf8", "ascii"} {
+		bytes, err := os.ReadFile(fmt.Sprintf("%s/frequent_string_%s_java.sk", internal.JavaPath, name))
+		assert.NoError(f, err)
+		f.Add(bytes)
+	}
+}
+
+func FuzzItemsSketchFromSlice(f *testing.F) {
+	addItemsFuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		sketch, err := NewItemsSketchFromSlice[string](b, StringItemsSketchOp{})
+		if err != nil {
+			return
+		}
+		if _, err := sketch.GetFrequentItems(ErrorTypeEnum.NoFalseNegatives); err != nil {
+			t.Fatal(err)
+		}
+		other, err := NewItemsSketchWithMaxMapSize[string](64, StringItemsSketchOp{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := other.Merge(sketch); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewItemsSketchFromSlice[string](sketch.ToSlice(), StringItemsSketchOp{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzLongsSketchReadFrom(f *testing.F) {
+	addLongsFuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		sketch, err := NewLongsSketchWithMaxMapSize(64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkReadFrom(t, b, sketch.ReadFrom, func(image []byte) ([]byte, error) {
+			decoded, err := NewLongsSketchFromSlice(image)
+			if err != nil {
+				return nil, err
+			}
+			return decoded.ToSlice(), nil
+		}, sketch.ToSlice)
+	})
+}
+
+func FuzzItemsSketchReadFrom(f *testing.F) {
+	addItemsFuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		sketch, err := NewItemsSketchWithMaxMapSize[string](64, StringItemsSketchOp{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkReadFrom(t, b, sketch.ReadFrom, func(image []byte) ([]byte, error) {
+			decoded, err := NewItemsSketchFromSlice[string](image, StringItemsSketchOp{})
+			if err != nil {
+				return nil, err
+			}
+			return decoded.ToSlice(), nil
+		}, sketch.ToSlice)
+	})
+}
+
+// checkReadFrom checks that readFrom consumes exactly the bytes it reports, fails if and only if the
+// slice constructor rejects b, and otherwise decodes the image the slice constructor decodes from the
+// bytes it read. fromSlice and toSlice return the images of the sketch decoded by the slice
+// constructor and by readFrom.
+func checkReadFrom(t *testing.T, b []byte, readFrom func(r io.Reader) (int64, error),
+	fromSlice func(image []byte) ([]byte, error), toSlice func() []byte) {
+	r := bytes.NewReader(b)
+	n, err := readFrom(r)
+	if n != int64(len(b)-r.Len()) {
+		t.Fatalf("read %d bytes, reported %d", len(b)-r.Len(), n)
+	}
+	if err != nil {
+		if _, sliceErr := fromSlice(b); sliceErr == nil {
+			t.Fatalf("ReadFrom rejected an image accepted by the slice constructor: %v", err)
+		}
+		return
+	}
+	want, err := fromSlice(b[:n])
+	if err != nil {
+		t.Fatalf("ReadFrom accepted an image rejected by the slice constructor: %v", err)
+	}
+	if got := toSlice(); !bytes.Equal(want, got) {
+		t.Fatalf("ReadFrom decoded %x, the slice constructor %x", got, want)
+	}
+}
@@ -18,6 +18,7 @@
 package frequencies
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
 )
@@ -26,6 +27,9 @@ const (
 	// _LG_MIN_MAP_SIZE constant controle the size of the initial data structure for the
 	// frequencies sketches and its value is somewhat arbitrary.
 	_LG_MIN_MAP_SIZE = 3
+	// _LG_MAX_MAP_SIZE is the largest lgMaxMapSize of a serialized sketch, the max map size of the
+	// Java sketches is an int.
+	_LG_MAX_MAP_SIZE = 30
 	// _SAMPLE_SIZE constant is large enough so that computing the median of SAMPLE_SIZE
 	// randomly selected entries from a list of numbers and outputting
 	// the empirical median will give a constant-factor approximation to the
@@ -54,6 +58,38 @@ var ErrorTypeEnum = &errorTypes{
 	},
 }
 
+// checkMapSizes checks the map sizes and the number of active items read from the preamble of a
+// non-empty image. It returns the lgCurMapSize to build the sketch with, which is the one of the
+// image capped to the size needed by its active items so that a corrupt image cannot cause a large
+// allocation, the map grows back as needed.
+func checkMapSizes(lgMaxMapSize int, lgCurMapSize int, activeItems int) (int, error) {
+	if lgMaxMapSize > _LG_MAX_MAP_SIZE || lgCurMapSize > lgMaxMapSize {
+		return 0, fmt.Errorf("possible corruption: invalid map sizes: %d, %d", lgMaxMapSize, lgCurMapSize)
+	}
+	maxActiveItems := int(float64(uint64(1<<max(lgMaxMapSize, _LG_MIN_MAP_SIZE))) * reversePurgeLongHashMapLoadFactor)
+	if activeItems > maxActiveItems {
+		return 0, fmt.Errorf("possible corruption: too many active items: %d", activeItems)
+	}
+	lgMapSize := _LG_MIN_MAP_SIZE
+	for int(float64(uint64(1<<lgMapSize))*reversePurgeLongHashMapLoadFactor) < activeItems {
+		lgMapSize++
+	}
+	return min(lgCurMapSize, lgMapSize), nil
+}
+
+// checkCounts checks that the counts read from an image are positive and add up to at most its
+// stream weight, which also prevents them from overflowing once merged into a sketch.
+func checkCounts(counts []int64, streamWeight int64) error {
+	remaining := streamWeight
+	for _, count := range counts {
+		if count <= 0 || count > remaining {
+			return fmt.Errorf("possible corruption: invalid count %d for a stream weight of %d", count, streamWeight)
+		}
+		remaining -= count
+	}
+	return nil
+}
+
 // hashFn returns an index into the hashFn table.
 // This hashFn function is taken from the internals of Austin Appleby's MurmurHash3 algorithm.
 // It is also used by the Trove for Java libraries.
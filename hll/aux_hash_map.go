@@ -53,7 +53,13 @@ func deserializeAuxHashMap(byteArray []byte, offset int, lgConfigL int, auxCount
 		lgAuxArrInts int
 	)
 
+	if auxCount > 1<<lgConfigL {
+		return nil, fmt.Errorf("possible Corruption: Invalid Aux Count: %d", auxCount)
+	}
 	if srcCompact {
+		if len(byteArray) < offset+(auxCount<<2) {
+			return nil, fmt.Errorf("input array too small: %d", len(byteArray))
+		}
 		v, err := computeLgArr(byteArray, auxCount, lgConfigL)
 		if err != nil {
 			return nil, err
@@ -61,6 +67,12 @@ func deserializeAuxHashMap(byteArray []byte, offset int, lgConfigL int, auxCount
 		lgAuxArrInts = v
 	} else {
 		lgAuxArrInts = extractLgArr(byteArray)
+		if lgAuxArrInts >= lgConfigL {
+			return nil, fmt.Errorf("possible Corruption: Invalid Aux Array Size: %d", lgAuxArrInts)
+		}
+		if len(byteArray) < offset+(4<<lgAuxArrInts) {
+			return nil, fmt.Errorf("input array too small: %d", len(byteArray))
+		}
 	}
 
 	auxMap := newAuxHashMap(lgAuxArrInts, lgConfigL)
@@ -0,0 +1,260 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hll
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/twmb/murmur3"
+)
+
+const (
+	defaultMaxConcurrencyError = 0.01
+	defaultLocalBufferSize     = 256
+)
+
+// WithMaxConcurrencyError sets the maximum relative error of the estimate of a ConcurrentSketch
+// that is due to the items still buffered by its local sketches. Defaults to 0.01.
+// It is only used by NewConcurrentSketch.
+func WithMaxConcurrencyError(maxConcurrencyError float64) SketchOption {
+	return func(o *sketchOptions) {
+		o.maxConcurrencyError = maxConcurrencyError
+	}
+}
+
+// WithLocalBufferSize sets the maximum number of items buffered by a local sketch of a
+// ConcurrentSketch before they are propagated. Defaults to 256.
+// It is only used by NewConcurrentSketch.
+func WithLocalBufferSize(localBufferSize int) SketchOption {
+	return func(o *sketchOptions) {
+		o.localBufferSize = localBufferSize
+	}
+}
+
+// ConcurrentSketch is an HLL sketch that can be updated from many goroutines at once, following the
+// design of the concurrent theta sketch of the Java library.
+//
+// Each writing goroutine updates its own ConcurrentLocalSketch, obtained with NewLocal, which hashes
+// the items and buffers their coupons. Buffers are propagated into a shared Union-backed gadget when
+// they are full. The buffer size is bounded so that the items not yet propagated account for at most
+// the configured maximum concurrency error of the estimate: while the estimate is small, every update
+// is propagated eagerly.
+//
+// All the methods of ConcurrentSketch are safe for concurrent use.
+type ConcurrentSketch struct {
+	mu     sync.Mutex
+	gadget Union
+
+	seed                uint64
+	maxConcurrencyError float64
+	localBufferSize     int
+
+	numLocals atomic.Int64
+	estimate  atomic.Uint64 // float64 bits of the estimate of the gadget
+}
+
+// ConcurrentLocalSketch is the goroutine-local buffer of a ConcurrentSketch.
+// It is not safe for concurrent use, each writing goroutine must have its own, and close it with
+// Close once it is done updating it.
+type ConcurrentLocalSketch struct {
+	shared  *ConcurrentSketch
+	coupons []int
+	scratch [8]byte
+	closed  bool
+}
+
+// NewConcurrentSketch constructs a new concurrent sketch.
+//
+//   - lgConfigK, the Log2 of K for the sketch. This value must be between 4 and 21 inclusively.
+//   - opts, optional parameters such as WithUpdateSeed, WithMaxConcurrencyError and WithLocalBufferSize.
+func NewConcurrentSketch(lgConfigK int, opts ...SketchOption) (*ConcurrentSketch, error) {
+	options := sketchOptions{
+		maxConcurrencyError: defaultMaxConcurrencyError,
+		localBufferSize:     defaultLocalBufferSize,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.maxConcurrencyError < 0 || options.maxConcurrencyError >= 1 {
+		return nil, fmt.Errorf("maxConcurrencyError must be >= 0 and < 1: %f", options.maxConcurrencyError)
+	}
+	if options.localBufferSize < 1 {
+		return nil, fmt.Errorf("localBufferSize must be > 0: %d", options.localBufferSize)
+	}
+	gadget, err := NewUnion(lgConfigK, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ConcurrentSketch{
+		gadget:              gadget,
+		seed:                gadget.(*unionImpl).gadget.(*hllSketchState).seed,
+		maxConcurrencyError: options.maxConcurrencyError,
+		localBufferSize:     options.localBufferSize,
+	}, nil
+}
+
+// NewLocal returns a new local sketch feeding this sketch, to be used by a single goroutine.
+// The buffers of the local sketches shrink as more of them are open, see Close.
+func (s *ConcurrentSketch) NewLocal() *ConcurrentLocalSketch {
+	s.numLocals.Add(1)
+	return &ConcurrentLocalSketch{
+		shared:  s,
+		coupons: make([]int, 0, s.localBufferSize),
+	}
+}
+
+// GetEstimate returns the cardinality estimate of the items propagated so far.
+// It does not block and can be called while local sketches are being updated.
+func (s *ConcurrentSketch) GetEstimate() (float64, error) {
+	return s.publishedEstimate(), nil
+}
+
+// publishedEstimate returns the estimate stored by the last propagation.
+func (s *ConcurrentSketch) publishedEstimate() float64 {
+	return math.Float64frombits(s.estimate.Load())
+}
+
+// GetLowerBound gets the approximate lower error bound of the items propagated so far, given the
+// specified number of standard deviations.
+//
+//   - numStdDev, this must be an integer between 1 and 3, inclusive.
+func (s *ConcurrentSketch) GetLowerBound(numStdDev int) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gadget.GetLowerBound(numStdDev)
+}
+
+// GetUpperBound gets the approximate upper error bound of the items propagated so far, given the
+// specified number of standard deviations.
+//
+//   - numStdDev, this must be an integer between 1 and 3, inclusive.
+func (s *ConcurrentSketch) GetUpperBound(numStdDev int) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gadget.GetUpperBound(numStdDev)
+}
+
+// GetLgConfigK returns the lgConfigK of the sketch.
+func (s *ConcurrentSketch) GetLgConfigK() int {
+	return s.gadget.GetLgConfigK()
+}
+
+// GetResult returns a copy of the items propagated so far as a sketch of the given TgtHllType.
+func (s *ConcurrentSketch) GetResult(tgtHllType TgtHllType) (HllSketch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gadget.GetResult(tgtHllType)
+}
+
+// Reset resets the shared sketch to empty, items buffered by local sketches are kept and will be
+// propagated later.
+func (s *ConcurrentSketch) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.estimate.Store(0)
+	return s.gadget.Reset()
+}
+
+// propagate applies the given coupons to the gadget and publishes its new estimate.
+func (s *ConcurrentSketch) propagate(coupons []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, coupon := range coupons {
+		if _, err := s.gadget.couponUpdate(coupon); err != nil {
+			return err
+		}
+	}
+	est, err := s.gadget.GetEstimate()
+	if err != nil {
+		return err
+	}
+	s.estimate.Store(math.Float64bits(est))
+	return nil
+}
+
+// getBufferLimit returns the number of coupons a local sketch may buffer before propagating them,
+// such that the buffered items of all the local sketches stay within the maximum concurrency error.
+func (s *ConcurrentSketch) getBufferLimit() int {
+	numLocals := float64(max(1, s.numLocals.Load()))
+	limit := int(s.maxConcurrencyError * s.publishedEstimate() / numLocals)
+	return max(1, min(limit, s.localBufferSize))
+}
+
+// UpdateUInt64 present the given unsigned 64-bit integer as a potential unique item.
+func (l *ConcurrentLocalSketch) UpdateUInt64(datum uint64) error {
+	binary.LittleEndian.PutUint64(l.scratch[:], datum)
+	return l.UpdateHash(murmur3.SeedSum128(l.shared.seed, l.shared.seed, l.scratch[:]))
+}
+
+// UpdateInt64 present the given signed 64-bit integer as a potential unique item.
+func (l *ConcurrentLocalSketch) UpdateInt64(datum int64) error {
+	return l.UpdateUInt64(uint64(datum))
+}
+
+// UpdateSlice present the given byte slice as a potential unique item.
+func (l *ConcurrentLocalSketch) UpdateSlice(datum []byte) error {
+	if len(datum) == 0 {
+		return nil
+	}
+	return l.UpdateHash(murmur3.SeedSum128(l.shared.seed, l.shared.seed, datum))
+}
+
+// UpdateString present the given string as a potential unique item.
+func (l *ConcurrentLocalSketch) UpdateString(datum string) error {
+	// get a slice to the string data (avoiding a copy to heap)
+	return l.UpdateSlice(unsafe.Slice(unsafe.StringData(datum), len(datum)))
+}
+
+// UpdateHash presents an item by its 128-bit hash, see HllSketch.UpdateHash.
+func (l *ConcurrentLocalSketch) UpdateHash(lo uint64, hi uint64) error {
+	if l.closed {
+		return fmt.Errorf("local sketch is closed")
+	}
+	l.coupons = append(l.coupons, coupon(lo, hi))
+	if len(l.coupons) >= l.shared.getBufferLimit() {
+		return l.Flush()
+	}
+	return nil
+}
+
+// Flush propagates the buffered items to the shared sketch.
+// It should be called once a goroutine is done updating its local sketch.
+func (l *ConcurrentLocalSketch) Flush() error {
+	if len(l.coupons) == 0 {
+		return nil
+	}
+	err := l.shared.propagate(l.coupons)
+	l.coupons = l.coupons[:0]
+	return err
+}
+
+// Close flushes the buffered items and releases the local sketch, so that the buffers of the local
+// sketches still open are sized for their number. The local sketch can no longer be updated.
+func (l *ConcurrentLocalSketch) Close() error {
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	l.shared.numLocals.Add(-1)
+	return l.Flush()
+}
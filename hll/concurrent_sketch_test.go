@@ -0,0 +1,208 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hll
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentSketch(t *testing.T) {
+	const (
+		lgK        = 12
+		numWriters = 8
+		perWriter  = 50000
+	)
+	shared, err := NewConcurrentSketch(lgK)
+	assert.NoError(t, err)
+	est, err := shared.GetEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, est)
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			est, err := shared.GetEstimate()
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, est, 0.0)
+		}
+	}()
+
+	var writers sync.WaitGroup
+	for w := 0; w < numWriters; w++ {
+		writers.Add(1)
+		local := shared.NewLocal()
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < perWriter; i++ {
+				assert.NoError(t, local.UpdateInt64(int64(w*perWriter+i)))
+			}
+			assert.NoError(t, local.Close())
+		}(w)
+	}
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	// the registers do not depend on the order of the updates
+	heap, err := NewHllSketch(lgK, TgtHllTypeHll8)
+	assert.NoError(t, err)
+	for i := 0; i < numWriters*perWriter; i++ {
+		assert.NoError(t, heap.UpdateInt64(int64(i)))
+	}
+	result, err := shared.GetResult(TgtHllTypeHll8)
+	assert.NoError(t, err)
+	heapComposite, err := heap.GetCompositeEstimate()
+	assert.NoError(t, err)
+	composite, err := result.GetCompositeEstimate()
+	assert.NoError(t, err)
+	assert.InDelta(t, heapComposite, composite, heapComposite*1e-9)
+
+	// the gadget sees every coupon in some order, so the HIP estimate keeps its accuracy
+	est, err = shared.GetEstimate()
+	assert.NoError(t, err)
+	lb, err := shared.GetLowerBound(3)
+	assert.NoError(t, err)
+	ub, err := shared.GetUpperBound(3)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, lb, est)
+	assert.GreaterOrEqual(t, ub, est)
+	assert.InDelta(t, numWriters*perWriter, est, numWriters*perWriter*0.05)
+}
+
+func TestConcurrentSketchPropagationLag(t *testing.T) {
+	const numLocals = 4
+	shared, err := NewConcurrentSketch(12, WithMaxConcurrencyError(0.05), WithLocalBufferSize(1000))
+	assert.NoError(t, err)
+	locals := make([]*ConcurrentLocalSketch, numLocals)
+	for i := range locals {
+		locals[i] = shared.NewLocal()
+	}
+
+	// eager propagation while the estimate is small
+	assert.NoError(t, locals[0].UpdateInt64(0))
+	est, err := shared.GetEstimate()
+	assert.NoError(t, err)
+	assert.InDelta(t, 1, est, 0.01)
+
+	n := 0
+	for ; n < 100000; n++ {
+		assert.NoError(t, locals[n%numLocals].UpdateInt64(int64(n)))
+		buffered := 0
+		for _, local := range locals {
+			buffered += len(local.coupons)
+			assert.LessOrEqual(t, len(local.coupons), 1000)
+		}
+		// the buffered items stay within the max concurrency error of the published estimate
+		est, err := shared.GetEstimate()
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, float64(buffered), 0.05*est+numLocals)
+	}
+	for _, local := range locals {
+		assert.NoError(t, local.Flush())
+		assert.Empty(t, local.coupons)
+	}
+	est, err = shared.GetEstimate()
+	assert.NoError(t, err)
+	assert.InDelta(t, n, est, float64(n)*0.05)
+}
+
+func TestConcurrentSketchUpdateSeed(t *testing.T) {
+	shared, err := NewConcurrentSketch(10, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	heap, err := NewHllSketch(10, TgtHllTypeHll8, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	local := shared.NewLocal()
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, local.UpdateString(string(rune('a'+i%26))+string(rune(i))))
+		assert.NoError(t, heap.UpdateString(string(rune('a'+i%26))+string(rune(i))))
+	}
+	assert.NoError(t, local.UpdateString(""))
+	assert.NoError(t, local.Flush())
+
+	result, err := shared.GetResult(TgtHllTypeHll8)
+	assert.NoError(t, err)
+	est, err := result.GetCompositeEstimate()
+	assert.NoError(t, err)
+	heapEst, err := heap.GetCompositeEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, heapEst, est)
+
+	assert.NoError(t, shared.Reset())
+	est, err = shared.GetEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, est)
+	result, err = shared.GetResult(TgtHllTypeHll8)
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+}
+
+func TestConcurrentSketchCloseLocals(t *testing.T) {
+	shared, err := NewConcurrentSketch(12, WithMaxConcurrencyError(0.05), WithLocalBufferSize(1000))
+	assert.NoError(t, err)
+	long := shared.NewLocal()
+	n := 0
+	for ; n < 100000; n++ {
+		assert.NoError(t, long.UpdateInt64(int64(n)))
+	}
+	limit := shared.getBufferLimit()
+
+	// goroutines come and go, each with its own local sketch
+	var wg sync.WaitGroup
+	for g := 0; g < 1000; g++ {
+		wg.Add(1)
+		local := shared.NewLocal()
+		go func(g int) {
+			defer wg.Done()
+			assert.NoError(t, local.UpdateInt64(int64(n+g)))
+			assert.NoError(t, local.Close())
+		}(g)
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), shared.numLocals.Load())
+	assert.GreaterOrEqual(t, shared.getBufferLimit(), limit)
+
+	assert.NoError(t, long.Close())
+	assert.NoError(t, long.Close())
+	assert.Error(t, long.UpdateInt64(0))
+	assert.Equal(t, int64(0), shared.numLocals.Load())
+	est, err := shared.GetEstimate()
+	assert.NoError(t, err)
+	assert.InDelta(t, n+1000, est, float64(n)*0.05)
+}
+
+func TestConcurrentSketchErrors(t *testing.T) {
+	_, err := NewConcurrentSketch(3)
+	assert.Error(t, err)
+	_, err = NewConcurrentSketch(10, WithMaxConcurrencyError(1))
+	assert.Error(t, err)
+	_, err = NewConcurrentSketch(10, WithMaxConcurrencyError(-0.1))
+	assert.Error(t, err)
+	_, err = NewConcurrentSketch(10, WithLocalBufferSize(0))
+	assert.Error(t, err)
+}
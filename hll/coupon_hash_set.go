@@ -94,7 +94,7 @@ func (c *couponHashSetImpl) getPreInts() int {
 
 func (c *couponHashSetImpl) copyAs(tgtHllType TgtHllType) (hllSketchStateI, error) {
 	newC := &couponHashSetImpl{
-		hllSketchConfig: newHllSketchConfig(c.lgConfigK, tgtHllType, curModeSet),
+		hllSketchConfig: newHllSketchConfig(c.lgConfigK, tgtHllType, CurModeSet),
 		hllCouponState:  newHllCouponState(c.lgCouponArrInts, c.couponCount, make([]int, len(c.couponIntArr))),
 	}
 
@@ -198,7 +198,7 @@ func newCouponHashSet(lgConfigK int, tgtHllType TgtHllType) (couponHashSetImpl,
 	if lgConfigK <= 7 {
 		return couponHashSetImpl{}, fmt.Errorf("lgConfigK must be > 7 for SET mode")
 	}
-	cl, err := newCouponList(lgConfigK, tgtHllType, curModeSet)
+	cl, err := newCouponList(lgConfigK, tgtHllType, CurModeSet)
 	if err != nil {
 		return couponHashSetImpl{}, err
 	}
@@ -212,15 +212,21 @@ func deserializeCouponHashSet(byteArray []byte) (hllCoupon, error) {
 
 	curMode := extractCurMode(byteArray)
 	memArrStart := listIntArrStart
-	if curMode == curModeSet {
+	if curMode == CurModeSet {
 		memArrStart = hashSetIntArrStart
 	}
 	set, err := newCouponHashSet(lgConfigK, tgtHllType)
 	if err != nil {
 		return nil, err
 	}
+	if len(byteArray) < hashSetIntArrStart {
+		return nil, fmt.Errorf("input array too small: %d", len(byteArray))
+	}
 	memIsCompact := extractCompactFlag(byteArray)
 	couponCount := extractHashSetCount(byteArray)
+	if couponCount > 1<<(lgConfigK-3) {
+		return nil, fmt.Errorf("possible Corruption: Invalid Set Count: %d", couponCount)
+	}
 	lgCouponArrInts := extractLgArr(byteArray)
 	if lgCouponArrInts < lgInitSetSize {
 		lgCouponArrInts, err = computeLgArr(byteArray, couponCount, lgConfigK)
@@ -229,13 +235,29 @@ func deserializeCouponHashSet(byteArray []byte) (hllCoupon, error) {
 		}
 	}
 	if memIsCompact {
-		for it := 0; it < couponCount && err == nil; it++ {
-			_, err = set.couponUpdate(int(binary.LittleEndian.Uint32(byteArray[memArrStart+(it<<2) : memArrStart+(it<<2)+4])))
+		if len(byteArray) < memArrStart+(couponCount<<2) {
+			return nil, fmt.Errorf("input array too small: %d", len(byteArray))
 		}
-		if err != nil {
-			return nil, err
+		for it := 0; it < couponCount; it++ {
+			coupon := int(binary.LittleEndian.Uint32(byteArray[memArrStart+(it<<2) : memArrStart+(it<<2)+4]))
+			if coupon == empty {
+				return nil, fmt.Errorf("possible Corruption: empty coupon")
+			}
+			sketch, err := set.couponUpdate(coupon)
+			if err != nil {
+				return nil, err
+			}
+			if sketch != &set {
+				return nil, fmt.Errorf("possible Corruption: Invalid Set Count: %d", couponCount)
+			}
 		}
 	} else {
+		if lgCouponArrInts > lgConfigK-3 || couponCount > 1<<lgCouponArrInts {
+			return nil, fmt.Errorf("possible Corruption: Invalid Set Size: %d, %d", lgCouponArrInts, couponCount)
+		}
+		if len(byteArray) < hashSetIntArrStart+(4<<lgCouponArrInts) {
+			return nil, fmt.Errorf("input array too small: %d", len(byteArray))
+		}
 		set.couponCount = couponCount
 		set.lgCouponArrInts = lgCouponArrInts
 		couponArrInts := 1 << lgCouponArrInts
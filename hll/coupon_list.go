@@ -100,7 +100,7 @@ func (c *couponListImpl) getPreInts() int {
 
 func (c *couponListImpl) copyAs(tgtHllType TgtHllType) (hllSketchStateI, error) {
 	newC := &couponListImpl{
-		hllSketchConfig: newHllSketchConfig(c.lgConfigK, tgtHllType, curModeList),
+		hllSketchConfig: newHllSketchConfig(c.lgConfigK, tgtHllType, CurModeList),
 		hllCouponState:  newHllCouponState(c.lgCouponArrInts, c.couponCount, make([]int, len(c.couponIntArr))),
 	}
 
@@ -160,12 +160,12 @@ func promoteListToSet(c *couponListImpl) (hllSketchStateI, error) {
 }
 
 // newCouponList returns a new couponListImpl.
-func newCouponList(lgConfigK int, tgtHllType TgtHllType, curMode curMode) (couponListImpl, error) {
+func newCouponList(lgConfigK int, tgtHllType TgtHllType, curMode CurMode) (couponListImpl, error) {
 	var (
 		lgCouponArrInts = lgInitSetSize //SET
 	)
 
-	if curMode == curModeList {
+	if curMode == CurModeList {
 		lgCouponArrInts = lgInitListSize
 	} else if lgConfigK <= 7 {
 		return couponListImpl{}, fmt.Errorf("lgConfigK must be > 7 for non-HLL mode")
@@ -185,11 +185,17 @@ func deserializeCouponList(byteArray []byte) (hllCoupon, error) {
 	lgConfigK := extractLgK(byteArray)
 	tgtHllType := extractTgtHllType(byteArray)
 
-	list, err := newCouponList(lgConfigK, tgtHllType, curModeList)
+	list, err := newCouponList(lgConfigK, tgtHllType, CurModeList)
 	if err != nil {
 		return nil, err
 	}
 	couponCount := extractListCount(byteArray)
+	if couponCount > len(list.couponIntArr) {
+		return nil, fmt.Errorf("possible Corruption: Invalid List Count: %d", couponCount)
+	}
+	if len(byteArray) < listIntArrStart+(couponCount<<2) {
+		return nil, fmt.Errorf("input array too small: %d", len(byteArray))
+	}
 	// TODO there must be a more efficient to reinterpret the byte array as an int array
 	for it := 0; it < couponCount; it++ {
 		list.couponIntArr[it] = int(binary.LittleEndian.Uint32(byteArray[listIntArrStart+it*4 : listIntArrStart+it*4+4]))
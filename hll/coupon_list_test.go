@@ -47,7 +47,7 @@ func TestCouponDuplicatesAndMisc(t *testing.T) {
 		assert.NoError(t, sk.UpdateInt64(int64(i)))
 		assert.NoError(t, sk.UpdateInt64(int64(i)))
 	}
-	assert.Equal(t, sk.GetCurMode(), curModeList)
+	assert.Equal(t, sk.GetCurMode(), CurModeList)
 	est, err := sk.GetCompositeEstimate()
 	assert.NoError(t, err)
 	assert.InDelta(t, est, 7.0, 7*.01)
@@ -58,7 +58,7 @@ func TestCouponDuplicatesAndMisc(t *testing.T) {
 
 	assert.NoError(t, sk.UpdateInt64(8))
 	assert.NoError(t, sk.UpdateInt64(8))
-	assert.Equal(t, sk.GetCurMode(), curModeSet)
+	assert.Equal(t, sk.GetCurMode(), CurModeSet)
 	est, err = sk.GetCompositeEstimate()
 	assert.NoError(t, err)
 	assert.InDelta(t, est, 8.0, 8*.01)
@@ -71,7 +71,7 @@ func TestCouponDuplicatesAndMisc(t *testing.T) {
 		assert.NoError(t, sk.UpdateInt64(int64(i)))
 	}
 
-	assert.Equal(t, sk.GetCurMode(), curModeHll)
+	assert.Equal(t, sk.GetCurMode(), CurModeHll)
 	est, err = sk.GetCompositeEstimate()
 	assert.NoError(t, err)
 	assert.InDelta(t, est, 25.0, 25*.1)
@@ -0,0 +1,273 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hll
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInsufficientMemory is returned by a direct sketch when the caller-supplied slice is too
+// small to hold the image required by the next promotion (LIST to SET, SET growth, SET to HLL)
+// or by the growth of the HLL_4 aux hash map.
+// The update that triggered the error is not applied and the slice still holds a valid image.
+var ErrInsufficientMemory = errors.New("insufficient memory for direct sketch")
+
+// NewDirectHllSketch constructs a new empty sketch whose state is kept in the given byte slice,
+// using the updatable serialization layout (see ToUpdatableSlice).
+// All updates are applied directly to the slice, so at any time it holds a valid image that can
+// be re-attached with WrapSketch or read with NewHllSketchFromSlice.
+//
+//   - lgConfigK, the Log2 of K for the target HLL sketch. This value must be between 4 and 21 inclusively.
+//   - tgtHllType, the desired HLL type.
+//   - mem, the slice backing the sketch. It must at least hold an empty sketch; it should be sized
+//     with the maximum updatable serialization bytes for lgConfigK and tgtHllType to never run out
+//     of space.
+//   - opts, optional parameters such as WithUpdateSeed.
+func NewDirectHllSketch(lgConfigK int, tgtHllType TgtHllType, mem []byte, opts ...SketchOption) (HllSketch, error) {
+	lgK, err := checkLgK(lgConfigK)
+	if err != nil {
+		return nil, err
+	}
+	couponList, err := newCouponList(lgK, tgtHllType, CurModeList)
+	if err != nil {
+		return nil, err
+	}
+	h, err := newHllSketchState(&couponList, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.mem = mem
+	if err := h.writeDirectImage(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// WrapSketch attaches a sketch to the given updatable image, as produced by ToUpdatableSlice or
+// by a direct sketch. The HLL register array is not copied, updates and estimates are done against
+// the slice, which must not be modified by other means while the sketch is in use.
+//
+//   - mem, the updatable image, it may be larger than the image itself to leave room for promotions.
+//   - opts, optional parameters such as WithUpdateSeed, the seed the image was built with.
+func WrapSketch(mem []byte, opts ...SketchOption) (HllSketch, error) {
+	sketch, err := wrapDirectState(mem)
+	if err != nil {
+		return nil, err
+	}
+	h, err := newHllSketchState(sketch, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.mem = mem
+	if need := h.GetUpdatableSerializationBytes(); len(mem) < need {
+		return nil, fmt.Errorf("%w: need %d bytes, have %d", ErrInsufficientMemory, need, len(mem))
+	}
+	if sketch.isRebuildCurMinNumKxQFlag() {
+		if err := checkRebuildCurMinNumKxQ(h); err != nil {
+			return nil, err
+		}
+		insertCommonHll(sketch.(hllArray), mem, false)
+	}
+	return h, nil
+}
+
+// wrapDirectState returns the in-memory state for the updatable image held in mem.
+// In HLL mode the register array of the returned state is shared with mem.
+func wrapDirectState(mem []byte) (hllSketchStateI, error) {
+	if len(mem) < 8 {
+		return nil, fmt.Errorf("input array too small: %d", len(mem))
+	}
+	curMode, err := checkPreamble(mem)
+	if err != nil {
+		return nil, err
+	}
+	if extractCompactFlag(mem) {
+		return nil, fmt.Errorf("cannot wrap a compact sketch image, use NewHllSketchFromSlice")
+	}
+	var sketch hllSketchStateI
+	switch curMode {
+	case CurModeList:
+		sketch, err = deserializeCouponList(mem)
+	case CurModeSet:
+		sketch, err = deserializeCouponHashSet(mem)
+	default:
+		if len(mem) < hllByteArrStart {
+			return nil, fmt.Errorf("input array too small: %d", len(mem))
+		}
+		switch extractTgtHllType(mem) {
+		case TgtHllTypeHll4:
+			sketch, err = deserializeHll4(mem)
+		case TgtHllTypeHll6:
+			sketch, err = deserializeHll6(mem)
+		default:
+			sketch, err = deserializeHll8(mem)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sketch, nil
+}
+
+// writeDirectImage writes the full updatable image of the current state into mem and attaches
+// the state to it.
+func (h *hllSketchState) writeDirectImage() error {
+	need := h.GetUpdatableSerializationBytes()
+	if len(h.mem) < need {
+		return fmt.Errorf("%w: need %d bytes, have %d", ErrInsufficientMemory, need, len(h.mem))
+	}
+	image, err := h.ToUpdatableSlice()
+	if err != nil {
+		return err
+	}
+	copy(h.mem, image)
+	sketch, err := wrapDirectState(h.mem)
+	if err != nil {
+		return err
+	}
+	h.sketch = sketch
+	return nil
+}
+
+// directCouponUpdate updates the state with the given coupon and mirrors the change into mem.
+func (h *hllSketchState) directCouponUpdate(coupon int) (hllSketchStateI, error) {
+	if h.sketch.GetCurMode() == CurModeHll {
+		return h.sketch, h.directHllUpdate(coupon)
+	}
+
+	src := h.sketch.(hllCoupon)
+	srcMode := src.GetCurMode()
+	srcLgArr := src.getLgCouponArrInts()
+	srcCount := src.getCouponCount()
+	sk, err := src.couponUpdate(coupon)
+	if err != nil {
+		return h.sketch, err
+	}
+	h.sketch = sk
+
+	if sk.GetCurMode() != srcMode || sk.(hllCoupon).getLgCouponArrInts() != srcLgArr {
+		// promotion or growth, the whole image changes
+		if err := h.writeDirectImage(); err != nil {
+			// mem still holds the image from before this update
+			prev, e := wrapDirectState(h.mem)
+			if e != nil {
+				return nil, e
+			}
+			h.sketch = prev
+			return h.sketch, err
+		}
+		return h.sketch, nil
+	}
+
+	dst := sk.(hllCoupon)
+	couponCount := dst.getCouponCount()
+	if couponCount == srcCount {
+		return h.sketch, nil //duplicate
+	}
+	index := couponCount - 1 //a list is filled in order
+	if srcMode == CurModeSet {
+		index, err = findCoupon(dst.getCouponIntArr(), dst.getLgCouponArrInts(), coupon)
+		if err != nil {
+			return h.sketch, err
+		}
+	}
+	offset := dst.getMemDataStart() + (index << 2)
+	binary.LittleEndian.PutUint32(h.mem[offset:offset+4], uint32(coupon))
+	insertEmptyFlag(h.mem, false)
+	if srcMode == CurModeList {
+		insertListCount(h.mem, couponCount)
+	} else {
+		insertHashSetCount(h.mem, couponCount)
+	}
+	return h.sketch, nil
+}
+
+// directHllUpdate updates an HLL mode state with the given coupon.
+// The register array is shared with mem, so only the preamble fields and, for HLL_4,
+// the aux hash map need to be written back.
+func (h *hllSketchState) directHllUpdate(coupon int) error {
+	hll4, isHll4 := h.sketch.(*hll4ArrayImpl)
+	var (
+		oldAux      *auxHashMap
+		oldAuxCount int
+	)
+	if isHll4 {
+		if err := checkDirectAuxSpace(hll4, coupon, len(h.mem)); err != nil {
+			return err
+		}
+		oldAux = hll4.auxHashMap
+		if oldAux != nil {
+			oldAuxCount = oldAux.getAuxCount()
+		}
+	}
+
+	_, err := h.sketch.couponUpdate(coupon)
+	insertCommonHll(h.sketch.(hllArray), h.mem, false)
+	if err != nil || !isHll4 {
+		return err
+	}
+
+	aux := hll4.auxHashMap
+	if aux == nil && oldAux == nil {
+		return nil
+	}
+	slotNo := coupon & ((1 << hll4.lgConfigK) - 1)
+	if aux != oldAux || aux.getAuxCount() != oldAuxCount || hll4.getNibble(slotNo) == auxToken {
+		return insertDirectAux(hll4, h.mem)
+	}
+	return nil
+}
+
+// checkDirectAuxSpace returns an error if updating the HLL_4 array with the given coupon would
+// grow its aux hash map beyond the space available in mem.
+func checkDirectAuxSpace(hll4 *hll4ArrayImpl, coupon int, memBytes int) error {
+	newValue := coupon >> keyBits26
+	slotNo := coupon & ((1 << hll4.lgConfigK) - 1)
+	nibble := hll4.getNibble(slotNo)
+	if nibble == auxToken || newValue <= hll4.curMin+nibble || newValue-hll4.curMin < auxToken {
+		return nil //no new exception
+	}
+	lgAuxArr := lgAuxArrInts[hll4.lgConfigK]
+	auxCount := 0
+	if hll4.auxHashMap != nil {
+		lgAuxArr = hll4.auxHashMap.getLgAuxArrInts()
+		auxCount = hll4.auxHashMap.getAuxCount()
+	}
+	if (resizeDenom * (auxCount + 1)) > (resizeNumber * (1 << lgAuxArr)) {
+		lgAuxArr++
+	}
+	need := hll4.getAuxStart() + (4 << lgAuxArr)
+	if need > memBytes {
+		return fmt.Errorf("%w: need %d bytes, have %d", ErrInsufficientMemory, need, memBytes)
+	}
+	return nil
+}
+
+// insertDirectAux writes the aux hash map of the HLL_4 array into mem, in updatable form.
+func insertDirectAux(hll4 *hll4ArrayImpl, mem []byte) error {
+	if hll4.auxHashMap != nil {
+		return insertAux(hll4, mem, false)
+	}
+	auxStart := hll4.getAuxStart()
+	auxEnd := min(auxStart+(4<<lgAuxArrInts[hll4.lgConfigK]), len(mem))
+	clear(mem[auxStart:auxEnd])
+	insertLgArr(mem, 0)
+	return insertAuxCount(mem, 0)
+}
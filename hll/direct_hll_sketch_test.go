@@ -0,0 +1,214 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hll
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirectMatchesHeap(t *testing.T) {
+	checkDirectMatchesHeap(t, 4, TgtHllTypeHll4, 1000)
+	checkDirectMatchesHeap(t, 10, TgtHllTypeHll4, 100000)
+	checkDirectMatchesHeap(t, 10, TgtHllTypeHll6, 10000)
+	checkDirectMatchesHeap(t, 10, TgtHllTypeHll8, 10000)
+	checkDirectMatchesHeap(t, 14, TgtHllTypeHll4, 20000)
+}
+
+func checkDirectMatchesHeap(t *testing.T, lgK int, tgtHllType TgtHllType, n int) {
+	mem := make([]byte, getMaxUpdatableSerializationBytes(lgK, tgtHllType))
+	direct, err := NewDirectHllSketch(lgK, tgtHllType, mem)
+	assert.NoError(t, err)
+	heap, err := NewHllSketch(lgK, tgtHllType)
+	assert.NoError(t, err)
+	assert.True(t, direct.IsEmpty())
+
+	for i := 0; i < n; i++ {
+		assert.NoError(t, direct.UpdateInt64(int64(i)))
+		assert.NoError(t, heap.UpdateInt64(int64(i)))
+		if i%97 != 0 && i != n-1 {
+			continue
+		}
+		assert.Equal(t, heap.GetCurMode(), direct.GetCurMode())
+		heapBytes, err := heap.ToUpdatableSlice()
+		assert.NoError(t, err)
+		assert.Equal(t, heapBytes, mem[:len(heapBytes)], "n=%d", i+1)
+
+		heapEst, err := heap.GetEstimate()
+		assert.NoError(t, err)
+		directEst, err := direct.GetEstimate()
+		assert.NoError(t, err)
+		assert.Equal(t, heapEst, directEst)
+	}
+
+	fromMem, err := NewHllSketchFromSlice(mem, false)
+	assert.NoError(t, err)
+	est, err := fromMem.GetEstimate()
+	assert.NoError(t, err)
+	directEst, err := direct.GetEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, directEst, est)
+}
+
+func TestWrapSketch(t *testing.T) {
+	checkWrapSketch(t, 10, TgtHllTypeHll4, 5)
+	checkWrapSketch(t, 10, TgtHllTypeHll4, 50)
+	checkWrapSketch(t, 10, TgtHllTypeHll4, 5000)
+	checkWrapSketch(t, 12, TgtHllTypeHll6, 100)
+	checkWrapSketch(t, 12, TgtHllTypeHll8, 10000)
+}
+
+func checkWrapSketch(t *testing.T, lgK int, tgtHllType TgtHllType, n int) {
+	heap, err := NewHllSketch(lgK, tgtHllType)
+	assert.NoError(t, err)
+	for i := 0; i < n; i++ {
+		assert.NoError(t, heap.UpdateInt64(int64(i)))
+	}
+	image, err := heap.ToUpdatableSlice()
+	assert.NoError(t, err)
+	mem := make([]byte, getMaxUpdatableSerializationBytes(lgK, tgtHllType))
+	copy(mem, image)
+
+	direct, err := WrapSketch(mem)
+	assert.NoError(t, err)
+	assert.Equal(t, heap.GetCurMode(), direct.GetCurMode())
+
+	for i := n; i < 2*n+1000; i++ {
+		assert.NoError(t, direct.UpdateInt64(int64(i)))
+		assert.NoError(t, heap.UpdateInt64(int64(i)))
+	}
+	heapBytes, err := heap.ToUpdatableSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, heapBytes, mem[:len(heapBytes)])
+
+	directBytes, err := direct.ToUpdatableSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, heapBytes, directBytes)
+
+	// a copy is detached from the slice
+	cp, err := direct.Copy()
+	assert.NoError(t, err)
+	assert.NoError(t, cp.UpdateInt64(-1))
+	assert.Equal(t, heapBytes, mem[:len(heapBytes)])
+}
+
+func TestWrapSketchErrors(t *testing.T) {
+	_, err := WrapSketch(make([]byte, 4))
+	assert.Error(t, err)
+
+	heap, err := NewHllSketch(10, TgtHllTypeHll4)
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, heap.UpdateInt64(int64(i)))
+	}
+	compact, err := heap.ToCompactSlice()
+	assert.NoError(t, err)
+	_, err = WrapSketch(compact)
+	assert.Error(t, err)
+
+	_, err = NewDirectHllSketch(10, TgtHllTypeHll4, make([]byte, 16))
+	assert.ErrorIs(t, err, ErrInsufficientMemory)
+}
+
+func TestDirectInsufficientMemory(t *testing.T) {
+	mem := make([]byte, listIntArrStart+(4<<lgInitListSize))
+	direct, err := NewDirectHllSketch(12, TgtHllTypeHll8, mem)
+	assert.NoError(t, err)
+
+	i := 0
+	for ; err == nil; i++ {
+		err = direct.UpdateInt64(int64(i))
+	}
+	assert.ErrorIs(t, err, ErrInsufficientMemory)
+	assert.Equal(t, CurModeList, direct.GetCurMode())
+	assert.Equal(t, 7, direct.(*hllSketchState).sketch.(hllCoupon).getCouponCount())
+
+	wrapped, err := WrapSketch(mem)
+	assert.NoError(t, err)
+	est, err := wrapped.GetEstimate()
+	assert.NoError(t, err)
+	assert.InDelta(t, 7, est, 0.01)
+}
+
+func TestDirectReset(t *testing.T) {
+	mem := make([]byte, getMaxUpdatableSerializationBytes(8, TgtHllTypeHll4))
+	direct, err := NewDirectHllSketch(8, TgtHllTypeHll4, mem)
+	assert.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, direct.UpdateInt64(int64(i)))
+	}
+	assert.Equal(t, CurModeHll, direct.GetCurMode())
+	assert.NoError(t, direct.Reset())
+	assert.True(t, direct.IsEmpty())
+
+	wrapped, err := WrapSketch(mem)
+	assert.NoError(t, err)
+	assert.True(t, wrapped.IsEmpty())
+	assert.Equal(t, CurModeList, wrapped.GetCurMode())
+}
+
+func TestDirectUnionSource(t *testing.T) {
+	mem := make([]byte, getMaxUpdatableSerializationBytes(12, TgtHllTypeHll4))
+	direct, err := NewDirectHllSketch(12, TgtHllTypeHll4, mem)
+	assert.NoError(t, err)
+	heap, err := NewHllSketch(12, TgtHllTypeHll4)
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		assert.NoError(t, direct.UpdateInt64(int64(i)))
+		assert.NoError(t, heap.UpdateInt64(int64(i)))
+	}
+
+	union1, err := NewUnion(12)
+	assert.NoError(t, err)
+	assert.NoError(t, union1.UpdateSketch(direct))
+	union2, err := NewUnion(12)
+	assert.NoError(t, err)
+	assert.NoError(t, union2.UpdateSketch(heap))
+
+	est1, err := union1.GetEstimate()
+	assert.NoError(t, err)
+	est2, err := union2.GetEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, est2, est1)
+}
+
+func TestDirectUpdateSeed(t *testing.T) {
+	mem := make([]byte, getMaxUpdatableSerializationBytes(10, TgtHllTypeHll4))
+	direct, err := NewDirectHllSketch(10, TgtHllTypeHll4, mem, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	heap, err := NewHllSketch(10, TgtHllTypeHll4, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	for i := 0; i < 100000; i++ {
+		assert.NoError(t, direct.UpdateInt64(int64(i)))
+		assert.NoError(t, heap.UpdateInt64(int64(i)))
+		if i%1001 == 0 {
+			heapBytes, err := heap.ToUpdatableSlice()
+			assert.NoError(t, err)
+			assert.Equal(t, heapBytes, mem[:len(heapBytes)])
+		}
+	}
+
+	wrapped, err := WrapSketch(mem, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	est1, err := wrapped.GetEstimate()
+	assert.NoError(t, err)
+	est2, err := heap.GetEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, est2, est1)
+}
@@ -30,6 +30,9 @@ func (h *hll4ArrayImpl) getSlotValue(slotNo int) (int, error) {
 	nib := h.getNibble(slotNo)
 	if nib == auxToken {
 		auxHashMap := h.getAuxHashMap()
+		if auxHashMap == nil {
+			return 0, fmt.Errorf("SlotNo not found: %d", slotNo)
+		}
 		return auxHashMap.mustFindValueFor(slotNo)
 	} else {
 		return nib + h.curMin, nil
@@ -91,7 +94,7 @@ func newHll4Array(lgConfigK int) hllArray {
 			hllSketchConfig: hllSketchConfig{
 				lgConfigK:  lgConfigK,
 				tgtHllType: TgtHllTypeHll4,
-				curMode:    curModeHll,
+				curMode:    CurModeHll,
 			},
 			curMin:      0,
 			numAtCurMin: 1 << lgConfigK,
@@ -108,6 +111,9 @@ func newHll4Array(lgConfigK int) hllArray {
 func deserializeHll4(byteArray []byte) (hllArray, error) {
 	lgConfigK := extractLgK(byteArray)
 	hll4 := newHll4Array(lgConfigK)
+	if len(byteArray) < hllByteArrStart+hll4.getHllByteArrBytes() {
+		return nil, fmt.Errorf("input array too small: %d", len(byteArray))
+	}
 	hll4.extractCommonHll(byteArray)
 
 	auxStart := hll4.getAuxStart()
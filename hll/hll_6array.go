@@ -73,7 +73,7 @@ func newHll6Array(lgConfigK int) hllArray {
 			hllSketchConfig: hllSketchConfig{
 				lgConfigK:  lgConfigK,
 				tgtHllType: TgtHllTypeHll6,
-				curMode:    curModeHll,
+				curMode:    CurModeHll,
 			},
 			curMin:      0,
 			numAtCurMin: 1 << lgConfigK,
@@ -87,11 +87,14 @@ func newHll6Array(lgConfigK int) hllArray {
 }
 
 // deserializeHll6 returns a new Hll6Array from the given byte array.
-func deserializeHll6(byteArray []byte) hllArray {
+func deserializeHll6(byteArray []byte) (hllArray, error) {
 	lgConfigK := extractLgK(byteArray)
 	hll6 := newHll6Array(lgConfigK)
+	if len(byteArray) < hllByteArrStart+hll6.getHllByteArrBytes() {
+		return nil, fmt.Errorf("input array too small: %d", len(byteArray))
+	}
 	hll6.extractCommonHll(byteArray)
-	return hll6
+	return hll6, nil
 }
 
 func (h *hll6ArrayImpl) couponUpdate(coupon int) (hllSketchStateI, error) {
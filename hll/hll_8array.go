@@ -70,7 +70,7 @@ func newHll8Array(lgConfigK int) hllArray {
 			hllSketchConfig: hllSketchConfig{
 				lgConfigK:  lgConfigK,
 				tgtHllType: TgtHllTypeHll8,
-				curMode:    curModeHll,
+				curMode:    CurModeHll,
 			},
 			curMin:      0,
 			numAtCurMin: 1 << lgConfigK,
@@ -84,11 +84,14 @@ func newHll8Array(lgConfigK int) hllArray {
 }
 
 // deserializeHll8 returns a new Hll8Array from the given byte array.
-func deserializeHll8(byteArray []byte) hllArray {
+func deserializeHll8(byteArray []byte) (hllArray, error) {
 	lgConfigK := extractLgK(byteArray)
 	hll8 := newHll8Array(lgConfigK)
+	if len(byteArray) < hllByteArrStart+hll8.getHllByteArrBytes() {
+		return nil, fmt.Errorf("input array too small: %d", len(byteArray))
+	}
 	hll8.extractCommonHll(byteArray)
-	return hll8
+	return hll8, nil
 }
 
 func convertToHll8(srcAbsHllArr hllArray) (hllSketchStateI, error) {
@@ -69,6 +69,126 @@ func TestBigHipGetRse(t *testing.T) {
 	}
 }
 
+func TestGetRelErr(t *testing.T) {
+	for lgK := minLogK; lgK <= maxLogK; lgK++ {
+		for numStdDev := 1; numStdDev <= 3; numStdDev++ {
+			for _, oooFlag := range []bool{false, true} {
+				ub, err := GetRelErr(true, oooFlag, lgK, numStdDev)
+				assert.NoError(t, err)
+				lb, err := GetRelErr(false, oooFlag, lgK, numStdDev)
+				assert.NoError(t, err)
+				assert.Greater(t, ub, 0.0)
+				assert.Greater(t, lb, 0.0)
+			}
+		}
+	}
+
+	// beyond the tables the relative error is the asymptotic RSE
+	relErr, err := GetRelErr(true, false, 14, 2)
+	assert.NoError(t, err)
+	assert.InDelta(t, 2*hllHipRSEFActor/128, relErr, 1e-12)
+	relErr, err = GetRelErr(false, true, 14, 1)
+	assert.NoError(t, err)
+	assert.InDelta(t, hllNonHipRSEFactor/128, relErr, 1e-12)
+
+	// out of order sketches have a larger error
+	hip, err := GetRelErr(true, false, 10, 1)
+	assert.NoError(t, err)
+	nonHip, err := GetRelErr(true, true, 10, 1)
+	assert.NoError(t, err)
+	assert.Greater(t, nonHip, hip)
+
+	_, err = GetRelErr(true, false, 3, 1)
+	assert.Error(t, err)
+	_, err = GetRelErr(true, false, 12, 4)
+	assert.Error(t, err)
+
+	// the bounds of a sketch in HLL mode are derived from it
+	sk, err := NewHllSketch(10, TgtHllTypeHll8)
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		assert.NoError(t, sk.UpdateInt64(int64(i)))
+	}
+	est, err := sk.GetEstimate()
+	assert.NoError(t, err)
+	ub, err := sk.GetUpperBound(2)
+	assert.NoError(t, err)
+	relErr, err = GetRelErr(true, sk.IsOutOfOrder(), 10, 2)
+	assert.NoError(t, err)
+	assert.InDelta(t, est/(1.0-relErr), ub, 1e-9)
+}
+
+func TestGetLgKForRelErr(t *testing.T) {
+	for numStdDev := 1; numStdDev <= 3; numStdDev++ {
+		for _, oooFlag := range []bool{false, true} {
+			for _, target := range []float64{0.2, 0.05, 0.01, 0.003} {
+				lgK, err := GetLgKForRelErr(target, oooFlag, numStdDev)
+				assert.NoError(t, err)
+				ub, err := GetRelErr(true, oooFlag, lgK, numStdDev)
+				assert.NoError(t, err)
+				lb, err := GetRelErr(false, oooFlag, lgK, numStdDev)
+				assert.NoError(t, err)
+				assert.LessOrEqual(t, max(ub, lb), target)
+				if lgK > minLogK {
+					// the smallest such lgK
+					ub, err = GetRelErr(true, oooFlag, lgK-1, numStdDev)
+					assert.NoError(t, err)
+					lb, err = GetRelErr(false, oooFlag, lgK-1, numStdDev)
+					assert.NoError(t, err)
+					assert.Greater(t, max(ub, lb), target)
+				}
+			}
+		}
+	}
+
+	lgK, err := GetLgKForRelErr(0.01, false, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 15, lgK) // 2 * 0.8326 / sqrt(2^15) = 0.0092
+
+	_, err = GetLgKForRelErr(0.0001, false, 1)
+	assert.Error(t, err)
+	_, err = GetLgKForRelErr(0.01, false, 0)
+	assert.Error(t, err)
+}
+
+func TestGetMaxSerializationBytes(t *testing.T) {
+	for _, tgtHllType := range []TgtHllType{TgtHllTypeHll4, TgtHllTypeHll6, TgtHllTypeHll8} {
+		for _, lgK := range []int{4, 7, 8, 12} {
+			maxUpdatable, err := GetMaxUpdatableSerializationBytes(lgK, tgtHllType)
+			assert.NoError(t, err)
+			sk, err := NewHllSketch(lgK, tgtHllType)
+			assert.NoError(t, err)
+			for n := 0; n <= 20*(1<<lgK); n++ {
+				if n > 0 {
+					assert.NoError(t, sk.UpdateInt64(int64(n)))
+				}
+				if n > 100 && n%97 != 0 {
+					continue
+				}
+				compactBound, err := GetMaxCompactSerializationBytes(lgK, tgtHllType, uint64(n))
+				assert.NoError(t, err)
+				compact, err := sk.ToCompactSlice()
+				assert.NoError(t, err)
+				assert.LessOrEqual(t, len(compact), compactBound, "lgK=%d type=%s n=%d", lgK, tgtHllType, n)
+				assert.LessOrEqual(t, compactBound, maxUpdatable)
+				assert.LessOrEqual(t, sk.GetUpdatableSerializationBytes(), maxUpdatable)
+			}
+		}
+	}
+
+	bound, err := GetMaxCompactSerializationBytes(12, TgtHllTypeHll8, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, bound)
+	bound, err = GetMaxCompactSerializationBytes(12, TgtHllTypeHll8, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 412, bound)
+
+	_, err = GetMaxUpdatableSerializationBytes(22, TgtHllTypeHll4)
+	assert.Error(t, err)
+	_, err = GetMaxCompactSerializationBytes(3, TgtHllTypeHll4, 10)
+	assert.Error(t, err)
+}
+
 func TestToArraySliceDeserialize(t *testing.T) {
 	lgK := 4
 	u := 8
@@ -20,10 +20,10 @@ package hll
 type hllSketchConfig struct { // extends hllSketchConfig
 	lgConfigK  int
 	tgtHllType TgtHllType
-	curMode    curMode
+	curMode    CurMode
 }
 
-func newHllSketchConfig(lgConfigK int, tgtHllType TgtHllType, curMode curMode) hllSketchConfig {
+func newHllSketchConfig(lgConfigK int, tgtHllType TgtHllType, curMode CurMode) hllSketchConfig {
 	return hllSketchConfig{
 		lgConfigK:  lgConfigK,
 		tgtHllType: tgtHllType,
@@ -39,6 +39,6 @@ func (c *hllSketchConfig) GetTgtHllType() TgtHllType {
 	return c.tgtHllType
 }
 
-func (c *hllSketchConfig) GetCurMode() curMode {
+func (c *hllSketchConfig) GetCurMode() CurMode {
 	return c.curMode
 }
@@ -18,6 +18,7 @@
 package hll
 
 import (
+	"fmt"
 	"math"
 )
 
@@ -145,6 +146,48 @@ func hllLowerBound(hllArray *hllArrayImpl, numStdDev int) (float64, error) {
 	return math.Max(estimate/(1.0+relErr), numNonZeros), nil
 }
 
+// GetRelErr returns the relative error that GetLowerBound and GetUpperBound apply to the estimate
+// of a sketch with the given configuration, for estimates in HLL mode.
+//
+//   - upperBound, true for the relative error of the upper bound, false for the lower bound.
+//   - oooFlag, true if the sketch is out of order, i.e. it is the result of a union operation,
+//     in which case the less accurate composite estimator is used instead of the HIP estimator.
+//   - lgConfigK, the Log2 of K, this value must be between 4 and 21 inclusively.
+//   - numStdDev, the number of standard deviations, this must be an integer between 1 and 3, inclusive.
+func GetRelErr(upperBound bool, oooFlag bool, lgConfigK int, numStdDev int) (float64, error) {
+	if err := checkNumStdDev(numStdDev); err != nil {
+		return 0, err
+	}
+	return getRelErrAllK(upperBound, oooFlag, lgConfigK, numStdDev)
+}
+
+// GetLgKForRelErr returns the smallest lgConfigK for which the relative error of the estimate is at
+// most relErr, for both the lower and the upper bound, at the confidence level given by the number
+// of standard deviations: 1 for 68.3%, 2 for 95.4% and 3 for 99.7%.
+//
+//   - relErr, the target relative error, e.g. 0.01 for 1%.
+//   - oooFlag, true if the sketch is the result of union operations, see GetRelErr.
+//   - numStdDev, the number of standard deviations, this must be an integer between 1 and 3, inclusive.
+func GetLgKForRelErr(relErr float64, oooFlag bool, numStdDev int) (int, error) {
+	if err := checkNumStdDev(numStdDev); err != nil {
+		return 0, err
+	}
+	for lgK := minLogK; lgK <= maxLogK; lgK++ {
+		lbErr, err := getRelErrAllK(false, oooFlag, lgK, numStdDev)
+		if err != nil {
+			return 0, err
+		}
+		ubErr, err := getRelErrAllK(true, oooFlag, lgK, numStdDev)
+		if err != nil {
+			return 0, err
+		}
+		if max(lbErr, ubErr) <= relErr {
+			return lgK, nil
+		}
+	}
+	return 0, fmt.Errorf("relative error %f is not achievable with lgConfigK <= %d", relErr, maxLogK)
+}
+
 func getRelErrAllK(upperBound bool, oooFlag bool, lgConfigK int, numStdDev int) (float64, error) {
 	lgK, err := checkLgK(lgConfigK)
 	if err != nil {
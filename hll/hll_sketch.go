@@ -27,6 +27,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math/bits"
+	"slices"
 	"unsafe"
 
 	"github.com/apache/datasketches-go/internal"
@@ -52,6 +53,14 @@ type HllSketch interface {
 	// GetEstimate returns the cardinality estimate
 	GetEstimate() (float64, error)
 
+	// GetHipEstimate returns the estimate of the HIP (Historic Inverse Probability) estimator,
+	// which is the most accurate one but is only valid if the sketch is not out of order.
+	GetHipEstimate() (float64, error)
+
+	// IsOutOfOrder returns true if the sketch is the result of a union operation, in which case
+	// GetEstimate and the bounds use the composite estimator instead of the HIP estimator.
+	IsOutOfOrder() bool
+
 	// UpdateUInt64 present the given unsigned 64-bit integer as a potential unique item.
 	UpdateUInt64(datum uint64) error
 
@@ -64,6 +73,22 @@ type HllSketch interface {
 	// UpdateString present the given string as a potential unique item.
 	UpdateString(datum string) error
 
+	// UpdateHash presents an item by its 128-bit hash, split in its low and high 64-bit halves.
+	// The hash must be the 128-bit murmur3 hash of the item using the update seed of the sketch for
+	// both of its seeds (internal.DEFAULT_UPDATE_SEED by default), as computed by
+	// murmur3.SeedSum128(seed, seed, datum), in which case the sketch is the same as if the item had
+	// been presented with UpdateSlice(datum). Integers are hashed from their 8-byte little-endian form.
+	UpdateHash(lo uint64, hi uint64) error
+
+	// UpdateUInt64s presents each of the given unsigned 64-bit integers as a potential unique item.
+	UpdateUInt64s(data []uint64) error
+
+	// UpdateSlices presents each of the given byte slices as a potential unique item, empty slices are ignored.
+	UpdateSlices(data [][]byte) error
+
+	// UpdateStrings presents each of the given strings as a potential unique item, empty strings are ignored.
+	UpdateStrings(data []string) error
+
 	// Reset resets the sketch to empty, but does not change the configured values of lgConfigK and tgtHllType.
 	Reset() error
 
@@ -87,7 +112,11 @@ type HllSketch interface {
 	GetTgtHllType() TgtHllType
 
 	// GetCurMode returns the current mode of the sketch: LIST, SET, HLL.
-	GetCurMode() curMode
+	GetCurMode() CurMode
+
+	// Registers returns a read-only iterator over the non-zero registers of the sketch, in any mode.
+	// The sketch must not be updated while iterating.
+	Registers() *RegisterIterator
 
 	// GetUpdatableSerializationBytes gets the size in bytes of the current sketch when serialized using
 	// ToUpdatableSlice.
@@ -103,6 +132,31 @@ type HllSketch interface {
 
 	GetSerializationVersion() int
 
+	// ToString returns a human-readable view of the sketch.
+	//
+	//   - summary, if true, includes the configuration, the estimate with its bounds and, in HLL mode,
+	//     the estimator state: curMin, numAtCurMin, HIP accumulator, KxQ registers and flags.
+	//   - detail, if true, lists the coupons in LIST and SET modes, or the registers in HLL mode.
+	//   - auxDetail, if true, lists the exceptions of the aux hash map of an HLL_4 sketch.
+	//   - all, if true, the detail lists also include the empty entries.
+	ToString(summary bool, detail bool, auxDetail bool, all bool) (string, error)
+
+	// String returns the summary of the sketch.
+	String() string
+
+	// MarshalBinary implements encoding.BinaryMarshaler, it returns the image of ToCompactSlice.
+	MarshalBinary() ([]byte, error)
+
+	// UnmarshalBinary implements encoding.BinaryUnmarshaler, it replaces the content of the sketch
+	// with the given image, which must have been produced with the same update seed.
+	UnmarshalBinary(data []byte) error
+
+	// GobEncode implements gob.GobEncoder, see MarshalBinary.
+	GobEncode() ([]byte, error)
+
+	// GobDecode implements gob.GobDecoder, see UnmarshalBinary.
+	GobDecode(data []byte) error
+
 	couponUpdate(coupon int) (hllSketchStateI, error)
 	iterator() pairIterator
 }
@@ -117,7 +171,7 @@ type hllSketchStateI interface {
 
 	GetLgConfigK() int
 	GetTgtHllType() TgtHllType
-	GetCurMode() curMode
+	GetCurMode() CurMode
 
 	GetUpdatableSerializationBytes() int
 	ToCompactSlice() ([]byte, error)
@@ -139,17 +193,62 @@ type hllSketchStateI interface {
 }
 
 type hllSketchState struct { // extends BaseHllSketch
-	sketch  hllSketchStateI
-	scratch [8]byte
+	sketch   hllSketchStateI
+	scratch  [8]byte
+	mem      []byte // backing image of a direct sketch, nil for heap sketches
+	seed     uint64
+	seedHash uint16
 }
 
-func newHllSketchState(coupon hllSketchStateI) HllSketch {
-	return &hllSketchState{
-		sketch:  coupon,
-		scratch: [8]byte{},
+// SketchOption configures optional parameters of the HllSketch and Union constructors and deserializers.
+type SketchOption func(*sketchOptions)
+
+type sketchOptions struct {
+	seed                uint64
+	maxConcurrencyError float64
+	localBufferSize     int
+}
+
+// WithUpdateSeed sets the seed of the hash function applied to the updated items.
+// Sketches built with different seeds cannot be merged. The seed is only kept in memory: the
+// serialized image keeps the format of the Java and C++ libraries and does not record the seed.
+// An image must be deserialized with the seed it was built with, which cannot be checked.
+func WithUpdateSeed(seed uint64) SketchOption {
+	return func(o *sketchOptions) {
+		o.seed = seed
 	}
 }
 
+// SeedHashMismatchError is returned when combining sketches built with a different update seed than
+// the one expected.
+type SeedHashMismatchError struct {
+	Expected uint16
+	Actual   uint16
+}
+
+func (e *SeedHashMismatchError) Error() string {
+	return fmt.Sprintf("incompatible seed hashes: expected %d, actual %d", e.Expected, e.Actual)
+}
+
+func newHllSketchState(coupon hllSketchStateI, opts ...SketchOption) (*hllSketchState, error) {
+	options := sketchOptions{
+		seed: internal.DEFAULT_UPDATE_SEED,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	seedHash, err := internal.ComputeSeedHash(options.seed)
+	if err != nil {
+		return nil, err
+	}
+	return &hllSketchState{
+		sketch:   coupon,
+		scratch:  [8]byte{},
+		seed:     options.seed,
+		seedHash: seedHash,
+	}, nil
+}
+
 // NewHllSketch constructs a new sketch with the type of HLL sketch to configure
 //
 //   - lgConfigK, the Log2 of K for the target HLL sketch. This value must be
@@ -157,38 +256,57 @@ func newHllSketchState(coupon hllSketchStateI) HllSketch {
 // between 4 and 21 inclusively.
 //
 //   - tgtHllType. the desired HLL type.
-func NewHllSketch(lgConfigK int, tgtHllType TgtHllType) (HllSketch, error) {
+//   - opts, optional parameters such as WithUpdateSeed.
+func NewHllSketch(lgConfigK int, tgtHllType TgtHllType, opts ...SketchOption) (HllSketch, error) {
 	lgK := lgConfigK
 	lgK, err := checkLgK(lgK)
 	if err != nil {
 		return nil, err
 	}
-	couponList, err := newCouponList(lgK, tgtHllType, curModeList)
+	couponList, err := newCouponList(lgK, tgtHllType, CurModeList)
 	if err != nil {
 		return nil, err
 	}
-	return newHllSketchState(&couponList), nil
+	sketch, err := newHllSketchState(&couponList, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return sketch, nil
 }
 
 // NewHllSketchWithLgK constructs a new on-heap sketch with the default tgtHllType.
 //
 //   - lgConfigK, the Log2 of K for the target HLL sketch. This value must be between 4 and 21 inclusively.
-func NewHllSketchWithLgK(lgConfigK int) (HllSketch, error) {
-	lgK, err := checkLgK(lgConfigK)
+//   - opts, optional parameters such as WithUpdateSeed.
+func NewHllSketchWithLgK(lgConfigK int, opts ...SketchOption) (HllSketch, error) {
+	return NewHllSketch(lgConfigK, TgtHllTypeDefault, opts...)
+}
+
+// NewHllSketchFromSlice deserialize a given byte slice, which must be a valid HllSketch image and may have data.
+//
+//   - bytes, the given byte slice, this slice is not modified and is not retained by the sketch
+//   - opts, optional parameters such as WithUpdateSeed, the seed the image was built with.
+func NewHllSketchFromSlice(bytes []byte, checkRebuild bool, opts ...SketchOption) (HllSketch, error) {
+	sketch, err := deserializeSketch(bytes)
 	if err != nil {
 		return nil, err
 	}
-	couponList, err := newCouponList(lgK, TgtHllTypeDefault, curModeList)
+	curMode := sketch.GetCurMode()
+	a, err := newHllSketchState(sketch, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return newHllSketchState(&couponList), nil
+	if checkRebuild && curMode == CurModeHll && sketch.GetTgtHllType() == TgtHllTypeHll8 {
+		if err := checkRebuildCurMinNumKxQ(a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
 }
 
-// NewHllSketchFromSlice deserialize a given byte slice, which must be a valid HllSketch image and may have data.
-//
-//   - bytes, the given byte slice, this slice is not modified and is not retained by the sketch
-func NewHllSketchFromSlice(bytes []byte, checkRebuild bool) (HllSketch, error) {
+// deserializeSketch checks the preamble of the given image and deserializes it according to its
+// current mode.
+func deserializeSketch(bytes []byte) (hllSketchStateI, error) {
 	if len(bytes) < 8 {
 		return nil, fmt.Errorf("input array too small: %d", len(bytes))
 	}
@@ -196,39 +314,18 @@ func NewHllSketchFromSlice(bytes []byte, checkRebuild bool) (HllSketch, error) {
 	if err != nil {
 		return nil, err
 	}
-	if curMode == curModeHll {
+	if curMode == CurModeHll {
 		tgtHllType := extractTgtHllType(bytes)
 		if tgtHllType == TgtHllTypeHll4 {
-			sk, err := deserializeHll4(bytes)
-			if err != nil {
-				return nil, err
-			}
-			return newHllSketchState(sk), nil
+			return deserializeHll4(bytes)
 		} else if tgtHllType == TgtHllTypeHll6 {
-			return newHllSketchState(deserializeHll6(bytes)), nil
-		} else {
-			a := newHllSketchState(deserializeHll8(bytes))
-			if checkRebuild {
-				err := checkRebuildCurMinNumKxQ(a)
-				if err != nil {
-					return nil, err
-				}
-			}
-			return a, nil
-		}
-	} else if curMode == curModeList {
-		cp, err := deserializeCouponList(bytes)
-		if err != nil {
-			return nil, err
-		}
-		return newHllSketchState(cp), nil
-	} else {
-		chs, err := deserializeCouponHashSet(bytes)
-		if err != nil {
-			return nil, err
+			return deserializeHll6(bytes)
 		}
-		return newHllSketchState(chs), nil
+		return deserializeHll8(bytes)
+	} else if curMode == CurModeList {
+		return deserializeCouponList(bytes)
 	}
+	return deserializeCouponHashSet(bytes)
 }
 
 func (h *hllSketchState) Copy() (HllSketch, error) {
@@ -236,7 +333,7 @@ func (h *hllSketchState) Copy() (HllSketch, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newHllSketchState(sketch), nil
+	return h.withSketch(sketch), nil
 }
 
 func (h *hllSketchState) CopyAs(tgtHllType TgtHllType) (HllSketch, error) {
@@ -244,7 +341,17 @@ func (h *hllSketchState) CopyAs(tgtHllType TgtHllType) (HllSketch, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newHllSketchState(sketch), nil
+	return h.withSketch(sketch), nil
+}
+
+// withSketch returns a new heap sketch holding the given state, with the same seed as this sketch.
+func (h *hllSketchState) withSketch(sketch hllSketchStateI) *hllSketchState {
+	return &hllSketchState{
+		sketch:   sketch,
+		scratch:  [8]byte{},
+		seed:     h.seed,
+		seedHash: h.seedHash,
+	}
 }
 
 func (h *hllSketchState) GetCompositeEstimate() (float64, error) {
@@ -259,6 +366,10 @@ func (h *hllSketchState) GetHipEstimate() (float64, error) {
 	return h.sketch.GetHipEstimate()
 }
 
+func (h *hllSketchState) IsOutOfOrder() bool {
+	return h.sketch.isOutOfOrder()
+}
+
 func (h *hllSketchState) GetUpperBound(numStdDev int) (float64, error) {
 	return h.sketch.GetUpperBound(numStdDev)
 }
@@ -294,6 +405,64 @@ func (h *hllSketchState) UpdateString(datum string) error {
 	return h.UpdateSlice(unsafe.Slice(unsafe.StringData(datum), len(datum)))
 }
 
+func (h *hllSketchState) UpdateHash(lo uint64, hi uint64) error {
+	_, err := h.couponUpdate(coupon(lo, hi))
+	return err
+}
+
+func (h *hllSketchState) UpdateUInt64s(data []uint64) error {
+	for _, datum := range data {
+		binary.LittleEndian.PutUint64(h.scratch[:], datum)
+		if err := h.batchCouponUpdate(coupon(h.hash(h.scratch[:]))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *hllSketchState) UpdateSlices(data [][]byte) error {
+	for _, datum := range data {
+		if len(datum) == 0 {
+			continue
+		}
+		if err := h.batchCouponUpdate(coupon(h.hash(datum))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *hllSketchState) UpdateStrings(data []string) error {
+	for _, datum := range data {
+		if len(datum) == 0 {
+			continue
+		}
+		// get a slice to the string data (avoiding a copy to heap)
+		if err := h.batchCouponUpdate(coupon(h.hash(unsafe.Slice(unsafe.StringData(datum), len(datum))))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchCouponUpdate is the couponUpdate of the batch update methods.
+// Once a heap sketch has reached HLL mode its state no longer changes, so the update of the
+// concrete HLL array is called directly.
+func (h *hllSketchState) batchCouponUpdate(coupon int) error {
+	if h.mem == nil {
+		switch sk := h.sketch.(type) {
+		case *hll4ArrayImpl:
+			return internalHll4Update(sk, coupon&((1<<sk.lgConfigK)-1), coupon>>keyBits26)
+		case *hll6ArrayImpl:
+			return sk.updateSlotWithKxQ(coupon&((1<<sk.lgConfigK)-1), coupon>>keyBits26)
+		case *hll8ArrayImpl:
+			return sk.updateSlotWithKxQ(coupon&((1<<sk.lgConfigK)-1), coupon>>keyBits26)
+		}
+	}
+	_, err := h.couponUpdate(coupon)
+	return err
+}
+
 func (h *hllSketchState) IsEmpty() bool {
 	return h.sketch.IsEmpty()
 }
@@ -314,7 +483,7 @@ func (h *hllSketchState) GetTgtHllType() TgtHllType {
 	return h.sketch.GetTgtHllType()
 }
 
-func (h *hllSketchState) GetCurMode() curMode {
+func (h *hllSketchState) GetCurMode() CurMode {
 	return h.sketch.GetCurMode()
 }
 
@@ -323,14 +492,21 @@ func (h *hllSketchState) Reset() error {
 	if err != nil {
 		return err
 	}
-	couponList, err := newCouponList(lgK, h.sketch.GetTgtHllType(), curModeList)
+	couponList, err := newCouponList(lgK, h.sketch.GetTgtHllType(), CurModeList)
 	if err != nil {
 		return err
 	}
 	h.sketch = &couponList
+	if h.mem != nil {
+		return h.writeDirectImage()
+	}
 	return nil
 }
 
+func (h *hllSketchState) Registers() *RegisterIterator {
+	return newRegisterIterator(h.sketch.iterator())
+}
+
 func (h *hllSketchState) iterator() pairIterator {
 	return h.sketch.iterator()
 }
@@ -346,6 +522,9 @@ func (h *hllSketchState) couponUpdate(coupon int) (hllSketchStateI, error) {
 	if (coupon >> keyBits26) == empty {
 		return h.sketch, nil
 	}
+	if h.mem != nil {
+		return h.directCouponUpdate(coupon)
+	}
 	sk, err := h.sketch.couponUpdate(coupon)
 	h.sketch = sk
 	return h.sketch, err
@@ -359,11 +538,42 @@ func (h *hllSketchState) mergeTo(dest HllSketch) error {
 	return h.sketch.mergeTo(dest)
 }
 
+func (h *hllSketchState) MarshalBinary() ([]byte, error) {
+	return h.ToCompactSlice()
+}
+
+// UnmarshalBinary replaces the content of the sketch with the given image, which is copied.
+// A direct sketch stays backed by its slice, into which the image is written.
+func (h *hllSketchState) UnmarshalBinary(data []byte) error {
+	// the registers of the deserialized sketch share the memory of the image
+	sk, err := NewHllSketchFromSlice(slices.Clone(data), true, WithUpdateSeed(h.seed))
+	if err != nil {
+		return err
+	}
+	prev := h.sketch
+	h.sketch = sk.(*hllSketchState).sketch
+	if h.mem != nil {
+		if err := h.writeDirectImage(); err != nil {
+			h.sketch = prev
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *hllSketchState) GobEncode() ([]byte, error) {
+	return h.MarshalBinary()
+}
+
+func (h *hllSketchState) GobDecode(data []byte) error {
+	return h.UnmarshalBinary(data)
+}
+
 // GetSerializationVersion returns the serialization version used by this sketch.
 func (h *hllSketchState) GetSerializationVersion() int {
 	return serVer
 }
 
 func (h *hllSketchState) hash(bs []byte) (uint64, uint64) {
-	return murmur3.SeedSum128(internal.DEFAULT_UPDATE_SEED, internal.DEFAULT_UPDATE_SEED, bs)
+	return murmur3.SeedSum128(h.seed, h.seed, bs)
 }
@@ -33,7 +33,7 @@ func TestIsomorphicUnionUpdatableHeap(t *testing.T) {
 			if (lgK < 8) && (cm == 1) { //lgk < 8 list transistions directly to HLL
 				continue
 			}
-			curMode := curMode(cm)
+			curMode := CurMode(cm)
 			for tt := 0; tt <= 2; tt++ { //HLL_4, HLL_6, HLL_8
 				tgtHllType1 := TgtHllType(tt)
 				sk1, err := buildHeapSketch(lgK, tgtHllType1, curMode)
@@ -62,7 +62,7 @@ func TestIsomorphicUnionCompactHeap(t *testing.T) {
 			if (lgK < 8) && (cm == 1) { //lgk < 8 list transistions directly to HLL
 				continue
 			}
-			curMode := curMode(cm)
+			curMode := CurMode(cm)
 			for tt := 0; tt <= 2; tt++ { //HLL_4, HLL_6, HLL_8
 				tgtHllType1 := TgtHllType(tt)
 				sk1, err := buildHeapSketch(lgK, tgtHllType1, curMode)
@@ -90,7 +90,7 @@ func TestIsomorphicCopyAsUpdatableHeap(t *testing.T) {
 			if (lgK < 8) && (cm == 1) { //lgk < 8 list transistions directly to HLL
 				continue
 			}
-			curMode := curMode(cm)
+			curMode := CurMode(cm)
 			for t1 := 0; t1 <= 2; t1++ { //HLL_4, HLL_6, HLL_8
 				tgtHllType1 := TgtHllType(t1)
 				sk1, err := buildHeapSketch(lgK, tgtHllType1, curMode)
@@ -164,7 +164,7 @@ func TestIsomorphicCopyAsCompactHeap(t *testing.T) {
 			if (lgK < 8) && (cm == 1) { //lgk < 8 list transistions directly to HLL
 				continue
 			}
-			curMode := curMode(cm)
+			curMode := CurMode(cm)
 			for t1 := 0; t1 <= 2; t1++ { //HLL_4, HLL_6, HLL_8
 				tgtHllType1 := TgtHllType(t1)
 				sk1, err := buildHeapSketch(lgK, tgtHllType1, curMode)
@@ -212,7 +212,7 @@ func buildHeapUnionHllMode(lgK int, startN int) (Union, error) {
 	if err != nil {
 		return nil, err
 	}
-	n := getN(lgK, curModeHll)
+	n := getN(lgK, CurModeHll)
 	for i := 0; i < n; i++ {
 		err = u.UpdateUInt64(uint64(i + startN))
 		if err != nil {
@@ -222,7 +222,7 @@ func buildHeapUnionHllMode(lgK int, startN int) (Union, error) {
 	return u, nil
 }
 
-func buildHeapSketch(lgK int, tgtHllType TgtHllType, curMode curMode) (HllSketch, error) {
+func buildHeapSketch(lgK int, tgtHllType TgtHllType, curMode CurMode) (HllSketch, error) {
 	sk, err := NewHllSketch(lgK, tgtHllType)
 	if err != nil {
 		return nil, err
@@ -243,7 +243,7 @@ func buildHeapSketchHllMode(lgK int, tgtHllType TgtHllType, startN int) (HllSket
 	if err != nil {
 		return nil, err
 	}
-	n := getN(lgK, curModeHll)
+	n := getN(lgK, CurModeHll)
 	for i := 0; i < n; i++ {
 		err = sk.UpdateUInt64(uint64(i + startN))
 		if err != nil {
@@ -254,14 +254,14 @@ func buildHeapSketchHllMode(lgK int, tgtHllType TgtHllType, startN int) (HllSket
 }
 
 // if lgK >= 8, curMode != SET!
-func getN(lgK int, curMode curMode) int {
-	if curMode == curModeList {
+func getN(lgK int, curMode CurMode) int {
+	if curMode == CurModeList {
 		return 4
 	}
-	if curMode == curModeSet {
+	if curMode == CurModeSet {
 		return 1 << (lgK - 4)
 	}
-	if (lgK < 8) && (curMode == curModeHll) {
+	if (lgK < 8) && (curMode == CurModeHll) {
 		return 1 << lgK
 	}
 	return 1 << (lgK - 3)
@@ -20,6 +20,7 @@ package hll
 import (
 	"fmt"
 	"os"
+	"slices"
 	"testing"
 
 	"github.com/apache/datasketches-go/internal"
@@ -72,7 +73,7 @@ func TestJavaCompat(t *testing.T) {
 			bytes, err := os.ReadFile(fmt.Sprintf("%s/hll4_n%d_java.sk", internal.JavaPath, n))
 			assert.NoError(t, err)
 			sketch, err := NewHllSketchFromSlice(bytes, true)
-			if err != nil {
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -90,7 +91,7 @@ func TestJavaCompat(t *testing.T) {
 			assert.NoError(t, err)
 
 			sketch, err := NewHllSketchFromSlice(bytes, true)
-			if err != nil {
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -107,7 +108,7 @@ func TestJavaCompat(t *testing.T) {
 			bytes, err := os.ReadFile(fmt.Sprintf("%s/hll8_n%d_java.sk", internal.JavaPath, n))
 			assert.NoError(t, err)
 			sketch, err := NewHllSketchFromSlice(bytes, true)
-			if err != nil {
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -126,7 +127,7 @@ func TestCppCompat(t *testing.T) {
 			bytes, err := os.ReadFile(fmt.Sprintf("%s/hll4_n%d_cpp.sk", internal.CppPath, n))
 			assert.NoError(t, err)
 			sketch, err := NewHllSketchFromSlice(bytes, true)
-			if err != nil {
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -144,7 +145,7 @@ func TestCppCompat(t *testing.T) {
 			assert.NoError(t, err)
 
 			sketch, err := NewHllSketchFromSlice(bytes, true)
-			if err != nil {
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -161,7 +162,7 @@ func TestCppCompat(t *testing.T) {
 			bytes, err := os.ReadFile(fmt.Sprintf("%s/hll8_n%d_cpp.sk", internal.CppPath, n))
 			assert.NoError(t, err)
 			sketch, err := NewHllSketchFromSlice(bytes, true)
-			if err != nil {
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -229,7 +230,7 @@ func TestGoCompat(t *testing.T) {
 
 			// clear compact flag for C++ sketches when in HLL mode tgt6
 			// as that flag is irrelevant but set in this case
-			if extractCurMode(bytes) == curModeHll {
+			if extractCurMode(bytes) == CurModeHll {
 				bytes[5] = clearCompactFlag(bytes[5])
 			}
 			assert.Equal(t, bytes, sl6, "n: %d", n)
@@ -243,7 +244,7 @@ func TestGoCompat(t *testing.T) {
 
 			// clear compact flag for C++ sketches when in HLL mode tgt8
 			// as that flag is irrelevant but set in this case
-			if extractCurMode(bytes) == curModeHll {
+			if extractCurMode(bytes) == CurModeHll {
 				bytes[5] = clearCompactFlag(bytes[5])
 			}
 			assert.Equal(t, bytes, sl8)
@@ -251,6 +252,169 @@ func TestGoCompat(t *testing.T) {
 	}
 }
 
+func TestSeededImageFormat(t *testing.T) {
+	nArr := []int{0, 1, 10, 100, 1000, 10000, 100000, 1000000}
+	for _, n := range nArr {
+		def, err := NewHllSketch(defaultLgK, TgtHllTypeHll4, WithUpdateSeed(internal.DEFAULT_UPDATE_SEED))
+		assert.NoError(t, err)
+		seeded, err := NewHllSketch(defaultLgK, TgtHllTypeHll4, WithUpdateSeed(123))
+		assert.NoError(t, err)
+		for i := 0; i < n; i++ {
+			assert.NoError(t, def.UpdateUInt64(uint64(i)))
+			assert.NoError(t, seeded.UpdateUInt64(uint64(i)))
+		}
+
+		// an explicit default seed gives the image of the Java library
+		sl, err := def.ToCompactSlice()
+		assert.NoError(t, err)
+		bytes, err := os.ReadFile(fmt.Sprintf("%s/hll4_n%d_java.sk", internal.JavaPath, n))
+		assert.NoError(t, err)
+		assert.Equal(t, bytes, sl)
+
+		// another seed keeps the preamble of the standard format
+		seededSl, err := seeded.ToCompactSlice()
+		assert.NoError(t, err)
+		assert.Equal(t, sl[:flagsByte+1], seededSl[:flagsByte+1])
+		_, err = NewHllSketchFromSlice(seededSl, true, WithUpdateSeed(123))
+		assert.NoError(t, err)
+	}
+}
+
 func clearCompactFlag(flags byte) byte {
 	return flags & ^(uint8(1) << 3)
 }
+
+// addHllFuzzSeeds adds the compatibility images and updatable images of every mode to the corpus.
+func addHllFuzzSeeds(f *testing.F) {
+	for _, dir := range []string{internal.JavaPath, internal.CppPath} {
+		for _, n := range []int{0, 1, 10, 100, 1000, 10000} {
+			for _, name := range []string{"hll4", "hll6", "hll8"} {
+				suffix := "java"
+				if dir == internal.CppPath {
+					suffix = "cpp"
+				}
+				bytes, err := os.ReadFile(fmt.Sprintf("%s/%s_n%d_%s.sk", dir, name, n, suffix))
+				assert.NoError(f, err)
+				f.Add(bytes)
+			}
+		}
+	}
+	for _, tgtHllType := range []TgtHllType{TgtHllTypeHll4, TgtHllTypeHll6, TgtHllTypeHll8} {
+		for _, n := range []int{1, 100, 1000} {
+			sketch, err := NewHllSketch(8, tgtHllType)
+			assert.NoError(f, err)
+			for i := 0; i < n; i++ {
+				assert.NoError(f, sketch.UpdateUInt64(uint64(i)))
+			}
+			slc, err := sketch.ToUpdatableSlice()
+			assert.NoError(f, err)
+			f.Add(slc)
+		}
+	}
+}
+
+func FuzzHllSketchFromSlice(f *testing.F) {
+	addHllFuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		sketch, err := NewHllSketchFromSlice(b, true)
+		if err != nil {
+			return
+		}
+		if _, err := sketch.GetEstimate(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sketch.ToCompactSlice(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sketch.ToUpdatableSlice(); err != nil {
+			t.Fatal(err)
+		}
+		union, err := NewUnion(sketch.GetLgConfigK())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = union.UpdateSketch(sketch)
+		_ = sketch.UpdateUInt64(1)
+	})
+}
+
+func FuzzUnionFromSlice(f *testing.F) {
+	addHllFuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		union, err := NewUnionFromSlice(b)
+		if err != nil {
+			return
+		}
+		if _, err := union.GetCompositeEstimate(); err != nil {
+			t.Fatal(err)
+		}
+		for _, tgtHllType := range []TgtHllType{TgtHllTypeHll4, TgtHllTypeHll6, TgtHllTypeHll8} {
+			result, err := union.GetResult(tgtHllType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := result.ToCompactSlice(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := union.UpdateUInt64(1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := union.ToUpdatableSlice(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzWrapSketch(f *testing.F) {
+	addHllFuzzSeeds(f)
+	for _, tgtHllType := range []TgtHllType{TgtHllTypeHll4, TgtHllTypeHll6, TgtHllTypeHll8} {
+		for _, n := range []int{0, 10, 100, 1000} {
+			mem := make([]byte, getMaxUpdatableSerializationBytes(8, tgtHllType))
+			sketch, err := NewDirectHllSketch(8, tgtHllType, mem)
+			assert.NoError(f, err)
+			for i := 0; i < n; i++ {
+				assert.NoError(f, sketch.UpdateUInt64(uint64(i)))
+			}
+			f.Add(mem)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		// the sketch writes to the slice, the input of the fuzzer must not be modified
+		mem := slices.Clone(b)
+		sketch, err := WrapSketch(mem)
+		if err != nil {
+			return
+		}
+		if _, err := sketch.GetEstimate(); err != nil {
+			t.Fatal(err)
+		}
+		compact, err := sketch.ToCompactSlice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewHllSketchFromSlice(compact, true); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sketch.ToUpdatableSlice(); err != nil {
+			t.Fatal(err)
+		}
+		union, err := NewUnion(sketch.GetLgConfigK())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = union.UpdateSketch(sketch)
+		// updates may run out of memory on promotion, they must not corrupt the slice
+		for i := 0; i < 100; i++ {
+			if err := sketch.UpdateUInt64(uint64(i)); err != nil {
+				break
+			}
+		}
+		if _, err := sketch.GetEstimate(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -18,12 +18,19 @@
 package hll
 
 import (
+	"bytes"
+	"encoding"
 	"encoding/binary"
+	"encoding/gob"
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/apache/datasketches-go/internal"
 	"github.com/stretchr/testify/assert"
+	"github.com/twmb/murmur3"
 )
 
 func TestMisc(t *testing.T) {
@@ -71,11 +78,11 @@ func checkCopy(t *testing.T, lgK int, tgtHllType TgtHllType) {
 		err := sk.UpdateInt64(int64(i))
 		assert.NoError(t, err)
 	}
-	assert.Equal(t, curModeList, sk.GetCurMode())
+	assert.Equal(t, CurModeList, sk.GetCurMode())
 
 	skCopy, err := sk.Copy()
 	assert.NoError(t, err)
-	assert.Equal(t, curModeList, skCopy.GetCurMode())
+	assert.Equal(t, CurModeList, skCopy.GetCurMode())
 
 	impl1 := sk.(*hllSketchState).sketch
 	impl2 := skCopy.(*hllSketchState).sketch
@@ -94,10 +101,10 @@ func checkCopy(t *testing.T, lgK int, tgtHllType TgtHllType) {
 		assert.NoError(t, err)
 	}
 
-	assert.Equal(t, curModeSet, sk.GetCurMode())
+	assert.Equal(t, CurModeSet, sk.GetCurMode())
 	skCopy, err = sk.Copy()
 	assert.NoError(t, err)
-	assert.Equal(t, curModeSet, skCopy.GetCurMode())
+	assert.Equal(t, CurModeSet, skCopy.GetCurMode())
 
 	impl1 = sk.(*hllSketchState).sketch
 	impl2 = skCopy.(*hllSketchState).sketch
@@ -119,10 +126,10 @@ func checkCopy(t *testing.T, lgK int, tgtHllType TgtHllType) {
 		assert.NoError(t, err)
 	}
 
-	assert.Equal(t, curModeHll, sk.GetCurMode())
+	assert.Equal(t, CurModeHll, sk.GetCurMode())
 	skCopy, err = sk.Copy()
 	assert.NoError(t, err)
-	assert.Equal(t, curModeHll, skCopy.GetCurMode())
+	assert.Equal(t, CurModeHll, skCopy.GetCurMode())
 
 	impl1 = sk.(*hllSketchState).sketch
 	impl2 = skCopy.(*hllSketchState).sketch
@@ -420,3 +427,407 @@ func TestHLL4RawStoredOldNibbleAndShiftedNewValueAuxToken(t *testing.T) {
 	err := hll.UpdateUInt64(29197004)
 	assert.NoError(t, err)
 }
+
+func TestUpdateSeed(t *testing.T) {
+	for _, tgtHllType := range []TgtHllType{TgtHllTypeHll4, TgtHllTypeHll6, TgtHllTypeHll8} {
+		for _, n := range []int{0, 5, 100, 10000} {
+			checkUpdateSeed(t, tgtHllType, n)
+		}
+	}
+}
+
+func checkUpdateSeed(t *testing.T, tgtHllType TgtHllType, n int) {
+	seeded, err := NewHllSketch(10, tgtHllType, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	def, err := NewHllSketch(10, tgtHllType)
+	assert.NoError(t, err)
+	for i := 0; i < n; i++ {
+		assert.NoError(t, seeded.UpdateInt64(int64(i)))
+		assert.NoError(t, def.UpdateInt64(int64(i)))
+	}
+
+	compact, err := seeded.ToCompactSlice()
+	assert.NoError(t, err)
+	updatable, err := seeded.ToUpdatableSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, seeded.GetUpdatableSerializationBytes(), len(updatable))
+	defCompact, err := def.ToCompactSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, len(defCompact), len(compact))
+	// the seed is not part of the image, so the flags match the default sketch
+	assert.Equal(t, defCompact[flagsByte], compact[flagsByte])
+
+	for _, bytes := range [][]byte{compact, updatable} {
+		sk, err := NewHllSketchFromSlice(bytes, true, WithUpdateSeed(123))
+		assert.NoError(t, err)
+		est1, err := sk.GetEstimate()
+		assert.NoError(t, err)
+		est2, err := seeded.GetEstimate()
+		assert.NoError(t, err)
+		assert.Equal(t, est2, est1)
+
+		// updates after deserialization keep using the seed of the image
+		sk1, err := seeded.Copy()
+		assert.NoError(t, err)
+		assert.NoError(t, sk.UpdateString("abc"))
+		assert.NoError(t, sk1.UpdateString("abc"))
+		b, err := sk.ToCompactSlice()
+		assert.NoError(t, err)
+		b1, err := sk1.ToCompactSlice()
+		assert.NoError(t, err)
+		assert.Equal(t, b1, b)
+	}
+}
+
+func TestBatchUpdates(t *testing.T) {
+	for _, tgtHllType := range []TgtHllType{TgtHllTypeHll4, TgtHllTypeHll6, TgtHllTypeHll8} {
+		for _, n := range []int{0, 5, 100, 100000} {
+			checkBatchUpdates(t, tgtHllType, n)
+		}
+	}
+}
+
+func checkBatchUpdates(t *testing.T, tgtHllType TgtHllType, n int) {
+	uints := make([]uint64, n)
+	strs := make([]string, n)
+	slices := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		uints[i] = uint64(i)
+		strs[i] = strconv.Itoa(i)
+		slices[i] = []byte(strs[i])
+	}
+	strs = append(strs, "")
+	slices = append(slices, nil, []byte{})
+
+	single, err := NewHllSketch(12, tgtHllType)
+	assert.NoError(t, err)
+	batch, err := NewHllSketch(12, tgtHllType)
+	assert.NoError(t, err)
+	union, err := NewUnion(12)
+	assert.NoError(t, err)
+	// split the batches to go through the mode transitions
+	half := n / 2
+	for _, r := range [][2]int{{0, half}, {half, n}} {
+		for i := r[0]; i < r[1]; i++ {
+			assert.NoError(t, single.UpdateUInt64(uints[i]))
+		}
+		for i := r[0]; i < r[1]; i++ {
+			assert.NoError(t, single.UpdateString(strs[i]))
+		}
+		for i := r[0]; i < r[1]; i++ {
+			assert.NoError(t, single.UpdateSlice(slices[i]))
+		}
+	}
+	for _, sk := range []interface {
+		UpdateUInt64s(data []uint64) error
+		UpdateSlices(data [][]byte) error
+		UpdateStrings(data []string) error
+	}{batch, union} {
+		assert.NoError(t, sk.UpdateUInt64s(uints[:half]))
+		assert.NoError(t, sk.UpdateStrings(strs[:half]))
+		assert.NoError(t, sk.UpdateSlices(slices[:half]))
+		assert.NoError(t, sk.UpdateUInt64s(uints[half:]))
+		assert.NoError(t, sk.UpdateStrings(strs[half:]))
+		assert.NoError(t, sk.UpdateSlices(slices[half:]))
+	}
+
+	singleBytes, err := single.ToCompactSlice()
+	assert.NoError(t, err)
+	batchBytes, err := batch.ToCompactSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, singleBytes, batchBytes)
+
+	singleEst, err := single.GetEstimate()
+	assert.NoError(t, err)
+	unionEst, err := union.GetEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, singleEst, unionEst)
+}
+
+func BenchmarkHLLBatchUpdate(b *testing.B) {
+	const batchSize = 1024
+	strs := make([]string, 1<<16)
+	for i := range strs {
+		strs[i] = strconv.Itoa(i)
+	}
+
+	for _, tgtHllType := range []TgtHllType{TgtHllTypeHll4, TgtHllTypeHll6, TgtHllTypeHll8} {
+		name := fmt.Sprintf("HLL%d", 4+2*int(tgtHllType))
+
+		b.Run(name+" single uint", func(b *testing.B) {
+			hll, _ := NewHllSketch(16, tgtHllType)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = hll.UpdateUInt64(uint64(i))
+			}
+		})
+		b.Run(name+" batch uint", func(b *testing.B) {
+			hll, _ := NewHllSketch(16, tgtHllType)
+			batch := make([]uint64, batchSize)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i += batchSize {
+				for j := range batch {
+					batch[j] = uint64(i + j)
+				}
+				_ = hll.UpdateUInt64s(batch[:min(batchSize, b.N-i)])
+			}
+		})
+		b.Run(name+" single string", func(b *testing.B) {
+			hll, _ := NewHllSketch(16, tgtHllType)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = hll.UpdateString(strs[i&(len(strs)-1)])
+			}
+		})
+		b.Run(name+" batch string", func(b *testing.B) {
+			hll, _ := NewHllSketch(16, tgtHllType)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i += batchSize {
+				j := i & (len(strs) - 1)
+				_ = hll.UpdateStrings(strs[j : j+min(batchSize, b.N-i)])
+			}
+		})
+	}
+}
+
+func TestUpdateHash(t *testing.T) {
+	for _, tgtHllType := range []TgtHllType{TgtHllTypeHll4, TgtHllTypeHll6, TgtHllTypeHll8} {
+		items, err := NewHllSketch(12, tgtHllType)
+		assert.NoError(t, err)
+		hashes, err := NewHllSketch(12, tgtHllType)
+		assert.NoError(t, err)
+		union, err := NewUnion(12)
+		assert.NoError(t, err)
+
+		var scratch [8]byte
+		for i := 0; i < 20000; i++ {
+			datum := []byte(strconv.Itoa(i))
+			assert.NoError(t, items.UpdateSlice(datum))
+			lo, hi := murmur3.SeedSum128(internal.DEFAULT_UPDATE_SEED, internal.DEFAULT_UPDATE_SEED, datum)
+			assert.NoError(t, hashes.UpdateHash(lo, hi))
+			assert.NoError(t, union.UpdateHash(lo, hi))
+
+			assert.NoError(t, items.UpdateUInt64(uint64(i)))
+			binary.LittleEndian.PutUint64(scratch[:], uint64(i))
+			lo, hi = murmur3.SeedSum128(internal.DEFAULT_UPDATE_SEED, internal.DEFAULT_UPDATE_SEED, scratch[:])
+			assert.NoError(t, hashes.UpdateHash(lo, hi))
+			assert.NoError(t, union.UpdateHash(lo, hi))
+		}
+
+		itemsBytes, err := items.ToCompactSlice()
+		assert.NoError(t, err)
+		hashesBytes, err := hashes.ToCompactSlice()
+		assert.NoError(t, err)
+		assert.Equal(t, itemsBytes, hashesBytes)
+
+		est, err := items.GetEstimate()
+		assert.NoError(t, err)
+		unionEst, err := union.GetEstimate()
+		assert.NoError(t, err)
+		assert.Equal(t, est, unionEst)
+	}
+}
+
+func TestToString(t *testing.T) {
+	sk, err := NewHllSketch(4, TgtHllTypeHll4)
+	assert.NoError(t, err)
+	assert.Contains(t, sk.String(), "Current Mode   : LIST")
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, sk.UpdateInt64(int64(i)))
+	}
+	s, err := sk.ToString(true, true, false, false)
+	assert.NoError(t, err)
+	assert.Contains(t, s, "### HLL SKETCH SUMMARY")
+	assert.Contains(t, s, "Hll Target     : HLL_4")
+	assert.Contains(t, s, "Coupon Count   : 5")
+	assert.Contains(t, s, "### COUPON DATA DETAIL")
+	assert.Equal(t, 5, strings.Count(s[strings.Index(s, "### COUPON DATA DETAIL"):], "\n")-2)
+
+	for i := 5; i < 10000; i++ {
+		assert.NoError(t, sk.UpdateInt64(int64(i)))
+	}
+	// force an exception into the aux hash map
+	_, err = sk.couponUpdate(pair(3, 40))
+	assert.NoError(t, err)
+	s, err = sk.ToString(true, true, true, true)
+	assert.NoError(t, err)
+	est, err := sk.GetEstimate()
+	assert.NoError(t, err)
+	assert.Contains(t, s, "Current Mode   : HLL")
+	assert.Contains(t, s, fmt.Sprintf("Estimate       : %f", est))
+	assert.Contains(t, s, "CurMin         : ")
+	assert.Contains(t, s, "HipAccum       : ")
+	assert.Contains(t, s, "KxQ0           : ")
+	assert.Contains(t, s, "### HLL SKETCH DATA DETAIL")
+	assert.Contains(t, s, "### HLL AUX DETAIL")
+
+	s, err = sk.ToString(false, false, false, false)
+	assert.NoError(t, err)
+	assert.Empty(t, s)
+
+	union, err := NewUnion(4)
+	assert.NoError(t, err)
+	assert.NoError(t, union.UpdateSketch(sk))
+	assert.NoError(t, union.UpdateSketch(sk))
+	assert.Contains(t, union.String(), "### HLL UNION: lgMaxK=4")
+	assert.Contains(t, union.String(), "OutOfOrder Flag: true")
+}
+
+func TestUnmarshalBinaryCopiesImage(t *testing.T) {
+	for _, tgtHllType := range []TgtHllType{TgtHllTypeHll4, TgtHllTypeHll6, TgtHllTypeHll8} {
+		for _, n := range []int{5, 10000} {
+			sk, err := NewHllSketch(10, tgtHllType)
+			assert.NoError(t, err)
+			for i := 0; i < n; i++ {
+				assert.NoError(t, sk.UpdateInt64(int64(i)))
+			}
+			data, err := sk.MarshalBinary()
+			assert.NoError(t, err)
+			image := slices.Clone(data)
+
+			decoded, err := NewHllSketch(4, TgtHllTypeHll4)
+			assert.NoError(t, err)
+			assert.NoError(t, decoded.UnmarshalBinary(data))
+			clear(data)
+			decodedImage, err := decoded.MarshalBinary()
+			assert.NoError(t, err)
+			assert.Equal(t, image, decodedImage)
+
+			union, err := NewUnion(10)
+			assert.NoError(t, err)
+			assert.NoError(t, union.UpdateSketch(decoded))
+			unionData, err := union.MarshalBinary()
+			assert.NoError(t, err)
+			unionImage := slices.Clone(unionData)
+			decodedUnion, err := NewUnion(4)
+			assert.NoError(t, err)
+			assert.NoError(t, decodedUnion.UnmarshalBinary(unionData))
+			clear(unionData)
+			decodedImage, err = decodedUnion.MarshalBinary()
+			assert.NoError(t, err)
+			assert.Equal(t, unionImage, decodedImage)
+		}
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, n := range []int{0, 5, 100, 10000} {
+		sk, err := NewHllSketch(12, TgtHllTypeHll6)
+		assert.NoError(t, err)
+		for i := 0; i < n; i++ {
+			assert.NoError(t, sk.UpdateInt64(int64(i)))
+		}
+		var _ encoding.BinaryMarshaler = sk
+		data, err := sk.MarshalBinary()
+		assert.NoError(t, err)
+		compact, err := sk.ToCompactSlice()
+		assert.NoError(t, err)
+		assert.Equal(t, compact, data)
+
+		// gob round trip
+		var buf bytes.Buffer
+		assert.NoError(t, gob.NewEncoder(&buf).Encode(sk))
+		decoded, err := NewHllSketch(4, TgtHllTypeHll4)
+		assert.NoError(t, err)
+		assert.NoError(t, gob.NewDecoder(&buf).Decode(decoded))
+		assert.Equal(t, 12, decoded.GetLgConfigK())
+		assert.Equal(t, TgtHllTypeHll6, decoded.GetTgtHllType())
+		est1, err := sk.GetEstimate()
+		assert.NoError(t, err)
+		est2, err := decoded.GetEstimate()
+		assert.NoError(t, err)
+		assert.Equal(t, est1, est2)
+	}
+
+	sk, err := NewHllSketch(12, TgtHllTypeHll4, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	assert.NoError(t, sk.UpdateInt64(1))
+	data, err := sk.MarshalBinary()
+	assert.NoError(t, err)
+	// the receiver keeps its seed for the updates after unmarshalling
+	other, err := NewHllSketch(12, TgtHllTypeHll4, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	assert.NoError(t, other.UnmarshalBinary(data))
+	assert.NoError(t, sk.UpdateInt64(2))
+	assert.NoError(t, other.UpdateInt64(2))
+	b, err := sk.MarshalBinary()
+	assert.NoError(t, err)
+	b1, err := other.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, b, b1)
+	assert.Error(t, other.UnmarshalBinary(data[:4]))
+
+	// a direct sketch stays direct
+	mem := make([]byte, getMaxUpdatableSerializationBytes(12, TgtHllTypeHll4))
+	direct, err := NewDirectHllSketch(12, TgtHllTypeHll4, mem)
+	assert.NoError(t, err)
+	heap, err := NewHllSketch(12, TgtHllTypeHll8)
+	assert.NoError(t, err)
+	for i := 0; i < 5000; i++ {
+		assert.NoError(t, heap.UpdateInt64(int64(i)))
+	}
+	data, err = heap.MarshalBinary()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, direct.UnmarshalBinary(data), ErrInsufficientMemory)
+	assert.True(t, direct.IsEmpty())
+	heap, err = heap.CopyAs(TgtHllTypeHll4)
+	assert.NoError(t, err)
+	data, err = heap.MarshalBinary()
+	assert.NoError(t, err)
+	assert.NoError(t, direct.UnmarshalBinary(data))
+	wrapped, err := WrapSketch(mem)
+	assert.NoError(t, err)
+	est1, err := heap.GetEstimate()
+	assert.NoError(t, err)
+	est2, err := wrapped.GetEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, est1, est2)
+}
+
+func TestInspectImage(t *testing.T) {
+	sk, err := NewHllSketch(12, TgtHllTypeHll6)
+	assert.NoError(t, err)
+	for _, n := range []int{0, 5, 1000, 10000} {
+		for i := 0; i < n; i++ {
+			assert.NoError(t, sk.UpdateInt64(int64(i)))
+		}
+		slc, err := sk.ToCompactSlice()
+		assert.NoError(t, err)
+		info, err := InspectImage(slc)
+		assert.NoError(t, err)
+		assert.True(t, info.FullPreamble)
+		assert.Equal(t, 12, info.LgK)
+		assert.Equal(t, TgtHllTypeHll6, info.TgtHllType)
+		assert.Equal(t, sk.GetCurMode(), info.CurMode)
+		switch info.CurMode {
+		case CurModeList:
+			assert.Equal(t, 8, info.PreambleBytes)
+			assert.Equal(t, n, info.ListCount)
+		case CurModeSet:
+			assert.Equal(t, 12, info.PreambleBytes)
+			assert.Equal(t, 1000, info.HashSetCount)
+		case CurModeHll:
+			assert.Equal(t, 40, info.PreambleBytes)
+			est, err := sk.GetEstimate()
+			assert.NoError(t, err)
+			assert.Equal(t, est, info.HipAccum)
+		}
+	}
+
+	// a truncated image keeps its preamble but fails the deserialization
+	slc, err := sk.ToCompactSlice()
+	assert.NoError(t, err)
+	info, err := InspectImage(slc[:len(slc)/2])
+	assert.Error(t, err)
+	assert.True(t, info.FullPreamble)
+	assert.Equal(t, CurModeHll, info.CurMode)
+
+	slc[preambleIntsBytes] = listPreInts
+	info, err = InspectImage(slc)
+	assert.Error(t, err)
+	assert.False(t, info.FullPreamble)
+	assert.Equal(t, 8, info.PreambleBytes)
+	_, err = InspectImage(slc[:4])
+	assert.Error(t, err)
+}
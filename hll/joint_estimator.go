@@ -0,0 +1,425 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hll
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+const (
+	// maxRegisterValue is the largest value of a register, see coupon()
+	maxRegisterValue = 63
+	minLgRate        = -40.0
+	maxLgRate        = 60.0
+)
+
+// JointEstimate is the result of an estimation involving two sketches, see IntersectionEstimate and Jaccard.
+type JointEstimate struct {
+	estimate   float64
+	stdErr     float64 // approximate standard error of the estimate
+	lowerLimit float64 // the bounds are clipped to [lowerLimit, upperLimit]
+	upperLimit float64
+}
+
+// GetEstimate returns the estimate.
+func (e JointEstimate) GetEstimate() float64 {
+	return e.estimate
+}
+
+// GetLowerBound returns the approximate lower error bound given the specified number of standard deviations.
+//
+//   - numStdDev, this must be an integer between 1 and 3, inclusive.
+func (e JointEstimate) GetLowerBound(numStdDev int) (float64, error) {
+	if err := checkNumStdDev(numStdDev); err != nil {
+		return 0, err
+	}
+	return max(e.estimate-float64(numStdDev)*e.stdErr, e.lowerLimit), nil
+}
+
+// GetUpperBound returns the approximate upper error bound given the specified number of standard deviations.
+//
+//   - numStdDev, this must be an integer between 1 and 3, inclusive.
+func (e JointEstimate) GetUpperBound(numStdDev int) (float64, error) {
+	if err := checkNumStdDev(numStdDev); err != nil {
+		return 0, err
+	}
+	return min(e.estimate+float64(numStdDev)*e.stdErr, e.upperLimit), nil
+}
+
+// IntersectionEstimate estimates the number of distinct items present in both the given sketches,
+// which may have different lgConfigK and TgtHllType. Unions can be compared through their GetResult.
+//
+// While both sketches are in LIST or SET mode the intersection of their coupons is counted.
+// Otherwise, the registers of both sketches, folded to the smaller lgConfigK, are paired and the
+// cardinalities of A\B, B\A and A∩B are jointly estimated by maximum likelihood, following
+// Otmar Ertl, "New cardinality estimation algorithms for HyperLogLog sketches", 2017.
+// This is much more accurate than inclusion–exclusion over unions when the intersection is small.
+// The bounds are derived from the relative error of the union of the sketches, which makes them
+// conservative, and the estimate and the bounds are clamped to the estimate of the smaller sketch.
+func IntersectionEstimate(a, b HllSketch) (JointEstimate, error) {
+	j, err := newJointCounts(a, b)
+	if err != nil {
+		return JointEstimate{}, err
+	}
+	return j.intersection()
+}
+
+// Jaccard estimates the Jaccard similarity |A∩B| / |A∪B| of the given sketches, see IntersectionEstimate.
+// The similarity of two empty sketches is 1.
+func Jaccard(a, b HllSketch) (JointEstimate, error) {
+	j, err := newJointCounts(a, b)
+	if err != nil {
+		return JointEstimate{}, err
+	}
+	return j.jaccard()
+}
+
+// jointCounts holds the statistics of two sketches needed by the joint estimators.
+type jointCounts struct {
+	emptyA, emptyB bool
+
+	// coupon mode: the number of coupons of each sketch and in common
+	isCoupon                bool
+	countA, countB, countAB int
+
+	// hll mode: the number of register pairs for each pair of values and the estimates of the sketches
+	lgK        int
+	pairs      []registerPairCount
+	estA, estB float64
+}
+
+type registerPairCount struct {
+	valueA, valueB int
+	count          float64
+}
+
+func newJointCounts(a, b HllSketch) (*jointCounts, error) {
+	stateA, ok := a.(*hllSketchState)
+	if !ok {
+		return nil, fmt.Errorf("unsupported sketch type: %T", a)
+	}
+	stateB, ok := b.(*hllSketchState)
+	if !ok {
+		return nil, fmt.Errorf("unsupported sketch type: %T", b)
+	}
+	if stateA.seedHash != stateB.seedHash {
+		return nil, &SeedHashMismatchError{Expected: stateA.seedHash, Actual: stateB.seedHash}
+	}
+	j := &jointCounts{
+		emptyA: a.IsEmpty(),
+		emptyB: b.IsEmpty(),
+		lgK:    min(a.GetLgConfigK(), b.GetLgConfigK()),
+	}
+	if j.emptyA || j.emptyB {
+		return j, nil
+	}
+	if a.GetCurMode() != CurModeHll && b.GetCurMode() != CurModeHll {
+		return j, j.countCoupons(a, b)
+	}
+	var err error
+	if j.estA, err = a.GetEstimate(); err != nil {
+		return nil, err
+	}
+	if j.estB, err = b.GetEstimate(); err != nil {
+		return nil, err
+	}
+	return j, j.countRegisters(a, b)
+}
+
+func (j *jointCounts) countCoupons(a, b HllSketch) error {
+	j.isCoupon = true
+	couponsA := make(map[int]struct{})
+	itr := a.iterator()
+	for itr.nextValid() {
+		p, err := itr.getPair()
+		if err != nil {
+			return err
+		}
+		couponsA[p] = struct{}{}
+	}
+	j.countA = len(couponsA)
+	itr = b.iterator()
+	for itr.nextValid() {
+		p, err := itr.getPair()
+		if err != nil {
+			return err
+		}
+		j.countB++
+		if _, ok := couponsA[p]; ok {
+			j.countAB++
+		}
+	}
+	return nil
+}
+
+func (j *jointCounts) countRegisters(a, b HllSketch) error {
+	regA, err := foldedRegisters(a, j.lgK)
+	if err != nil {
+		return err
+	}
+	regB, err := foldedRegisters(b, j.lgK)
+	if err != nil {
+		return err
+	}
+	var counts [maxRegisterValue + 1][maxRegisterValue + 1]int
+	for slot := range regA {
+		counts[regA[slot]][regB[slot]]++
+	}
+	for va := range counts {
+		for vb, c := range counts[va] {
+			if c > 0 {
+				j.pairs = append(j.pairs, registerPairCount{valueA: va, valueB: vb, count: float64(c)})
+			}
+		}
+	}
+	return nil
+}
+
+// foldedRegisters returns the register values of the sketch for the given lgK, which must not
+// be greater than the lgConfigK of the sketch.
+func foldedRegisters(sketch HllSketch, lgK int) ([]byte, error) {
+	regs := make([]byte, 1<<lgK)
+	mask := (1 << lgK) - 1
+	itr := sketch.iterator()
+	for itr.nextAll() { // the HLL_4 iterator only supports nextAll, empty pairs have a zero value
+		v, err := itr.getValue()
+		if err != nil {
+			return nil, err
+		}
+		slot := itr.getKey() & mask
+		regs[slot] = max(regs[slot], byte(v))
+	}
+	return regs, nil
+}
+
+func (j *jointCounts) intersection() (JointEstimate, error) {
+	if j.emptyA || j.emptyB {
+		return JointEstimate{}, nil
+	}
+	if j.isCoupon {
+		est := float64(j.countAB)
+		return JointEstimate{
+			estimate:   est,
+			stdErr:     est * couponRSE,
+			lowerLimit: est,
+			upperLimit: float64(min(j.countA, j.countB)),
+		}, nil
+	}
+	estA, estB, estX := j.maximumLikelihood()
+	stdErr, err := j.stdErr(estA + estB + estX)
+	if err != nil {
+		return JointEstimate{}, err
+	}
+	// the intersection cannot be larger than the smaller sketch
+	upperLimit := min(j.estA, j.estB)
+	return JointEstimate{
+		estimate:   min(max(estX, 0), upperLimit),
+		stdErr:     stdErr,
+		upperLimit: upperLimit,
+	}, nil
+}
+
+func (j *jointCounts) jaccard() (JointEstimate, error) {
+	if j.emptyA && j.emptyB {
+		return JointEstimate{estimate: 1, lowerLimit: 1, upperLimit: 1}, nil
+	}
+	if j.emptyA || j.emptyB {
+		return JointEstimate{}, nil
+	}
+	if j.isCoupon {
+		est := float64(j.countAB) / float64(j.countA+j.countB-j.countAB)
+		return JointEstimate{estimate: est, stdErr: est * couponRSE, upperLimit: 1}, nil
+	}
+	estA, estB, estX := j.maximumLikelihood()
+	union := estA + estB + estX
+	stdErr, err := j.stdErr(union)
+	if err != nil {
+		return JointEstimate{}, err
+	}
+	return JointEstimate{estimate: estX / union, stdErr: stdErr / union, upperLimit: 1}, nil
+}
+
+// stdErr returns the approximate standard error of an intersection estimate given the
+// cardinality of the union.
+func (j *jointCounts) stdErr(union float64) (float64, error) {
+	relErr, err := getRelErrAllK(true, true, j.lgK, 1)
+	if err != nil {
+		return 0, err
+	}
+	return relErr * union, nil
+}
+
+// maximumLikelihood returns the estimated cardinalities of A\B, B\A and A∩B.
+func (j *jointCounts) maximumLikelihood() (float64, float64, float64) {
+	m := float64(int(1) << j.lgK)
+	// start from a half overlap of the per-sketch estimates
+	var sumA, sumB float64
+	for _, p := range j.pairs {
+		sumA += p.count * math.Ldexp(1, -p.valueA)
+		sumB += p.count * math.Ldexp(1, -p.valueB)
+	}
+	rateA, rateB := m/sumA, m/sumB
+	rateX := min(rateA, rateB) / 2
+	start := []float64{
+		math.Log(max(rateA-rateX, 1/m)),
+		math.Log(max(rateB-rateX, 1/m)),
+		math.Log(rateX),
+	}
+	best := nelderMeadMinimize(func(x []float64) float64 {
+		return -j.logLikelihood(math.Exp(x[0]), math.Exp(x[1]), math.Exp(x[2]))
+	}, start)
+	return m * math.Exp(best[0]), m * math.Exp(best[1]), m * math.Exp(best[2])
+}
+
+// logLikelihood returns the log-likelihood of the register pairs for the given per-register
+// Poisson rates of the items only in A, only in B and in both.
+// A register of a Poisson process of rate r is at most k with probability exp(-r/2^k), and the
+// register of A is the maximum of the registers of the A\B and A∩B processes.
+func (j *jointCounts) logLikelihood(rateA, rateB, rateX float64) float64 {
+	var ll float64
+	for _, p := range j.pairs {
+		var prob float64
+		switch {
+		case p.valueA < p.valueB:
+			prob = registerProb(rateB, p.valueB) * registerProb(rateA+rateX, p.valueA)
+		case p.valueA > p.valueB:
+			prob = registerProb(rateA, p.valueA) * registerProb(rateB+rateX, p.valueB)
+		default:
+			v := p.valueA
+			prob = registerProb(rateX, v)*registerCdf(rateA, v)*registerCdf(rateB, v) +
+				registerCdf(rateX, v-1)*registerProb(rateA, v)*registerProb(rateB, v)
+		}
+		if prob <= 0 {
+			return math.Inf(-1)
+		}
+		ll += p.count * math.Log(prob)
+	}
+	return ll
+}
+
+// registerCdf returns the probability that a register of a Poisson process of the given rate is
+// at most k.
+func registerCdf(rate float64, k int) float64 {
+	if k < 0 {
+		return 0
+	}
+	if k >= maxRegisterValue {
+		return 1
+	}
+	return math.Exp(-math.Ldexp(rate, -k))
+}
+
+// registerProb returns the probability that a register of a Poisson process of the given rate is k.
+func registerProb(rate float64, k int) float64 {
+	if k == 0 {
+		return math.Exp(-rate)
+	}
+	if k >= maxRegisterValue {
+		return -math.Expm1(-math.Ldexp(rate, -(maxRegisterValue - 1)))
+	}
+	x := math.Ldexp(rate, -k)
+	return math.Exp(-x) * -math.Expm1(-x)
+}
+
+// nelderMeadMinimize returns an approximate minimum of f, starting the simplex search at the given
+// point. The coordinates are kept within [minLgRate, maxLgRate].
+func nelderMeadMinimize(f func([]float64) float64, start []float64) []float64 {
+	const (
+		maxIterations = 2000
+		tolerance     = 1e-10
+	)
+	n := len(start)
+	clamp := func(x []float64) []float64 {
+		for i := range x {
+			x[i] = min(max(x[i], minLgRate), maxLgRate)
+		}
+		return x
+	}
+	type vertex struct {
+		x []float64
+		f float64
+	}
+	simplex := make([]vertex, n+1)
+	simplex[0] = vertex{x: clamp(slices.Clone(start))}
+	for i := 0; i < n; i++ {
+		x := slices.Clone(start)
+		x[i] += 1
+		simplex[i+1] = vertex{x: clamp(x)}
+	}
+	for i := range simplex {
+		simplex[i].f = f(simplex[i].x)
+	}
+	// along moves from the centroid c towards (t > 0) or away from (t < 0) the worst vertex w
+	along := func(c, w []float64, t float64) vertex {
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = c[i] + t*(w[i]-c[i])
+		}
+		x = clamp(x)
+		return vertex{x: x, f: f(x)}
+	}
+	for iter := 0; iter < maxIterations; iter++ {
+		slices.SortFunc(simplex, func(a, b vertex) int {
+			switch {
+			case a.f < b.f:
+				return -1
+			case a.f > b.f:
+				return 1
+			}
+			return 0
+		})
+		best, worst := simplex[0], simplex[n]
+		if math.Abs(worst.f-best.f) <= tolerance*(math.Abs(best.f)+tolerance) {
+			break
+		}
+		centroid := make([]float64, n)
+		for _, v := range simplex[:n] {
+			for i := range centroid {
+				centroid[i] += v.x[i] / float64(n)
+			}
+		}
+		reflected := along(centroid, worst.x, -1)
+		switch {
+		case reflected.f < best.f:
+			if expanded := along(centroid, worst.x, -2); expanded.f < reflected.f {
+				simplex[n] = expanded
+			} else {
+				simplex[n] = reflected
+			}
+		case reflected.f < simplex[n-1].f:
+			simplex[n] = reflected
+		default:
+			if contracted := along(centroid, worst.x, 0.5); contracted.f < worst.f {
+				simplex[n] = contracted
+				continue
+			}
+			for i := 1; i <= n; i++ {
+				simplex[i] = along(best.x, simplex[i].x, 0.5)
+			}
+		}
+	}
+	bestIdx := 0
+	for i := range simplex {
+		if simplex[i].f < simplex[bestIdx].f {
+			bestIdx = i
+		}
+	}
+	return simplex[bestIdx].x
+}
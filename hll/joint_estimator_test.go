@@ -0,0 +1,170 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hll
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntersectionEstimate(t *testing.T) {
+	// coupon mode, exact
+	intersectionCheck(t, 12, TgtHllTypeHll4, 12, TgtHllTypeHll8, 100, 50, 0)
+	intersectionCheck(t, 12, TgtHllTypeHll8, 10, TgtHllTypeHll6, 200, 0, 0)
+	// hll mode
+	intersectionCheck(t, 12, TgtHllTypeHll4, 12, TgtHllTypeHll4, 100000, 50000, 3)
+	intersectionCheck(t, 12, TgtHllTypeHll6, 12, TgtHllTypeHll8, 100000, 10000, 3)
+	intersectionCheck(t, 12, TgtHllTypeHll8, 12, TgtHllTypeHll8, 100000, 0, 3)
+	intersectionCheck(t, 14, TgtHllTypeHll4, 11, TgtHllTypeHll6, 50000, 25000, 3)
+	intersectionCheck(t, 10, TgtHllTypeHll8, 10, TgtHllTypeHll4, 1000000, 1000000, 3)
+	// mixed modes
+	intersectionCheck(t, 12, TgtHllTypeHll4, 12, TgtHllTypeHll8, 200000, 100, 3)
+}
+
+// intersectionCheck builds sketches of n items each, overlapping by the given number of items,
+// and checks that the true intersection and Jaccard similarity are within the bounds.
+func intersectionCheck(t *testing.T, lgKA int, typeA TgtHllType, lgKB int, typeB TgtHllType, n int, overlap int, numStdDev int) {
+	a, err := NewHllSketch(lgKA, typeA)
+	assert.NoError(t, err)
+	b, err := NewHllSketch(lgKB, typeB)
+	assert.NoError(t, err)
+	for i := 0; i < n; i++ {
+		assert.NoError(t, a.UpdateInt64(int64(i)))
+	}
+	// a mixed mode check uses a small B
+	nb := n
+	if overlap < n/100 {
+		nb = overlap
+	}
+	for i := 0; i < nb; i++ {
+		assert.NoError(t, b.UpdateInt64(int64(n-overlap+i)))
+	}
+
+	inter, err := IntersectionEstimate(a, b)
+	assert.NoError(t, err)
+	jaccard, err := Jaccard(a, b)
+	assert.NoError(t, err)
+	trueJaccard := float64(overlap) / float64(n+nb-overlap)
+	if numStdDev == 0 {
+		assert.Equal(t, float64(overlap), inter.GetEstimate())
+		assert.InDelta(t, trueJaccard, jaccard.GetEstimate(), 1e-12)
+		return
+	}
+
+	lb, err := inter.GetLowerBound(numStdDev)
+	assert.NoError(t, err)
+	ub, err := inter.GetUpperBound(numStdDev)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, lb, inter.GetEstimate())
+	assert.GreaterOrEqual(t, ub, inter.GetEstimate())
+	assert.GreaterOrEqual(t, lb, 0.0)
+	assert.LessOrEqual(t, lb, float64(overlap), "n=%d overlap=%d est=%f", n, overlap, inter.GetEstimate())
+	// the upper bound is clamped to the estimate of the smaller sketch
+	estA, err := a.GetEstimate()
+	assert.NoError(t, err)
+	estB, err := b.GetEstimate()
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, ub, min(estA, estB))
+	assert.GreaterOrEqual(t, ub, min(float64(overlap), estA, estB), "n=%d overlap=%d est=%f", n, overlap, inter.GetEstimate())
+
+	lb, err = jaccard.GetLowerBound(numStdDev)
+	assert.NoError(t, err)
+	ub, err = jaccard.GetUpperBound(numStdDev)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, lb, trueJaccard)
+	assert.GreaterOrEqual(t, ub, trueJaccard)
+	assert.LessOrEqual(t, ub, 1.0)
+
+	// both orders agree
+	rev, err := IntersectionEstimate(b, a)
+	assert.NoError(t, err)
+	assert.InDelta(t, inter.GetEstimate(), rev.GetEstimate(), float64(n)*1e-3)
+}
+
+func TestIntersectionEstimateUnions(t *testing.T) {
+	u1, err := NewUnion(12)
+	assert.NoError(t, err)
+	u2, err := NewUnion(12)
+	assert.NoError(t, err)
+	for i := 0; i < 100000; i++ {
+		assert.NoError(t, u1.UpdateInt64(int64(i)))
+		assert.NoError(t, u2.UpdateInt64(int64(i+50000)))
+	}
+	r1, err := u1.GetResult(TgtHllTypeHll8)
+	assert.NoError(t, err)
+	r2, err := u2.GetResult(TgtHllTypeHll4)
+	assert.NoError(t, err)
+	inter, err := IntersectionEstimate(r1, r2)
+	assert.NoError(t, err)
+	assert.InDelta(t, 50000, inter.GetEstimate(), 50000*0.1)
+	jaccard, err := Jaccard(r1, r2)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.0/3, jaccard.GetEstimate(), 0.05)
+}
+
+func TestIntersectionEstimateEmpty(t *testing.T) {
+	a, err := NewHllSketch(12, TgtHllTypeHll4)
+	assert.NoError(t, err)
+	b, err := NewHllSketch(12, TgtHllTypeHll4)
+	assert.NoError(t, err)
+
+	jaccard, err := Jaccard(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, jaccard.GetEstimate())
+
+	assert.NoError(t, a.UpdateInt64(1))
+	inter, err := IntersectionEstimate(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, inter.GetEstimate())
+	ub, err := inter.GetUpperBound(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, ub)
+	jaccard, err = Jaccard(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, jaccard.GetEstimate())
+
+	_, err = inter.GetLowerBound(4)
+	assert.Error(t, err)
+}
+
+// wrappedSketch is an HllSketch that is not a sketch of this package.
+type wrappedSketch struct {
+	HllSketch
+}
+
+func TestIntersectionEstimateUnsupportedSketch(t *testing.T) {
+	a, err := NewHllSketch(12, TgtHllTypeHll4)
+	assert.NoError(t, err)
+	_, err = IntersectionEstimate(a, wrappedSketch{a})
+	assert.Error(t, err)
+	_, err = Jaccard(wrappedSketch{a}, a)
+	assert.Error(t, err)
+}
+
+func TestIntersectionEstimateSeedMismatch(t *testing.T) {
+	a, err := NewHllSketch(12, TgtHllTypeHll4)
+	assert.NoError(t, err)
+	b, err := NewHllSketch(12, TgtHllTypeHll4, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	var seedErr *SeedHashMismatchError
+	_, err = IntersectionEstimate(a, b)
+	assert.ErrorAs(t, err, &seedErr)
+	_, err = Jaccard(a, b)
+	assert.ErrorAs(t, err, &seedErr)
+}
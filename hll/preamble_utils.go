@@ -19,6 +19,7 @@ package hll
 
 import (
 	"encoding/binary"
+	"fmt"
 	"math"
 
 	"github.com/apache/datasketches-go/internal"
@@ -101,8 +102,8 @@ func extractFamilyID(byteArr []byte) int {
 	return int((byteArr[familyByte]) & 0xFF)
 }
 
-func extractCurMode(byteArr []byte) curMode {
-	return curMode(byteArr[modeByte] & curModeMask)
+func extractCurMode(byteArr []byte) CurMode {
+	return CurMode(byteArr[modeByte] & curModeMask)
 }
 
 func extractTgtHllType(byteArr []byte) TgtHllType {
@@ -166,14 +167,14 @@ func extractAuxCount(byteArr []byte) int {
 func computeLgArr(byteArr []byte, couponCount int, lgConfigK int) (int, error) {
 	//value is missing, recompute
 	curMode := extractCurMode(byteArr)
-	if curMode == curModeList {
+	if curMode == CurModeList {
 		return lgInitListSize, nil
 	}
 	ceilPwr2 := internal.CeilPowerOf2(couponCount)
 	if (resizeDenom * couponCount) > (resizeNumber * ceilPwr2) {
 		ceilPwr2 <<= 1
 	}
-	if curMode == curModeSet {
+	if curMode == CurModeSet {
 		v, err := internal.ExactLog2(ceilPwr2)
 		return max(lgInitSetSize, v), err
 	}
@@ -237,7 +238,7 @@ func insertOooFlag(byteArr []byte, oooFlag bool) {
 	byteArr[flagsByte] = flags
 }
 
-func insertCurMode(byteArr []byte, curMode curMode) {
+func insertCurMode(byteArr []byte, curMode CurMode) {
 	mode := byteArr[modeByte] & ^uint8(curModeMask)
 	mode |= uint8(curMode) & curModeMask
 	byteArr[modeByte] = mode
@@ -288,3 +289,62 @@ func insertRebuildCurMinNumKxQFlag(byteArr []byte, rebuild bool) {
 	}
 	byteArr[flagsByte] = flags
 }
+
+// ImageInfo is the preamble of a serialized image, see InspectImage.
+type ImageInfo struct {
+	// PreambleBytes is the size in bytes of the preamble announced by its first byte.
+	PreambleBytes int
+	Flags         int
+	LgK           int
+	LgArr         int
+	CurMode       CurMode
+	TgtHllType    TgtHllType
+	// FullPreamble is true if the fields of the current mode were read from a valid preamble:
+	// ListCount in LIST mode, HashSetCount in SET mode, the others in HLL mode.
+	FullPreamble bool
+	ListCount    int
+	HashSetCount int
+	CurMin       int
+	HipAccum     float64
+	KxQ0         float64
+	KxQ1         float64
+	NumAtCurMin  int
+	AuxCount     int
+}
+
+// InspectImage decodes and validates the preamble of a serialized image, which is then deserialized
+// to check its coupons or registers. The fields decoded before the image was found invalid are
+// returned along with the error.
+func InspectImage(image []byte) (ImageInfo, error) {
+	if len(image) < 8 {
+		return ImageInfo{}, fmt.Errorf("input array too small: %d", len(image))
+	}
+	info := ImageInfo{
+		PreambleBytes: extractPreInts(image) * 4,
+		Flags:         int(image[flagsByte]),
+		LgK:           extractLgK(image),
+		LgArr:         extractLgArr(image),
+		CurMode:       extractCurMode(image),
+		TgtHllType:    extractTgtHllType(image),
+	}
+	curMode, err := checkPreamble(image)
+	if err != nil {
+		return info, err
+	}
+	info.FullPreamble = true
+	switch curMode {
+	case CurModeList:
+		info.ListCount = extractListCount(image)
+	case CurModeSet:
+		info.HashSetCount = extractHashSetCount(image)
+	case CurModeHll:
+		info.CurMin = extractCurMin(image)
+		info.HipAccum = extractHipAccum(image)
+		info.KxQ0 = extractKxQ0(image)
+		info.KxQ1 = extractKxQ1(image)
+		info.NumAtCurMin = extractNumAtCurMin(image)
+		info.AuxCount = extractAuxCount(image)
+	}
+	_, err = deserializeSketch(image)
+	return info, err
+}
@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hll
+
+// RegisterIterator is a read-only iterator over the non-zero registers of a sketch, see HllSketch.Registers.
+//
+//	itr := sketch.Registers()
+//	for itr.Next() {
+//		fmt.Println(itr.GetSlot(), itr.GetValue())
+//	}
+//	if err := itr.Err(); err != nil {
+//		...
+//	}
+type RegisterIterator struct {
+	itr   pairIterator
+	value int
+	err   error
+}
+
+func newRegisterIterator(itr pairIterator) *RegisterIterator {
+	return &RegisterIterator{itr: itr}
+}
+
+// Next advances the iterator to the next non-zero register and returns false when the iteration
+// is done or has failed, in which case Err returns the error.
+func (r *RegisterIterator) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	for r.itr.nextAll() {
+		value, err := r.itr.getValue()
+		if err != nil {
+			r.err = err
+			return false
+		}
+		if value != empty {
+			r.value = value
+			return true
+		}
+	}
+	return false
+}
+
+// GetSlot returns the slot number of the current register, between 0 and K-1.
+// In LIST and SET modes each coupon is visited, several coupons can then share the same slot, the
+// value of the register being the largest of their values.
+func (r *RegisterIterator) GetSlot() int {
+	return r.itr.getSlot()
+}
+
+// GetValue returns the value of the current register, between 1 and 63.
+// For HLL_4 this is the actual value, including the exceptions kept in the aux hash map.
+func (r *RegisterIterator) GetValue() int {
+	return r.value
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (r *RegisterIterator) Err() error {
+	return r.err
+}
@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hll
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/apache/datasketches-go/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/murmur3"
+)
+
+func TestRegisters(t *testing.T) {
+	for _, tgtHllType := range []TgtHllType{TgtHllTypeHll4, TgtHllTypeHll6, TgtHllTypeHll8} {
+		checkRegisters(t, 10, tgtHllType, 5, CurModeList)
+		checkRegisters(t, 10, tgtHllType, 50, CurModeSet)
+		checkRegisters(t, 10, tgtHllType, 1000, CurModeHll)
+		checkRegisters(t, 4, tgtHllType, 100000, CurModeHll)
+	}
+}
+
+func checkRegisters(t *testing.T, lgK int, tgtHllType TgtHllType, n int, mode CurMode) {
+	sk, err := NewHllSketch(lgK, tgtHllType)
+	assert.NoError(t, err)
+	expected := make(map[int]int)
+	var scratch [8]byte
+	for i := 0; i < n; i++ {
+		assert.NoError(t, sk.UpdateInt64(int64(i)))
+		binary.LittleEndian.PutUint64(scratch[:], uint64(i))
+		c := coupon(murmur3.SeedSum128(internal.DEFAULT_UPDATE_SEED, internal.DEFAULT_UPDATE_SEED, scratch[:]))
+		slot := getPairLow26(c) & ((1 << lgK) - 1)
+		expected[slot] = max(expected[slot], getPairValue(c))
+	}
+	assert.Equal(t, mode, sk.GetCurMode())
+
+	actual := make(map[int]int)
+	itr := sk.Registers()
+	for itr.Next() {
+		assert.Less(t, itr.GetSlot(), 1<<lgK)
+		assert.Greater(t, itr.GetValue(), 0)
+		actual[itr.GetSlot()] = max(actual[itr.GetSlot()], itr.GetValue())
+	}
+	assert.NoError(t, itr.Err())
+	assert.Equal(t, expected, actual, "lgK=%d type=%d n=%d", lgK, tgtHllType, n)
+
+	union, err := NewUnion(lgK)
+	assert.NoError(t, err)
+	assert.NoError(t, union.UpdateSketch(sk))
+	actual = make(map[int]int)
+	itr = union.Registers()
+	for itr.Next() {
+		actual[itr.GetSlot()] = max(actual[itr.GetSlot()], itr.GetValue())
+	}
+	assert.NoError(t, itr.Err())
+	assert.Equal(t, expected, actual)
+}
+
+func TestRegistersEmpty(t *testing.T) {
+	sk, err := NewHllSketch(10, TgtHllTypeHll4)
+	assert.NoError(t, err)
+	itr := sk.Registers()
+	assert.False(t, itr.Next())
+	assert.NoError(t, itr.Err())
+}
+
+func TestCurModeString(t *testing.T) {
+	assert.Equal(t, "LIST", CurModeList.String())
+	assert.Equal(t, "SET", CurModeSet.String())
+	assert.Equal(t, "HLL", CurModeHll.String())
+	assert.Equal(t, "CurMode(3)", CurMode(3).String())
+}
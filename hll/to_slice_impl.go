@@ -50,7 +50,7 @@ func toCouponSlice(impl hllCoupon, dstCompact bool) ([]byte, error) {
 	srcCouponCount := impl.getCouponCount()
 	srcLgCouponArrInts := impl.getLgCouponArrInts()
 	srcCouponArrInts := 1 << srcLgCouponArrInts
-	list := impl.GetCurMode() == curModeList
+	list := impl.GetCurMode() == CurModeList
 	if dstCompact {
 		//Src Heap,   Src Updatable, Dst Compact
 		dataStart := impl.getMemDataStart()
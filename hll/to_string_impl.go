@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hll
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String returns the name of the target type: HLL_4, HLL_6 or HLL_8.
+func (t TgtHllType) String() string {
+	switch t {
+	case TgtHllTypeHll4:
+		return "HLL_4"
+	case TgtHllTypeHll6:
+		return "HLL_6"
+	case TgtHllTypeHll8:
+		return "HLL_8"
+	}
+	return fmt.Sprintf("TgtHllType(%d)", int(t))
+}
+
+// String returns the summary of the sketch, see ToString.
+func (h *hllSketchState) String() string {
+	s, err := h.ToString(true, false, false, false)
+	if err != nil {
+		return err.Error()
+	}
+	return s
+}
+
+// ToString returns a human-readable view of the sketch.
+//
+//   - summary, if true, includes the configuration, the estimate with its bounds and, in HLL mode,
+//     the estimator state: curMin, numAtCurMin, HIP accumulator, KxQ registers and flags.
+//   - detail, if true, lists the coupons in LIST and SET modes, or the registers in HLL mode.
+//   - auxDetail, if true, lists the exceptions of the aux hash map of an HLL_4 sketch.
+//   - all, if true, the detail lists also include the empty entries.
+func (h *hllSketchState) ToString(summary bool, detail bool, auxDetail bool, all bool) (string, error) {
+	var sb strings.Builder
+	if summary {
+		if err := h.writeSummary(&sb); err != nil {
+			return "", err
+		}
+	}
+	if detail {
+		if err := h.writeDetail(&sb, all); err != nil {
+			return "", err
+		}
+	}
+	if auxDetail {
+		if hll4, ok := h.sketch.(*hll4ArrayImpl); ok && hll4.auxHashMap != nil {
+			sb.WriteString("### HLL AUX DETAIL: \n")
+			if err := writePairs(&sb, hll4.auxHashMap.iterator(), all); err != nil {
+				return "", err
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+func (h *hllSketchState) writeSummary(sb *strings.Builder) error {
+	est, err := h.GetEstimate()
+	if err != nil {
+		return err
+	}
+	lb, err := h.GetLowerBound(1)
+	if err != nil {
+		return err
+	}
+	ub, err := h.GetUpperBound(1)
+	if err != nil {
+		return err
+	}
+	sb.WriteString("### HLL SKETCH SUMMARY: \n")
+	fmt.Fprintf(sb, "  Log Config K   : %d\n", h.GetLgConfigK())
+	fmt.Fprintf(sb, "  Hll Target     : %s\n", h.GetTgtHllType())
+	fmt.Fprintf(sb, "  Current Mode   : %s\n", h.GetCurMode())
+	fmt.Fprintf(sb, "  Direct         : %t\n", h.mem != nil)
+	fmt.Fprintf(sb, "  LB             : %f\n", lb)
+	fmt.Fprintf(sb, "  Estimate       : %f\n", est)
+	fmt.Fprintf(sb, "  UB             : %f\n", ub)
+	fmt.Fprintf(sb, "  OutOfOrder Flag: %t\n", h.IsOutOfOrder())
+	switch sketch := h.sketch.(type) {
+	case hllArray:
+		fmt.Fprintf(sb, "  CurMin         : %d\n", sketch.getCurMin())
+		fmt.Fprintf(sb, "  NumAtCurMin    : %d\n", sketch.getNumAtCurMin())
+		fmt.Fprintf(sb, "  HipAccum       : %f\n", sketch.getHipAccum())
+		fmt.Fprintf(sb, "  KxQ0           : %f\n", sketch.getKxQ0())
+		fmt.Fprintf(sb, "  KxQ1           : %f\n", sketch.getKxQ1())
+		fmt.Fprintf(sb, "  Rebuild KxQ Flg: %t\n", sketch.isRebuildCurMinNumKxQFlag())
+		if hll4, ok := sketch.(*hll4ArrayImpl); ok && hll4.auxHashMap != nil {
+			fmt.Fprintf(sb, "  Aux Count      : %d\n", hll4.auxHashMap.getAuxCount())
+		}
+	case hllCoupon:
+		fmt.Fprintf(sb, "  Coupon Count   : %d\n", sketch.getCouponCount())
+		fmt.Fprintf(sb, "  Coupon Arr Ints: %d\n", 1<<sketch.getLgCouponArrInts())
+	}
+	return nil
+}
+
+func (h *hllSketchState) writeDetail(sb *strings.Builder, all bool) error {
+	if h.GetCurMode() != CurModeHll {
+		sb.WriteString("### COUPON DATA DETAIL: \n")
+		return writePairs(sb, h.sketch.iterator(), all)
+	}
+	sb.WriteString("### HLL SKETCH DATA DETAIL: \n")
+	fmt.Fprintf(sb, "%10s%10s\n", "Slot", "Value")
+	itr := h.sketch.iterator()
+	for itr.nextAll() { // the HLL_4 iterator only supports nextAll
+		value, err := itr.getValue()
+		if err != nil {
+			return err
+		}
+		if all || value != empty {
+			fmt.Fprintf(sb, "%10d%10d\n", itr.getSlot(), value)
+		}
+	}
+	return nil
+}
+
+// writePairs lists the coupons of a coupon list, a coupon hash set or an aux hash map.
+func writePairs(sb *strings.Builder, itr pairIterator, all bool) error {
+	fmt.Fprintf(sb, "%10s%10s%10s%10s\n", "Index", "Key", "Slot", "Value")
+	for itr.nextAll() {
+		pair, err := itr.getPair()
+		if err != nil {
+			return err
+		}
+		if !all && pair == empty {
+			continue
+		}
+		value, err := itr.getValue()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sb, "%10d%10d%10d%10d\n", itr.getIndex(), itr.getKey(), itr.getSlot(), value)
+	}
+	return nil
+}
@@ -19,6 +19,7 @@ package hll
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/apache/datasketches-go/internal"
 )
@@ -29,19 +30,29 @@ type Union interface {
 	UpdateInt64(datum int64) error
 	UpdateSlice(datum []byte) error
 	UpdateString(datum string) error
+	UpdateHash(lo uint64, hi uint64) error
+	UpdateUInt64s(data []uint64) error
+	UpdateSlices(data [][]byte) error
+	UpdateStrings(data []string) error
 	Reset() error
 
 	// Estimable
 	GetCompositeEstimate() (float64, error)
 	GetEstimate() (float64, error)
-	//GetHipEstimate() (float64, error)
+	// GetHipEstimate returns the HIP estimate of the union, which is only valid if IsOutOfOrder is false.
+	GetHipEstimate() (float64, error)
 	GetLowerBound(numStdDev int) (float64, error)
 	GetUpperBound(numStdDev int) (float64, error)
 	IsEmpty() bool
+	// IsOutOfOrder returns true once sketches in HLL mode have been merged into the union, from then
+	// on its estimate and bounds are based on the composite estimator (see GetRelErr).
+	IsOutOfOrder() bool
 
 	GetLgConfigK() int
 	GetTgtHllType() TgtHllType
-	GetCurMode() curMode
+	GetCurMode() CurMode
+	// Registers returns a read-only iterator over the non-zero registers of the union.
+	Registers() *RegisterIterator
 
 	GetUpdatableSerializationBytes() int
 	ToCompactSlice() ([]byte, error)
@@ -50,6 +61,21 @@ type Union interface {
 	UpdateSketch(sketch HllSketch) error
 	GetResult(tgtHllType TgtHllType) (HllSketch, error)
 
+	// ToString returns a human-readable view of the union gadget, see HllSketch.ToString.
+	ToString(summary bool, detail bool, auxDetail bool, all bool) (string, error)
+	// String returns the summary of the union gadget.
+	String() string
+
+	// MarshalBinary implements encoding.BinaryMarshaler, it returns the image of ToCompactSlice.
+	MarshalBinary() ([]byte, error)
+	// UnmarshalBinary implements encoding.BinaryUnmarshaler, it replaces the union with the one of
+	// the given image, see NewUnionFromSlice. The image must have been produced with the same update seed.
+	UnmarshalBinary(data []byte) error
+	// GobEncode implements gob.GobEncoder, see MarshalBinary.
+	GobEncode() ([]byte, error)
+	// GobDecode implements gob.GobDecoder, see UnmarshalBinary.
+	GobDecode(data []byte) error
+
 	couponUpdate(coupon int) (hllSketchStateI, error)
 	iterator() pairIterator
 }
@@ -63,15 +89,50 @@ func (u *unionImpl) iterator() pairIterator {
 	return u.gadget.iterator()
 }
 
-//func (u *unionImpl) GetHipEstimate() (float64, error) {
-//	return u.gadget.GetHipEstimate()
-//}
+func (u *unionImpl) ToString(summary bool, detail bool, auxDetail bool, all bool) (string, error) {
+	if err := checkRebuildCurMinNumKxQ(u.gadget); err != nil {
+		return "", err
+	}
+	s, err := u.gadget.ToString(summary, detail, auxDetail, all)
+	if err != nil || !summary {
+		return s, err
+	}
+	return fmt.Sprintf("### HLL UNION: lgMaxK=%d\n", u.lgMaxK) + s, nil
+}
+
+func (u *unionImpl) String() string {
+	s, err := u.ToString(true, false, false, false)
+	if err != nil {
+		return err.Error()
+	}
+	return s
+}
+
+func (u *unionImpl) Registers() *RegisterIterator {
+	return u.gadget.Registers()
+}
+
+func (u *unionImpl) GetHipEstimate() (float64, error) {
+	return u.gadget.GetHipEstimate()
+}
+
+func (u *unionImpl) IsOutOfOrder() bool {
+	return u.gadget.IsOutOfOrder()
+}
 
 func (u *unionImpl) GetUpperBound(numStdDev int) (float64, error) {
+	err := checkRebuildCurMinNumKxQ(u.gadget)
+	if err != nil {
+		return 0, err
+	}
 	return u.gadget.GetUpperBound(numStdDev)
 }
 
 func (u *unionImpl) GetLowerBound(numStdDev int) (float64, error) {
+	err := checkRebuildCurMinNumKxQ(u.gadget)
+	if err != nil {
+		return 0, err
+	}
 	return u.gadget.GetLowerBound(numStdDev)
 }
 
@@ -92,12 +153,16 @@ func (u *unionImpl) GetResult(tgtHllType TgtHllType) (HllSketch, error) {
 	return u.gadget.CopyAs(tgtHllType)
 }
 
-func NewUnionWithDefault() (Union, error) {
-	return NewUnion(defaultLgK)
+func NewUnionWithDefault(opts ...SketchOption) (Union, error) {
+	return NewUnion(defaultLgK, opts...)
 }
 
-func NewUnion(lgMaxK int) (Union, error) {
-	sk, err := NewHllSketch(lgMaxK, TgtHllTypeHll8)
+// NewUnion constructs a new union with the given maximum lgK.
+//
+//   - lgMaxK, the Log2 of the maximum K of the union. This value must be between 4 and 21 inclusively.
+//   - opts, optional parameters such as WithUpdateSeed, only sketches built with the same seed can be merged.
+func NewUnion(lgMaxK int, opts ...SketchOption) (Union, error) {
+	sk, err := NewHllSketch(lgMaxK, TgtHllTypeHll8, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -107,16 +172,19 @@ func NewUnion(lgMaxK int) (Union, error) {
 	}, nil
 }
 
-func NewUnionFromSlice(byteArray []byte) (Union, error) {
+func NewUnionFromSlice(byteArray []byte, opts ...SketchOption) (Union, error) {
+	if len(byteArray) < 8 {
+		return nil, fmt.Errorf("input array too small: %d", len(byteArray))
+	}
 	lgK, err := checkLgK(extractLgK(byteArray))
 	if err != nil {
 		return nil, err
 	}
-	sk, e := NewHllSketchFromSlice(byteArray, false)
+	sk, e := NewHllSketchFromSlice(byteArray, false, opts...)
 	if e != nil {
 		return nil, e
 	}
-	union, err := NewUnion(lgK)
+	union, err := NewUnion(lgK, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -125,10 +193,18 @@ func NewUnionFromSlice(byteArray []byte) (Union, error) {
 }
 
 func (u *unionImpl) GetCompositeEstimate() (float64, error) {
+	err := checkRebuildCurMinNumKxQ(u.gadget)
+	if err != nil {
+		return 0, err
+	}
 	return u.gadget.GetCompositeEstimate()
 }
 
 func (u *unionImpl) GetEstimate() (float64, error) {
+	err := checkRebuildCurMinNumKxQ(u.gadget)
+	if err != nil {
+		return 0, err
+	}
 	return u.gadget.GetEstimate()
 }
 
@@ -148,6 +224,22 @@ func (u *unionImpl) UpdateString(datum string) error {
 	return u.gadget.UpdateString(datum)
 }
 
+func (u *unionImpl) UpdateHash(lo uint64, hi uint64) error {
+	return u.gadget.UpdateHash(lo, hi)
+}
+
+func (u *unionImpl) UpdateUInt64s(data []uint64) error {
+	return u.gadget.UpdateUInt64s(data)
+}
+
+func (u *unionImpl) UpdateSlices(data [][]byte) error {
+	return u.gadget.UpdateSlices(data)
+}
+
+func (u *unionImpl) UpdateStrings(data []string) error {
+	return u.gadget.UpdateStrings(data)
+}
+
 func (u *unionImpl) UpdateSketch(sketch HllSketch) error {
 	un, err := u.unionImpl(sketch)
 	if err != nil {
@@ -165,7 +257,7 @@ func (u *unionImpl) GetTgtHllType() TgtHllType {
 	return u.gadget.GetTgtHllType()
 }
 
-func (u *unionImpl) GetCurMode() curMode {
+func (u *unionImpl) GetCurMode() CurMode {
 	return u.gadget.GetCurMode()
 }
 
@@ -189,6 +281,29 @@ func (u *unionImpl) ToUpdatableSlice() ([]byte, error) {
 	return u.gadget.ToUpdatableSlice()
 }
 
+func (u *unionImpl) MarshalBinary() ([]byte, error) {
+	return u.ToCompactSlice()
+}
+
+// UnmarshalBinary replaces the content of the union with the given image, which is copied.
+func (u *unionImpl) UnmarshalBinary(data []byte) error {
+	seed := u.gadget.(*hllSketchState).seed
+	union, err := NewUnionFromSlice(slices.Clone(data), WithUpdateSeed(seed))
+	if err != nil {
+		return err
+	}
+	*u = *union.(*unionImpl)
+	return nil
+}
+
+func (u *unionImpl) GobEncode() ([]byte, error) {
+	return u.MarshalBinary()
+}
+
+func (u *unionImpl) GobDecode(data []byte) error {
+	return u.UnmarshalBinary(data)
+}
+
 func (u *unionImpl) GetUpdatableSerializationBytes() int {
 	return u.gadget.GetUpdatableSerializationBytes()
 }
@@ -201,15 +316,21 @@ func (u *unionImpl) unionImpl(source HllSketch) (hllSketchStateI, error) {
 	if u.gadget.GetTgtHllType() != TgtHllTypeHll8 {
 		return nil, fmt.Errorf("gadget must be HLL_8")
 	}
-	if source == nil || source.IsEmpty() {
+	if source == nil {
 		return u.gadget.(*hllSketchState).sketch, nil
 	}
 
 	gadgetC := u.gadget.(*hllSketchState)
 	sourceC := source.(*hllSketchState)
+	if sourceC.seedHash != gadgetC.seedHash {
+		return nil, &SeedHashMismatchError{Expected: gadgetC.seedHash, Actual: sourceC.seedHash}
+	}
+	if source.IsEmpty() {
+		return gadgetC.sketch, nil
+	}
 
 	srcMode := sourceC.sketch.GetCurMode()
-	if srcMode == curModeList {
+	if srcMode == CurModeList {
 		err := sourceC.mergeTo(u.gadget)
 		return u.gadget.(*hllSketchState).sketch, err
 	}
@@ -218,7 +339,7 @@ func (u *unionImpl) unionImpl(source HllSketch) (hllSketchStateI, error) {
 	gdgtLgK := u.gadget.GetLgConfigK()
 	gdgtEmpty := u.gadget.IsEmpty()
 
-	if srcMode == curModeSet {
+	if srcMode == CurModeSet {
 		if gdgtEmpty && srcLgK == gdgtLgK {
 			un, err := sourceC.CopyAs(TgtHllTypeHll8)
 			gadgetC.sketch = un.(*hllSketchState).sketch
@@ -312,7 +433,7 @@ func checkRebuildCurMinNumKxQ(sketch HllSketch) error {
 	curMode := sketch.GetCurMode()
 	tgtHllType := sketch.GetTgtHllType()
 	rebuild := sketchImpl.isRebuildCurMinNumKxQFlag()
-	if !rebuild || curMode != curModeHll || tgtHllType != TgtHllTypeHll8 {
+	if !rebuild || curMode != CurModeHll || tgtHllType != TgtHllTypeHll8 {
 		return nil
 	}
 
@@ -18,6 +18,8 @@
 package hll
 
 import (
+	"bytes"
+	"encoding/gob"
 	"fmt"
 	"testing"
 
@@ -335,3 +337,109 @@ func TestCheckUnionDeserializeRebuildAfterMerge(t *testing.T) {
 	assert.False(t, rebuild)
 
 }
+
+func TestUnionSeedMismatch(t *testing.T) {
+	seeded, err := NewHllSketch(12, TgtHllTypeHll4, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	def, err := NewHllSketch(12, TgtHllTypeHll4)
+	assert.NoError(t, err)
+
+	union, err := NewUnion(12)
+	assert.NoError(t, err)
+	var seedErr *SeedHashMismatchError
+	assert.ErrorAs(t, union.UpdateSketch(seeded), &seedErr)
+	assert.NoError(t, union.UpdateSketch(def))
+
+	seededUnion, err := NewUnion(12, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	assert.ErrorAs(t, seededUnion.UpdateSketch(def), &seedErr)
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, seeded.UpdateInt64(int64(i)))
+	}
+	assert.NoError(t, seededUnion.UpdateSketch(seeded))
+
+	bytes, err := seededUnion.ToCompactSlice()
+	assert.NoError(t, err)
+	fromSlice, err := NewUnionFromSlice(bytes, WithUpdateSeed(123))
+	assert.NoError(t, err)
+	est1, err := fromSlice.GetEstimate()
+	assert.NoError(t, err)
+	est2, err := seeded.GetEstimate()
+	assert.NoError(t, err)
+	assert.InDelta(t, est2, est1, est2*0.02)
+
+	result, err := seededUnion.GetResult(TgtHllTypeHll4)
+	assert.NoError(t, err)
+	assert.ErrorAs(t, union.UpdateSketch(result), &seedErr)
+}
+
+func TestUnionHipEstimateAndOutOfOrder(t *testing.T) {
+	union, err := NewUnion(12)
+	assert.NoError(t, err)
+	assert.False(t, union.IsOutOfOrder())
+
+	// coupon and HIP estimates agree while the union holds a single sketch in SET mode
+	sk1, err := NewHllSketch(12, TgtHllTypeHll4)
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, sk1.UpdateInt64(int64(i)))
+	}
+	assert.NoError(t, union.UpdateSketch(sk1))
+	assert.False(t, union.IsOutOfOrder())
+	hip, err := union.GetHipEstimate()
+	assert.NoError(t, err)
+	est, err := union.GetEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, est, hip)
+
+	// merging sketches in HLL mode makes the union out of order
+	sk2, err := NewHllSketch(12, TgtHllTypeHll4)
+	assert.NoError(t, err)
+	sk3, err := NewHllSketch(12, TgtHllTypeHll4)
+	assert.NoError(t, err)
+	for i := 0; i < 100000; i++ {
+		assert.NoError(t, sk2.UpdateInt64(int64(i)))
+		assert.NoError(t, sk3.UpdateInt64(int64(i+50000)))
+	}
+	assert.NoError(t, union.UpdateSketch(sk2))
+	assert.NoError(t, union.UpdateSketch(sk3))
+	assert.True(t, union.IsOutOfOrder())
+
+	// the estimate and bounds account for the merged registers before GetResult is called
+	est, err = union.GetEstimate()
+	assert.NoError(t, err)
+	assert.InDelta(t, 150000, est, 150000*0.05)
+	ub, err := union.GetUpperBound(2)
+	assert.NoError(t, err)
+	relErr, err := GetRelErr(true, union.IsOutOfOrder(), 12, 2)
+	assert.NoError(t, err)
+	assert.InDelta(t, est/(1.0-relErr), ub, 1e-9)
+
+	result, err := union.GetResult(TgtHllTypeHll4)
+	assert.NoError(t, err)
+	assert.True(t, result.IsOutOfOrder())
+	resultEst, err := result.GetEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, est, resultEst)
+}
+
+func TestUnionMarshalBinary(t *testing.T) {
+	union, err := NewUnion(12)
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		assert.NoError(t, union.UpdateInt64(int64(i)))
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(union))
+	decoded, err := NewUnion(4)
+	assert.NoError(t, err)
+	assert.NoError(t, gob.NewDecoder(&buf).Decode(decoded))
+	assert.Equal(t, 12, decoded.GetLgConfigK())
+	est1, err := union.GetEstimate()
+	assert.NoError(t, err)
+	est2, err := decoded.GetEstimate()
+	assert.NoError(t, err)
+	assert.Equal(t, est1, est2)
+
+	assert.Error(t, decoded.UnmarshalBinary(nil))
+}
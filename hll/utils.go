@@ -54,14 +54,31 @@ var (
 )
 
 type TgtHllType int
-type curMode int
+
+// CurMode is the current mode of a sketch: it starts in LIST mode, storing coupons in a list, then
+// in SET mode, storing coupons in a hash set, and is promoted to HLL mode, storing an array of
+// registers, when the coupons would take more space than the registers.
+type CurMode int
 
 const (
-	curModeList curMode = 0
-	curModeSet  curMode = 1
-	curModeHll  curMode = 2
+	CurModeList CurMode = 0
+	CurModeSet  CurMode = 1
+	CurModeHll  CurMode = 2
 )
 
+// String returns the name of the mode: LIST, SET or HLL.
+func (c CurMode) String() string {
+	switch c {
+	case CurModeList:
+		return "LIST"
+	case CurModeSet:
+		return "SET"
+	case CurModeHll:
+		return "HLL"
+	}
+	return fmt.Sprintf("CurMode(%d)", int(c))
+}
+
 // Specifies the target type of HLL sketch to be created. It is a target in that the actual
 // allocation of the HLL array is deferred until sufficient number of items have been received by
 // the warm-up phases.
@@ -142,7 +159,7 @@ func checkNumStdDev(numStdDev int) error {
 }
 
 // checkPreamble checks the given preamble and returns the curMode if it is valid and return an error otherwise.
-func checkPreamble(preamble []byte) (curMode, error) {
+func checkPreamble(preamble []byte) (CurMode, error) {
 	if len(preamble) == 0 {
 		return 0, fmt.Errorf("preamble cannot be nil or empty")
 	}
@@ -165,21 +182,78 @@ func checkPreamble(preamble []byte) (curMode, error) {
 		return 0, fmt.Errorf("possible Corruption: Invalid Preamble Ints: %d", preInts)
 	}
 
-	if curMode == curModeList && preInts != listPreInts {
+	if curMode == CurModeList && preInts != listPreInts {
 		return 0, fmt.Errorf("possible Corruption: Invalid Preamble Ints: %d", preInts)
 	}
 
-	if curMode == curModeSet && preInts != hashSetPreInts {
+	if curMode == CurModeSet && preInts != hashSetPreInts {
 		return 0, fmt.Errorf("possible Corruption: Invalid Preamble Ints: %d", preInts)
 	}
 
-	if curMode == curModeHll && preInts != hllPreInts {
+	if curMode == CurModeHll && preInts != hllPreInts {
 		return 0, fmt.Errorf("possible Corruption: Invalid Preamble Ints: %d", preInts)
 	}
 
+	if curMode != CurModeList && curMode != CurModeSet && curMode != CurModeHll {
+		return 0, fmt.Errorf("possible Corruption: Invalid Current Mode: %d", curMode)
+	}
+
+	tgtHllType := extractTgtHllType(preamble)
+	if tgtHllType != TgtHllTypeHll4 && tgtHllType != TgtHllTypeHll6 && tgtHllType != TgtHllTypeHll8 {
+		return 0, fmt.Errorf("possible Corruption: Invalid Target HLL Type: %d", tgtHllType)
+	}
+
+	if _, err := checkLgK(extractLgK(preamble)); err != nil {
+		return 0, fmt.Errorf("possible Corruption: %w", err)
+	}
+
 	return curMode, nil
 }
 
+// GetMaxUpdatableSerializationBytes returns the maximum size in bytes that a sketch of the given
+// configuration can take when serialized with ToUpdatableSlice, which is also the size of the
+// slice to give to NewDirectHllSketch to never run out of space.
+// For HLL_4 this assumes that the aux hash map keeps its initial size, it only grows in the
+// exceedingly rare case of a large number of exceptions.
+//
+//   - lgConfigK, the Log2 of K, this value must be between 4 and 21 inclusively.
+//   - tgtHllType, the TgtHllType of the sketch.
+func GetMaxUpdatableSerializationBytes(lgConfigK int, tgtHllType TgtHllType) (int, error) {
+	lgK, err := checkLgK(lgConfigK)
+	if err != nil {
+		return 0, err
+	}
+	return getMaxUpdatableSerializationBytes(lgK, tgtHllType), nil
+}
+
+// GetMaxCompactSerializationBytes returns an upper bound of the size in bytes of a sketch of the
+// given configuration that has been presented with n distinct items, when serialized with
+// ToCompactSlice. As long as the sketch is in LIST or SET mode this is about 4 bytes per item,
+// see GetMaxUpdatableSerializationBytes for the HLL mode.
+//
+//   - lgConfigK, the Log2 of K, this value must be between 4 and 21 inclusively.
+//   - tgtHllType, the TgtHllType of the sketch.
+//   - n, the number of distinct items presented to the sketch.
+func GetMaxCompactSerializationBytes(lgConfigK int, tgtHllType TgtHllType, n uint64) (int, error) {
+	lgK, err := checkLgK(lgConfigK)
+	if err != nil {
+		return 0, err
+	}
+	// the largest number of coupons held before the promotion to HLL mode
+	maxCoupons := (resizeNumber * (1 << (lgK - 3))) / resizeDenom
+	if lgK < 8 {
+		maxCoupons = (1 << lgInitListSize) - 1
+	}
+	if n < uint64(1<<lgInitListSize) {
+		return listIntArrStart + (int(n) << 2), nil
+	}
+	if n <= uint64(maxCoupons) {
+		return hashSetIntArrStart + (int(n) << 2), nil
+	}
+	couponBytes := hashSetIntArrStart + (maxCoupons << 2)
+	return max(couponBytes, getMaxUpdatableSerializationBytes(lgK, tgtHllType)), nil
+}
+
 func getMaxUpdatableSerializationBytes(lgConfigK int, tgtHllType TgtHllType) int {
 	var arrBytes int
 	if tgtHllType == TgtHllTypeHll4 {
@@ -0,0 +1,163 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+)
+
+// StreamChunkItems is the number of items serialized at once when streaming a sketch image.
+const StreamChunkItems = 1024
+
+// CountingWriter counts the bytes written to the underlying writer and keeps the first error,
+// after which all the writes are skipped, so that a sequence of writes is checked only once.
+type CountingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// NewCountingWriter returns a CountingWriter writing to w.
+func NewCountingWriter(w io.Writer) *CountingWriter {
+	return &CountingWriter{w: w}
+}
+
+// Write implements io.Writer.
+func (c *CountingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// Count returns the number of bytes written so far.
+func (c *CountingWriter) Count() int64 {
+	return c.n
+}
+
+// Err returns the first error returned by the underlying writer, if any.
+func (c *CountingWriter) Err() error {
+	return c.err
+}
+
+// CountingReader counts the bytes read from the underlying reader.
+type CountingReader struct {
+	r io.Reader
+	n int64
+}
+
+// NewCountingReader returns a CountingReader reading from r.
+func NewCountingReader(r io.Reader) *CountingReader {
+	return &CountingReader{r: r}
+}
+
+// Read implements io.Reader.
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ReadFull reads exactly len(p) bytes. It returns io.EOF only if the reader was at its end before
+// anything was read from it, any other truncated input is reported as io.ErrUnexpectedEOF.
+func (c *CountingReader) ReadFull(p []byte) error {
+	start := c.n
+	_, err := io.ReadFull(c, p)
+	if err == io.EOF && start > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Count returns the number of bytes read so far.
+func (c *CountingReader) Count() int64 {
+	return c.n
+}
+
+// ReadAppend appends n bytes read from the reader to buf, see ReadFull.
+func (c *CountingReader) ReadAppend(buf []byte, n int) ([]byte, error) {
+	start := len(buf)
+	buf = slices.Grow(buf, n)[:start+n]
+	return buf, c.ReadFull(buf[start:])
+}
+
+// ReadLengthPrefixed reads a string serialized as the Java ArrayOfStringsSerDe does, prefixed by its
+// length in bytes as a 32-bit little-endian integer. The bytes are read by chunks doubling in size, so
+// that a corrupted length does not allocate much more than r holds. io.EOF is returned only if r is at
+// its end before the prefix, any other truncated input is reported as io.ErrUnexpectedEOF.
+func ReadLengthPrefixed(r io.Reader) (string, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return "", err
+	}
+	itemLen := int(binary.LittleEndian.Uint32(prefix[:]))
+	item := make([]byte, 0, min(itemLen, 1<<12))
+	for len(item) < itemLen {
+		start := len(item)
+		n := min(itemLen-start, max(start, 1<<12))
+		item = slices.Grow(item, n)[:start+n]
+		if _, err := io.ReadFull(r, item[start:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+	}
+	return string(item), nil
+}
+
+// ReadItem reads one serialized item whose size is only known once it is complete, the reader
+// wrapped by c must be a *bufio.Reader: decode is called on prefixes of the buffered bytes, doubling
+// in size, until it returns the item and its size in bytes, which are then consumed, so that nothing
+// is read past the item. decode must not retain its argument. An item larger than the buffer of the
+// reader is reported as bufio.ErrBufferFull, a stream that ends within the item as io.ErrUnexpectedEOF.
+func ReadItem[C any](c *CountingReader, decode func(buf []byte) (C, int, error)) (C, error) {
+	var item C
+	br, ok := c.r.(*bufio.Reader)
+	if !ok {
+		return item, errors.New("the items can only be sized on a *bufio.Reader")
+	}
+	for size := min(16, br.Size()); ; size = min(2*size, br.Size()) {
+		buf, peekErr := br.Peek(size)
+		if len(buf) > 0 {
+			if item, n, err := decode(buf); err == nil {
+				_, err = br.Discard(n)
+				c.n += int64(n)
+				return item, err
+			}
+		}
+		switch {
+		case peekErr == io.EOF:
+			if c.n == 0 && len(buf) == 0 {
+				return item, io.EOF
+			}
+			return item, io.ErrUnexpectedEOF
+		case peekErr != nil:
+			return item, peekErr
+		case size == br.Size():
+			return item, bufio.ErrBufferFull
+		}
+	}
+}
@@ -18,11 +18,14 @@
 package internal
 
 import (
+	"encoding/binary"
 	"fmt"
 	"math"
 	"math/bits"
 	"reflect"
 	"strconv"
+
+	"github.com/twmb/murmur3"
 )
 
 const (
@@ -86,6 +89,19 @@ func IsPowerOf2(powerOf2 int) bool {
 	return powerOf2 > 0 && (powerOf2&(powerOf2-1)) == 0
 }
 
+// ComputeSeedHash returns the 16-bit hash of the given update seed, which is recorded in
+// serialized images to detect sketches built with different seeds.
+func ComputeSeedHash(seed uint64) (uint16, error) {
+	var seedArr [8]byte
+	binary.LittleEndian.PutUint64(seedArr[:], seed)
+	h, _ := murmur3.SeedSum128(0, 0, seedArr[:])
+	seedHash := uint16(h)
+	if seedHash == 0 {
+		return 0, fmt.Errorf("the given seed: %d produced a seedHash of zero, you must choose a different seed", seed)
+	}
+	return seedHash, nil
+}
+
 func BoolToInt(b bool) int {
 	if b {
 		return 1
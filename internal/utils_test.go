@@ -40,3 +40,13 @@ func TestFloorPowerOf2(t *testing.T) {
 	assert.Equal(t, FloorPowerOf2(1<<62), int64(1<<62))
 	assert.Equal(t, FloorPowerOf2((1<<62)+1), int64(1<<62))
 }
+
+func TestComputeSeedHash(t *testing.T) {
+	seedHash, err := ComputeSeedHash(DEFAULT_UPDATE_SEED)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x93cc), seedHash)
+
+	other, err := ComputeSeedHash(123)
+	assert.NoError(t, err)
+	assert.NotEqual(t, seedHash, other)
+}
@@ -18,24 +18,47 @@
 package kll
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/apache/datasketches-go/common"
 	"github.com/apache/datasketches-go/internal"
-	"sort"
-	"unsafe"
+	"io"
+	"math/bits"
+	"math/rand"
+	"slices"
 )
 
+// ItemSketchOp defines the ordering and the serialization of the items of an ItemsSketch.
+// StringItemsSketchOp, Int64ItemsSketchOp and Float64ItemsSketchOp are provided for the common types.
 type ItemSketchOp[C comparable] interface {
-	identity() C
-	lessFn() common.LessFn[C]
-	sizeOf(item C) int
-	sizeOfMany(mem []byte, offsetBytes int, numItems int) (int, error)
+	// Identity returns the item returned along with an error by the queries of an empty sketch.
+	Identity() C
+	// LessFn returns the function defining the strict ordering of the items.
+	LessFn() common.LessFn[C]
+	// SizeOf returns the size in bytes of the serialized item.
+	SizeOf(item C) int
+	// SizeOfMany returns the size in bytes of numItems items serialized in mem at offsetBytes,
+	// or an error if mem is too small to hold them.
+	SizeOfMany(mem []byte, offsetBytes int, numItems int) (int, error)
+	// SerializeManyToSlice returns the concatenated serialization of the items.
 	SerializeManyToSlice(items []C) []byte
+	// SerializeOneToSlice returns the serialization of the item.
 	SerializeOneToSlice(item C) []byte
+	// DeserializeFromSlice returns numItems items deserialized from mem at offsetBytes.
 	DeserializeFromSlice(mem []byte, offsetBytes int, numItems int) ([]C, error)
 }
 
+// ItemReader is an optional interface of an ItemSketchOp, implemented by StringItemsSketchOp,
+// Int64ItemsSketchOp and Float64ItemsSketchOp, with which ItemsSketch.ReadFrom reads the items one
+// at a time from its reader.
+type ItemReader[C comparable] interface {
+	// ReadItem reads exactly one serialized item from r, it returns io.ErrUnexpectedEOF if r ends
+	// within the item.
+	ReadItem(r io.Reader) (C, error)
+}
+
 type ItemsSketch[C comparable] struct {
 	k                 uint16
 	m                 uint8
@@ -48,7 +71,41 @@ type ItemsSketch[C comparable] struct {
 	minItem           *C
 	maxItem           *C
 	sortedView        *ItemsSketchSortedView[C]
+	sortedViewBufs    sortedViewBuffers[C]
 	itemsSketchOp     ItemSketchOp[C]
+	random            *rand.Rand
+}
+
+// SketchOption configures optional parameters of the ItemsSketch and NumericSketch constructors and
+// deserializers.
+type SketchOption func(*sketchOptions)
+
+type sketchOptions struct {
+	random  *rand.Rand
+	workers int
+}
+
+// WithRandSource sets the source of the coin flips choosing which half of the items is kept when a
+// level is compacted. By default the top-level functions of math/rand are used.
+// The source is used by the sketch only, it must not be shared by sketches used concurrently.
+func WithRandSource(src rand.Source) SketchOption {
+	return func(o *sketchOptions) {
+		o.random = rand.New(src)
+	}
+}
+
+// WithRandSeed sets a seeded source of the coin flips, see WithRandSource, so that the sketch of a
+// given sequence of updates is reproducible.
+func WithRandSeed(seed int64) SketchOption {
+	return WithRandSource(rand.NewSource(seed))
+}
+
+func newSketchOptions(opts []SketchOption) sketchOptions {
+	var options sketchOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
 }
 
 const (
@@ -58,6 +115,8 @@ const (
 	_MAX_K     = (1 << 16) - 1
 	_MIN_M     = 2 //The minimum M
 	_MAX_M     = 8 //The maximum M
+	// _MAX_NUM_LEVELS bounds the levels of a sketch, since an item of level l has a weight of 2^l and n is 64 bits.
+	_MAX_NUM_LEVELS = 61
 )
 
 var (
@@ -68,7 +127,7 @@ var (
 		205891132094649}
 )
 
-func NewItemsSketch[C comparable](k uint16, itemsSketchOp ItemSketchOp[C]) (*ItemsSketch[C], error) {
+func NewItemsSketch[C comparable](k uint16, itemsSketchOp ItemSketchOp[C], opts ...SketchOption) (*ItemsSketch[C], error) {
 	if k < _MIN_K || k > _MAX_K {
 		return nil, fmt.Errorf("k must be >= %d and <= %d: %d", _MIN_K, _MAX_K, k)
 	}
@@ -80,10 +139,11 @@ func NewItemsSketch[C comparable](k uint16, itemsSketchOp ItemSketchOp[C]) (*Ite
 		levels:        []uint32{uint32(k), uint32(k)},
 		items:         make([]C, k),
 		itemsSketchOp: itemsSketchOp,
+		random:        newSketchOptions(opts).random,
 	}, nil
 }
 
-func NewItemsSketchFromSlice[C comparable](sl []byte, itemsSketchOp ItemSketchOp[C]) (*ItemsSketch[C], error) {
+func NewItemsSketchFromSlice[C comparable](sl []byte, itemsSketchOp ItemSketchOp[C], opts ...SketchOption) (*ItemsSketch[C], error) {
 
 	memVal, err := newItemsSketchMemoryValidate(sl, itemsSketchOp)
 	if err != nil {
@@ -111,34 +171,49 @@ func NewItemsSketchFromSlice[C comparable](sl []byte, itemsSketchOp ItemSketchOp
 		offset := _N_LONG_ADR
 		deserItems, err := itemsSketchOp.DeserializeFromSlice(sl, offset, 1)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %v", ErrCorruptImage, err)
 		}
 		minItem = &deserItems[0]
 		maxItem = &deserItems[0]
 		items = make([]C, k)
 		items[k-1] = deserItems[0]
 	case _COMPACT_FULL:
-		offset := int(_DATA_START_ADR + memVal.numLevels*4)
+		offset := _DATA_START_ADR + int(memVal.numLevels)*4
 		deserMinItems, err := itemsSketchOp.DeserializeFromSlice(sl, offset, 1)
-		minItem = &deserMinItems[0]
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %v", ErrCorruptImage, err)
 		}
-		offset += itemsSketchOp.sizeOf(*minItem)
+		minItem = &deserMinItems[0]
+		offset += itemsSketchOp.SizeOf(*minItem)
 		deserMaxItems, err := itemsSketchOp.DeserializeFromSlice(sl, offset, 1)
-		maxItem = &deserMaxItems[0]
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %v", ErrCorruptImage, err)
 		}
-		offset += itemsSketchOp.sizeOf(*maxItem)
+		maxItem = &deserMaxItems[0]
+		offset += itemsSketchOp.SizeOf(*maxItem)
 		numRetained := levelsArr[memVal.numLevels] - levelsArr[0]
 		deseRetItems, err := itemsSketchOp.DeserializeFromSlice(sl, offset, int(numRetained))
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %v", ErrCorruptImage, err)
 		}
 		for i := uint32(0); i < numRetained; i++ {
 			items[i+levelsArr[0]] = deseRetItems[i]
 		}
+	case _UPDATABLE:
+		offset := _DATA_START_ADR + (int(memVal.numLevels)+1)*4
+		deserMinMaxItems, err := itemsSketchOp.DeserializeFromSlice(sl, offset, 2)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptImage, err)
+		}
+		if n > 0 {
+			minItem = &deserMinMaxItems[0]
+			maxItem = &deserMinMaxItems[1]
+		}
+		offset += itemsSketchOp.SizeOf(deserMinMaxItems[0]) + itemsSketchOp.SizeOf(deserMinMaxItems[1])
+		items, err = itemsSketchOp.DeserializeFromSlice(sl, offset, int(levelsArr[memVal.numLevels]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptImage, err)
+		}
 	}
 
 	return &ItemsSketch[C]{
@@ -153,6 +228,7 @@ func NewItemsSketchFromSlice[C comparable](sl []byte, itemsSketchOp ItemSketchOp
 		minItem:           minItem,
 		maxItem:           maxItem,
 		itemsSketchOp:     itemsSketchOp,
+		random:            newSketchOptions(opts).random,
 	}, nil
 }
 
@@ -174,14 +250,14 @@ func (s *ItemsSketch[C]) GetNumRetained() uint32 {
 
 func (s *ItemsSketch[C]) GetMinItem() (C, error) {
 	if s.IsEmpty() {
-		return s.itemsSketchOp.identity(), fmt.Errorf("operation is undefined for an empty sketch")
+		return s.itemsSketchOp.Identity(), fmt.Errorf("operation is undefined for an empty sketch")
 	}
 	return *s.minItem, nil
 }
 
 func (s *ItemsSketch[C]) GetMaxItem() (C, error) {
 	if s.IsEmpty() {
-		return s.itemsSketchOp.identity(), fmt.Errorf("operation is undefined for an empty sketch")
+		return s.itemsSketchOp.Identity(), fmt.Errorf("operation is undefined for an empty sketch")
 	}
 	return *s.maxItem, nil
 }
@@ -234,14 +310,14 @@ func (s *ItemsSketch[C]) GetRanks(item []C, inclusive bool) ([]float64, error) {
 
 func (s *ItemsSketch[C]) GetQuantile(rank float64, inclusive bool) (C, error) {
 	if s.IsEmpty() {
-		return s.itemsSketchOp.identity(), fmt.Errorf("operation is undefined for an empty sketch")
+		return s.itemsSketchOp.Identity(), fmt.Errorf("operation is undefined for an empty sketch")
 	}
 	if rank < 0.0 || rank > 1.0 {
-		return s.itemsSketchOp.identity(), fmt.Errorf("normalized rank cannot be less than zero or greater than 1.0: %f", rank)
+		return s.itemsSketchOp.Identity(), fmt.Errorf("normalized rank cannot be less than zero or greater than 1.0: %f", rank)
 	}
 	err := s.setupSortedView()
 	if err != nil {
-		return s.itemsSketchOp.identity(), err
+		return s.itemsSketchOp.Identity(), err
 	}
 	return s.sortedView.GetQuantile(rank, inclusive)
 }
@@ -290,6 +366,36 @@ func (s *ItemsSketch[C]) GetNormalizedRankError(pmf bool) float64 {
 	return getNormalizedRankError(s.minK, pmf)
 }
 
+// GetRankLowerBound returns the lower bound, at a 99% confidence, of the true rank of an item whose
+// rank estimated by GetRank is rank.
+func (s *ItemsSketch[C]) GetRankLowerBound(rank float64) float64 {
+	return getRankLowerBound(rank, s.minK)
+}
+
+// GetRankUpperBound returns the upper bound, at a 99% confidence, of the true rank of an item whose
+// rank estimated by GetRank is rank.
+func (s *ItemsSketch[C]) GetRankUpperBound(rank float64) float64 {
+	return getRankUpperBound(rank, s.minK)
+}
+
+// GetQuantileLowerBound returns the lower bound, at a 99% confidence, of the quantile of the given
+// rank: the inclusive quantile of GetRankLowerBound(rank).
+func (s *ItemsSketch[C]) GetQuantileLowerBound(rank float64) (C, error) {
+	if err := checkNormalizedRankBounds(rank); err != nil {
+		return s.itemsSketchOp.Identity(), err
+	}
+	return s.GetQuantile(s.GetRankLowerBound(rank), true)
+}
+
+// GetQuantileUpperBound returns the upper bound, at a 99% confidence, of the quantile of the given
+// rank: the inclusive quantile of GetRankUpperBound(rank).
+func (s *ItemsSketch[C]) GetQuantileUpperBound(rank float64) (C, error) {
+	if err := checkNormalizedRankBounds(rank); err != nil {
+		return s.itemsSketchOp.Identity(), err
+	}
+	return s.GetQuantile(s.GetRankUpperBound(rank), true)
+}
+
 func (s *ItemsSketch[C]) GetPartitionBoundaries(numEquallySized int, inclusive bool) (*ItemsSketchPartitionBoundaries[C], error) {
 	if s.IsEmpty() {
 		return nil, fmt.Errorf("operation is undefined for an empty sketch")
@@ -302,6 +408,13 @@ func (s *ItemsSketch[C]) GetPartitionBoundaries(numEquallySized int, inclusive b
 	return s.sortedView.GetPartitionBoundaries(numEquallySized, inclusive)
 }
 
+// GetSortedView returns the sorted view of the sketch. The view is immutable, it can be queried by
+// many goroutines at once, including while the sketch is updated: the sketch then builds a new view.
+// The view keeps the buffers of the sketch, so the first view built after an update, by a query or by
+// GetSortedView, allocates new ones. Workloads that interleave updates and queries should use the
+// queries of the sketch, such as GetRank and GetQuantile, which reuse the buffers, rather than call
+// GetSortedView after every update. Until the next update, GetSortedView returns the same view
+// without allocating.
 func (s *ItemsSketch[C]) GetSortedView() (*ItemsSketchSortedView[C], error) {
 	if s.IsEmpty() {
 		return nil, fmt.Errorf("operation is undefined for an empty sketch")
@@ -310,14 +423,86 @@ func (s *ItemsSketch[C]) GetSortedView() (*ItemsSketchSortedView[C], error) {
 	if err != nil {
 		return nil, err
 	}
+	// the view is handed out, its buffers can no longer be reused
+	s.sortedViewBufs.detach()
 	return s.sortedView, nil
 }
 
 func (s *ItemsSketch[C]) Update(item C) {
-	s.updateItem(item, s.itemsSketchOp.lessFn())
+	s.updateItem(item, s.itemsSketchOp.LessFn())
 	s.sortedView = nil
 }
 
+// UpdateWeighted updates the sketch with the given item as many times as its weight, which must be
+// between 1 and 2^61 - 1. Unless level 0 has room for all of them, the copies of the item are not
+// inserted one by one: the item is merged from a sketch that holds it once at each level l such that
+// bit l of the weight is set, an item of level l having a weight of 2^l.
+// It returns an error, leaving the sketch unchanged, if the weight is out of range or if n would
+// overflow.
+func (s *ItemsSketch[C]) UpdateWeighted(item C, weight uint64) error {
+	if err := checkWeight(s.n, weight); err != nil {
+		return err
+	}
+	if internal.IsNil(item) {
+		return nil
+	}
+	if weight < uint64(s.levels[0]) {
+		lessFn := s.itemsSketchOp.LessFn()
+		for i := uint64(0); i < weight; i++ {
+			s.updateItem(item, lessFn)
+		}
+		s.sortedView = nil
+		return nil
+	}
+	return s.Merge(newWeightedItemsSketch(s.k, s.m, item, weight, s.itemsSketchOp))
+}
+
+// checkWeight returns an error if the weight of UpdateWeighted is out of range or if adding it to
+// the n of the sketch would overflow.
+func checkWeight(n uint64, weight uint64) error {
+	if weight == 0 || bits.Len64(weight) > _MAX_NUM_LEVELS {
+		return fmt.Errorf("weight must be >= 1 and < 2^%d: %d", _MAX_NUM_LEVELS, weight)
+	}
+	if n+weight < n {
+		return fmt.Errorf("n would overflow: %d + %d", n, weight)
+	}
+	return nil
+}
+
+// weightedLevels returns the levels and the items of a sketch of n = weight holding one copy of item
+// at each level of a set bit of weight, without free space.
+func weightedLevels[C comparable](item C, weight uint64) (uint8, []uint32, []C) {
+	numLevels := bits.Len64(weight)
+	levels := make([]uint32, numLevels+1)
+	items := make([]C, 0, bits.OnesCount64(weight))
+	for level := 0; level < numLevels; level++ {
+		levels[level] = uint32(len(items))
+		if (weight>>level)&1 == 1 {
+			items = append(items, item)
+		}
+	}
+	levels[numLevels] = uint32(len(items))
+	return uint8(numLevels), levels, items
+}
+
+// newWeightedItemsSketch returns a sketch of n = weight holding one copy of item at each level of a
+// set bit of weight, without free space.
+func newWeightedItemsSketch[C comparable](k uint16, m uint8, item C, weight uint64, itemsSketchOp ItemSketchOp[C]) *ItemsSketch[C] {
+	numLevels, levels, items := weightedLevels(item, weight)
+	return &ItemsSketch[C]{
+		k:             k,
+		m:             m,
+		minK:          k,
+		numLevels:     numLevels,
+		n:             weight,
+		levels:        levels,
+		items:         items,
+		minItem:       &item,
+		maxItem:       &item,
+		itemsSketchOp: itemsSketchOp,
+	}
+}
+
 func (s *ItemsSketch[C]) Reset() {
 	s.n = 0
 	s.isLevelZeroSorted = false
@@ -330,6 +515,30 @@ func (s *ItemsSketch[C]) Reset() {
 }
 
 func (s *ItemsSketch[C]) ToSlice() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ToUpdatableSlice returns the image of the sketch in the updatable layout of the Java KLL sketches:
+// a full preamble whatever n, the levels including the last one, the min and max items and all the
+// items, including the free space of level 0, so that the sketch keeps its capacity once
+// deserialized with NewItemsSketchFromSlice. The min and max items of an empty sketch are the
+// Identity of the ItemSketchOp.
+func (s *ItemsSketch[C]) ToUpdatableSlice() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := s.writeTo(&buf, _UPDATABLE); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo implements io.WriterTo, it writes the image of ToSlice to w without building it in memory:
+// the preamble and the levels are written first, then the min and max items and the retained items,
+// serialized by chunks of internal.StreamChunkItems items.
+func (s *ItemsSketch[C]) WriteTo(w io.Writer) (int64, error) {
 	srcN := s.n
 	var tgtStructure = _COMPACT_FULL
 	if srcN == 0 {
@@ -337,16 +546,13 @@ func (s *ItemsSketch[C]) ToSlice() ([]byte, error) {
 	} else if srcN == 1 {
 		tgtStructure = _COMPACT_SINGLE
 	}
-	totalBytes, err := s.currentSerializedSizeBytes()
-	if err != nil {
-		return nil, err
-	}
-	bytesOut := make([]byte, totalBytes)
+	return s.writeTo(w, tgtStructure)
+}
+
+func (s *ItemsSketch[C]) writeTo(w io.Writer, tgtStructure sketchStructure) (int64, error) {
+	cw := internal.NewCountingWriter(w)
 
 	//ints 0,1
-	preInts := byte(tgtStructure.getPreInts())
-	serVer := byte(tgtStructure.getSerVer())
-	famId := byte(internal.FamilyEnum.Kll.Id)
 	flags := byte(0)
 	if s.IsEmpty() {
 		flags |= _EMPTY_BIT_MASK
@@ -357,50 +563,218 @@ func (s *ItemsSketch[C]) ToSlice() ([]byte, error) {
 	if s.n == 1 {
 		flags |= _SINGLE_ITEM_BIT_MASK
 	}
-	k := uint16(s.k)
-	m := uint8(s.m)
-
-	bytesOut[0] = preInts
-	bytesOut[1] = serVer
-	bytesOut[2] = famId
-	bytesOut[3] = flags
-	binary.LittleEndian.PutUint16(bytesOut[4:6], k)
-	bytesOut[6] = m
+	var preamble [_DATA_START_ADR]byte
+	preamble[0] = byte(tgtStructure.getPreInts())
+	preamble[1] = byte(tgtStructure.getSerVer())
+	preamble[2] = byte(internal.FamilyEnum.Kll.Id)
+	preamble[3] = flags
+	binary.LittleEndian.PutUint16(preamble[4:6], uint16(s.k))
+	preamble[6] = uint8(s.m)
 
 	if tgtStructure == _COMPACT_EMPTY {
-		return bytesOut, nil
+		cw.Write(preamble[:_N_LONG_ADR])
+		return cw.Count(), cw.Err()
 	}
 
 	if tgtStructure == _COMPACT_SINGLE {
-		siByteArr, err := s.getSingleItemByteArr()
+		item, err := s.getSingleItem()
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
-		copy(bytesOut[_DATA_START_ADR_SINGLE_ITEM:], siByteArr)
-		//wbuf.incrementPosition(-len);
-		return bytesOut, nil
+		cw.Write(preamble[:_DATA_START_ADR_SINGLE_ITEM])
+		cw.Write(s.itemsSketchOp.SerializeOneToSlice(item))
+		return cw.Count(), cw.Err()
 	}
 
 	// Tgt is either COMPACT_FULL or UPDATABLE
 	//ints 2,3
-	n := s.n
+	binary.LittleEndian.PutUint64(preamble[8:16], s.n)
 	//ints 4
-	minK := uint16(s.minK)
-	numLevels := uint8(s.numLevels)
+	binary.LittleEndian.PutUint16(preamble[16:18], uint16(s.minK))
+	preamble[18] = uint8(s.numLevels)
 	//end of full preamble
-	lvlsArr := s.getLevelsArray()
-	minMaxByteArr := s.getMinMaxByteArr()
-	itemsByteArr := s.getRetainedItemsByteArr()
+	cw.Write(preamble[:])
+
+	numLevels := int(s.numLevels)
+	if tgtStructure == _UPDATABLE {
+		numLevels++ // the last level
+	}
+	lvlsBytes := make([]byte, numLevels*4)
+	for i := 0; i < numLevels; i++ {
+		binary.LittleEndian.PutUint32(lvlsBytes[i*4:], s.levels[i])
+	}
+	cw.Write(lvlsBytes)
+	minItem, maxItem := s.itemsSketchOp.Identity(), s.itemsSketchOp.Identity()
+	if !s.IsEmpty() {
+		minItem, maxItem = *s.minItem, *s.maxItem
+	}
+	cw.Write(s.itemsSketchOp.SerializeOneToSlice(minItem))
+	cw.Write(s.itemsSketchOp.SerializeOneToSlice(maxItem))
+
+	if tgtStructure == _UPDATABLE {
+		cw.Write(s.itemsSketchOp.SerializeManyToSlice(s.getFreeSpaceItems()))
+	}
+	end := s.levels[s.numLevels]
+	for i := s.levels[0]; i < end && cw.Err() == nil; i += internal.StreamChunkItems {
+		cw.Write(s.itemsSketchOp.SerializeManyToSlice(s.items[i:min(i+internal.StreamChunkItems, end)]))
+	}
+	return cw.Count(), cw.Err()
+}
+
+// ReadFrom implements io.ReaderFrom, it replaces the content of the sketch with the image read from
+// r, see UnmarshalBinary. Unlike a plain io.ReaderFrom, it reads exactly one image and leaves the rest
+// of r unread, so that consecutive images can be read from the same stream, io.EOF is returned if r is
+// already at its end.
+// The preamble and the levels are read and validated first, then the items are deserialized one at a
+// time without holding the image: with ReadItem if the ItemSketchOp implements ItemReader, otherwise
+// r must be a *bufio.Reader whose buffer holds the largest serialized item, which is sized with
+// SizeOfMany on the buffered bytes.
+func (s *ItemsSketch[C]) ReadFrom(r io.Reader) (int64, error) {
+	if s.itemsSketchOp == nil {
+		return 0, fmt.Errorf("the sketch has no ItemSketchOp, it must be constructed with NewItemsSketch")
+	}
+	op := s.itemsSketchOp
+	cr := internal.NewCountingReader(r)
+	readItem := func() (C, error) {
+		return internal.ReadItem(cr, func(buf []byte) (C, int, error) {
+			size, err := op.SizeOfMany(buf, 0, 1)
+			if err != nil {
+				return op.Identity(), 0, err
+			}
+			// the buffer of the reader is not retained by the item
+			items, err := op.DeserializeFromSlice(slices.Clone(buf[:size]), 0, 1)
+			if err != nil {
+				return op.Identity(), 0, fmt.Errorf("%w: %v", ErrCorruptImage, err)
+			}
+			return items[0], size, nil
+		})
+	}
+	if itemReader, ok := op.(ItemReader[C]); ok {
+		readItem = func() (C, error) {
+			return itemReader.ReadItem(cr)
+		}
+	}
+	vlid, minMax, items, err := readSketch[C](cr, 0, func(dst []C) error {
+		for i := range dst {
+			item, err := readItem()
+			if err != nil {
+				return err
+			}
+			dst[i] = item
+		}
+		return nil
+	})
+	if err != nil {
+		return cr.Count(), err
+	}
+	sketch := &ItemsSketch[C]{
+		k:                 vlid.k,
+		m:                 vlid.m,
+		minK:              vlid.minK,
+		numLevels:         vlid.numLevels,
+		isLevelZeroSorted: vlid.level0SortedFlag,
+		n:                 vlid.n,
+		levels:            vlid.levelsArr,
+		items:             items,
+		itemsSketchOp:     op,
+		random:            s.random,
+	}
+	if minMax != nil {
+		sketch.minItem, sketch.maxItem = &minMax[0], &minMax[1]
+	}
+	*s = *sketch
+	return cr.Count(), nil
+}
+
+// readSketch reads exactly one image from cr without holding it: the preamble, then the levels of a
+// full preamble, which are validated before the items are allocated, then the items, which readItems
+// deserializes into the slices it is given, min and max first, then the items of the levels. minMax
+// is nil for an empty sketch. readItems reads items of typeBytes bytes, or sized by the ItemSketchOp
+// if typeBytes is 0, see newSketchMemoryValidate.
+func readSketch[C comparable](cr *internal.CountingReader, typeBytes int, readItems func(dst []C) error) (*itemsSketchMemoryValidate[C], []C, []C, error) {
+	img := make([]byte, _DATA_START_ADR_SINGLE_ITEM)
+	if err := cr.ReadFull(img); err != nil {
+		return nil, nil, nil, err
+	}
+	structure, err := getSketchStructure(getPreInts(img), getSerVer(img))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if structure == _COMPACT_FULL || structure == _UPDATABLE {
+		if img, err = cr.ReadAppend(img, _DATA_START_ADR-_DATA_START_ADR_SINGLE_ITEM); err != nil {
+			return nil, nil, nil, err
+		}
+		numLevelsInImage := int(getNumLevels(img))
+		if structure == _UPDATABLE {
+			numLevelsInImage++
+		}
+		if img, err = cr.ReadAppend(img, numLevelsInImage*4); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	vlid, _, _, err := newSketchPreambleValidate[C](img, nil, typeBytes)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	items := make([]C, vlid.levelsArr[vlid.numLevels])
+	var minMax []C
+	switch vlid.sketchStructure {
+	case _COMPACT_SINGLE:
+		err = readItems(items[vlid.levelsArr[0]:])
+		minMax = []C{items[vlid.levelsArr[0]], items[vlid.levelsArr[0]]}
+	case _COMPACT_FULL:
+		minMax = make([]C, 2)
+		if err = readItems(minMax); err == nil {
+			err = readItems(items[vlid.levelsArr[0]:])
+		}
+	case _UPDATABLE:
+		minMax = make([]C, 2)
+		if err = readItems(minMax); err == nil {
+			err = readItems(items)
+		}
+		if vlid.n == 0 {
+			minMax = nil
+		}
+	}
+	if err == io.EOF {
+		// the preamble has been read, the image is truncated
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return vlid, minMax, items, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, it returns the image of ToSlice.
+func (s *ItemsSketch[C]) MarshalBinary() ([]byte, error) {
+	return s.ToSlice()
+}
 
-	binary.LittleEndian.PutUint64(bytesOut[8:16], n)
-	binary.LittleEndian.PutUint16(bytesOut[16:18], minK)
-	bytesOut[18] = numLevels
-	for i := uint8(0); i < numLevels; i++ {
-		binary.LittleEndian.PutUint32(bytesOut[_DATA_START_ADR+i*4:], lvlsArr[i])
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, it replaces the content of the sketch with
+// the given image, deserialized with the ItemSketchOp of the sketch, which must then have been
+// constructed with NewItemsSketch or NewItemsSketchFromSlice.
+func (s *ItemsSketch[C]) UnmarshalBinary(data []byte) error {
+	if s.itemsSketchOp == nil {
+		return fmt.Errorf("the sketch has no ItemSketchOp, it must be constructed with NewItemsSketch")
+	}
+	sketch, err := NewItemsSketchFromSlice[C](data, s.itemsSketchOp)
+	if err != nil {
+		return err
 	}
-	copy(bytesOut[_DATA_START_ADR+(numLevels*4):], minMaxByteArr)
-	copy(bytesOut[_DATA_START_ADR+int(numLevels*4)+len(minMaxByteArr):], itemsByteArr)
-	return bytesOut, nil
+	sketch.random = s.random
+	*s = *sketch
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder, see MarshalBinary.
+func (s *ItemsSketch[C]) GobEncode() ([]byte, error) {
+	return s.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder, see UnmarshalBinary.
+func (s *ItemsSketch[C]) GobDecode(data []byte) error {
+	return s.UnmarshalBinary(data)
 }
 
 func (s *ItemsSketch[C]) GetSerializedSizeBytes() (int, error) {
@@ -436,12 +810,37 @@ func (s *ItemsSketch[C]) currentSerializedSizeBytes() (int, error) {
 	} else if tgtStructure == _COMPACT_FULL {
 
 		totalBytes = _DATA_START_ADR + s.getLevelsArrSizeBytes(tgtStructure) + s.getMinMaxSizeBytes() + s.getRetainedItemsSizeBytes()
-	} else { //structure = UPDATABLE
-		return 0, fmt.Errorf("updatable serialization not implemented")
 	}
 	return totalBytes, nil
 }
 
+// GetUpdatableSerializedSizeBytes returns the size in bytes of the image of ToUpdatableSlice.
+func (s *ItemsSketch[C]) GetUpdatableSerializedSizeBytes() int {
+	minItem, maxItem := s.itemsSketchOp.Identity(), s.itemsSketchOp.Identity()
+	if !s.IsEmpty() {
+		minItem, maxItem = *s.minItem, *s.maxItem
+	}
+	totalBytes := _DATA_START_ADR + (int(s.numLevels)+1)*4 + s.itemsSketchOp.SizeOf(minItem) + s.itemsSketchOp.SizeOf(maxItem)
+	for _, item := range s.getFreeSpaceItems() {
+		totalBytes += s.itemsSketchOp.SizeOf(item)
+	}
+	return totalBytes + s.getRetainedItemsSizeBytes()
+}
+
+// getFreeSpaceItems returns the items of the free space of level 0, as written to updatable images:
+// they are leftovers of previous compactions, nil ones are replaced with the Identity of the
+// ItemSketchOp so that it can serialize them.
+func (s *ItemsSketch[C]) getFreeSpaceItems() []C {
+	items := make([]C, s.levels[0])
+	for i, item := range s.items[:s.levels[0]] {
+		if internal.IsNil(item) {
+			item = s.itemsSketchOp.Identity()
+		}
+		items[i] = item
+	}
+	return items
+}
+
 func (s *ItemsSketch[C]) getNumLevels() int {
 	return len(s.levels) - 1
 }
@@ -463,16 +862,7 @@ func (s *ItemsSketch[C]) getLevelsArrSizeBytes(structure sketchStructure) int {
 }
 
 func (s *ItemsSketch[C]) getMinMaxSizeBytes() int {
-	return s.itemsSketchOp.sizeOf(*s.minItem) + s.itemsSketchOp.sizeOf(*s.maxItem)
-}
-
-func (s *ItemsSketch[C]) getMinMaxByteArr() []byte {
-	minBytes := s.itemsSketchOp.SerializeOneToSlice(*s.minItem)
-	maxBytes := s.itemsSketchOp.SerializeOneToSlice(*s.maxItem)
-	minMaxBytes := make([]byte, len(minBytes)+len(maxBytes))
-	copy(minMaxBytes, minBytes)
-	copy(minMaxBytes[len(minBytes):], maxBytes)
-	return minMaxBytes
+	return s.itemsSketchOp.SizeOf(*s.minItem) + s.itemsSketchOp.SizeOf(*s.maxItem)
 }
 
 func (s *ItemsSketch[C]) getSingleItemSizeBytes() (int, error) {
@@ -480,20 +870,12 @@ func (s *ItemsSketch[C]) getSingleItemSizeBytes() (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	return s.itemsSketchOp.sizeOf(v) + int(unsafe.Sizeof(uint32(1))), nil
-}
-
-func (s *ItemsSketch[C]) getSingleItemByteArr() ([]byte, error) {
-	v, err := s.getSingleItem()
-	if err != nil {
-		return nil, err
-	}
-	return s.itemsSketchOp.SerializeOneToSlice(v), nil
+	return s.itemsSketchOp.SizeOf(v), nil
 }
 
 func (s *ItemsSketch[C]) getSingleItem() (C, error) {
 	if s.n != 1 {
-		return s.itemsSketchOp.identity(), fmt.Errorf("sketch must have exactly one item")
+		return s.itemsSketchOp.Identity(), fmt.Errorf("sketch must have exactly one item")
 	}
 	return s.items[s.k-1], nil
 }
@@ -514,9 +896,11 @@ func (s *ItemsSketch[C]) getRetainedItemsSizeBytes() int {
 	return len(s.getRetainedItemsByteArr())
 }
 
+// setupSortedView builds the sorted view of the sketch if it was invalidated by an update, reusing
+// the buffers of the previous one unless it was handed out by GetSortedView.
 func (s *ItemsSketch[C]) setupSortedView() error {
 	if s.sortedView == nil {
-		sView, err := newItemsSketchSortedView[C](s)
+		sView, err := newItemsSketchSortedView[C](s, &s.sortedViewBufs)
 		if err != nil {
 			return err
 		}
@@ -542,7 +926,7 @@ func (s *ItemsSketch[C]) updateItem(item C, lessFn common.LessFn[C]) {
 	}
 	level0space := s.levels[0]
 	if level0space == 0 {
-		s.compressWhileUpdatingSketch()
+		s.numLevels, s.levels, s.items = compressWhileUpdating(s.k, s.m, s.numLevels, s.levels, s.items, s.isLevelZeroSorted, s.itemsSketchOp.LessFn(), s.random)
 		level0space = s.levels[0]
 	}
 	s.n++
@@ -552,268 +936,215 @@ func (s *ItemsSketch[C]) updateItem(item C, lessFn common.LessFn[C]) {
 	s.items[nextPos] = item
 }
 
-func (s *ItemsSketch[C]) Merge(other *ItemsSketch[C]) {
+// Merge merges the other sketch into this one. It returns an error, leaving this sketch
+// unchanged, if the other sketch is nil or its min and max items cannot be read.
+func (s *ItemsSketch[C]) Merge(other *ItemsSketch[C]) error {
+	if other == nil {
+		return errors.New("nil sketch")
+	}
 	if other.IsEmpty() {
-		return
+		return nil
+	}
+	if err := s.mergeItemsSketch(other); err != nil {
+		return err
 	}
-	s.mergeItemsSketch(other)
 	s.sortedView = nil
+	return nil
 }
 
-func (s *ItemsSketch[C]) mergeItemsSketch(other *ItemsSketch[C]) {
+// Downsample reduces the k of the sketch to newK, which must be >= 8 and <= its current k, by merging
+// it into an empty sketch of k newK: the sketch retains fewer items, and the rank error of
+// GetNormalizedRankError becomes the one of newK.
+func (s *ItemsSketch[C]) Downsample(newK uint16) error {
+	if newK < _MIN_K || newK > s.k {
+		return fmt.Errorf("new k must be >= %d and <= %d: %d", _MIN_K, s.k, newK)
+	}
+	if newK == s.k {
+		return nil
+	}
+	tgt, err := NewItemsSketch[C](newK, s.itemsSketchOp)
+	if err != nil {
+		return err
+	}
+	tgt.m = s.m
+	tgt.minK = min(newK, s.minK)
+	tgt.random = s.random
+	if err := tgt.Merge(s); err != nil {
+		return err
+	}
+	*s = *tgt
+	return nil
+}
+
+func (s *ItemsSketch[C]) mergeItemsSketch(other *ItemsSketch[C]) error {
 	if other.IsEmpty() {
-		return
+		return nil
+	}
+	if other.minItem == nil || other.maxItem == nil {
+		return errors.New("sketch to merge has no min or max item")
+	}
+	if other == s {
+		// the items of the other sketch are read while this one is updated
+		other = &ItemsSketch[C]{
+			minK:      s.minK,
+			numLevels: s.numLevels,
+			n:         s.n,
+			levels:    s.getLevelsArray(),
+			items:     s.GetTotalItemsArray(),
+			minItem:   s.minItem,
+			maxItem:   s.maxItem,
+		}
 	}
 	// capture my key mutable fields before doing any merging
 	myEmpty := s.IsEmpty()
-	var myMin, myMax C
-	var err error
-	if !myEmpty {
-		myMin, err = s.GetMinItem()
-		if err != nil {
-			panic(err)
-		}
-		myMax, err = s.GetMaxItem()
-		if err != nil {
-			panic(err)
-		}
-	}
+	myMin, myMax := s.minItem, s.maxItem
 	myMinK := s.minK
 	finalN := s.n + other.n
+	lessFn := s.itemsSketchOp.LessFn()
 
-	// buffers that are referenced multiple times
-	otherNumLevels := other.numLevels
-	otherLevelsArr := other.levels
-	var otherItemsArr []C
-
-	// MERGE: update this sketch with level0 items from the other sketch
-	otherItemsArr = other.GetTotalItemsArray()
-	for i := otherLevelsArr[0]; i < otherLevelsArr[1]; i++ {
-		s.updateItem(otherItemsArr[i], s.itemsSketchOp.lessFn())
+	// update this sketch with level0 items from the other sketch
+	for i := other.levels[0]; i < other.levels[1]; i++ {
+		s.updateItem(other.items[i], lessFn)
 	}
 
-	// After the level 0 update, we capture the intermediate state of levels and items arrays...
-	myCurNumLevels := s.numLevels
-	myCurLevelsArr := s.levels
-	myCurItemsArr := s.GetTotalItemsArray()
-
-	// then rename them and initialize in case there are no higher levels
-	myNewNumLevels := myCurNumLevels
-	myNewLevelsArr := myCurLevelsArr
-	myNewItemsArr := myCurItemsArr
-
-	//merge higher levels if they exist
-	if otherNumLevels > 1 {
-		tmpSpaceNeeded := s.GetNumRetained() + getNumRetainedAboveLevelZero(otherNumLevels, otherLevelsArr)
-		workbuf := make([]C, tmpSpaceNeeded)
-		ub := ubOnNumLevels(finalN)
-		worklevels := make([]uint32, ub+2) // ub+1 does not work
-		outlevels := make([]uint32, ub+2)
-
-		provisionalNumLevels := max(myCurNumLevels, otherNumLevels)
-
-		populateItemWorkArrays(workbuf, worklevels, provisionalNumLevels,
-			myCurNumLevels, myCurLevelsArr, myCurItemsArr,
-			otherNumLevels, otherLevelsArr, otherItemsArr, s.itemsSketchOp.lessFn())
-
-		// notice that workbuf is being used as both the input and output
-		result := generalItemsCompress(s.k, s.m, provisionalNumLevels, workbuf, worklevels, workbuf, outlevels, s.isLevelZeroSorted, s.itemsSketchOp.lessFn())
-		targetItemCount := result[1] //was finalCapacity. Max size given k, m, numLevels
-		curItemCount := result[2]    //was finalPop
-
-		// now we need to finalize the results for mySketch
-
-		//THE NEW NUM LEVELS
-		myNewNumLevels = uint8(result[0])
-
-		// THE NEW ITEMS ARRAY
-		if int(targetItemCount) == len(myCurItemsArr) {
-			myNewItemsArr = myCurItemsArr
-		} else {
-			myNewItemsArr = make([]C, targetItemCount)
-		}
-		freeSpaceAtBottom := targetItemCount - curItemCount
-
-		//shift the new items array create space at bottom
-		for i := uint32(0); i < uint32(curItemCount); i++ {
-			myNewItemsArr[uint32(freeSpaceAtBottom)+i] = workbuf[outlevels[0]+i]
-		}
-		theShift := uint32(freeSpaceAtBottom) - outlevels[0]
-
-		//calculate the new levels array length
-		var finalLevelsArrLen uint32
-		if uint32(len(myCurLevelsArr)) < uint32(myNewNumLevels+1) {
-			finalLevelsArrLen = uint32(myNewNumLevels + 1)
-		} else {
-			finalLevelsArrLen = uint32(len(myCurLevelsArr))
-		}
-
-		//THE NEW LEVELS ARRAY
-		myNewLevelsArr = make([]uint32, finalLevelsArrLen)
-		for lvl := uint8(0); lvl < myNewNumLevels+1; lvl++ { // includes the "extra" index
-			myNewLevelsArr[lvl] = outlevels[lvl] + theShift
-		}
-
-		//MEMORY SPACE MANAGEMENT
-		//not used
+	// merge higher levels if they exist
+	if other.numLevels > 1 {
+		s.numLevels, s.levels, s.items = mergeUpperLevels(s.k, s.m, s.numLevels, s.levels, s.items, s.isLevelZeroSorted,
+			other.numLevels, other.levels, other.items, finalN, lessFn, s.random)
 	}
 
-	// Update Preamble:
 	s.n = finalN
 	if other.IsEstimationMode() { //otherwise the merge brings over exact items.
 		s.minK = min(myMinK, other.minK)
 	}
-
-	// Update numLevels, levelsArray, items
-	s.numLevels = myNewNumLevels
-	s.levels = myNewLevelsArr
-	s.items = myNewItemsArr
-
-	// Update min, max items
 	if myEmpty {
 		s.minItem = other.minItem
 		s.maxItem = other.maxItem
 	} else {
-		less := s.itemsSketchOp.lessFn()
-		if less(myMin, *other.minItem) {
-			s.minItem = &myMin
-		} else {
+		s.minItem, s.maxItem = myMin, myMax
+		if lessFn(*other.minItem, *myMin) {
 			s.minItem = other.minItem
 		}
-
-		if less(*other.maxItem, myMax) {
-			s.maxItem = &myMax
-		} else {
+		if lessFn(*myMax, *other.maxItem) {
 			s.maxItem = other.maxItem
 		}
 	}
+	return nil
 }
 
-func (s *ItemsSketch[C]) compressWhileUpdatingSketch() {
-	level := findLevelToCompact(s.k, s.m, s.numLevels, s.levels)
-	if level == s.numLevels-1 {
-		//The level to compact is the top level, thus we need to add a level.
-		//Be aware that this operation grows the items array,
-		//shifts the items data and the level boundaries of the data,
-		//and grows the levels array and increments numLevels_.
-		s.addEmptyTopLevelToCompletelyFullSketch()
-	}
-	myLevelsArr := s.levels
-	rawBeg := myLevelsArr[level]
-	rawEnd := myLevelsArr[level+1]
+// compressWhileUpdating compacts the lowest level of a sketch that reached its capacity, to make
+// room in its full level 0, and returns the new number of levels, levels and items of the sketch.
+// The top level is compacted into a new level added above it, level 0 is sorted before it is
+// compacted unless isLevelZeroSorted.
+func compressWhileUpdating[C comparable](k uint16, m uint8, numLevels uint8, levels []uint32, items []C, isLevelZeroSorted bool,
+	lessFn common.LessFn[C], random *rand.Rand) (uint8, []uint32, []C) {
+	level := findLevelToCompact(k, m, numLevels, levels)
+	if level == numLevels-1 {
+		// the level to compact is the top level, a level is added above it
+		numLevels, levels, items = addEmptyTopLevelToCompletelyFullSketch(k, m, numLevels, levels, items)
+	}
+	rawBeg := levels[level]
+	rawEnd := levels[level+1]
 	// +2 is OK because we already added a new top level if necessary
-	popAbove := myLevelsArr[level+2] - rawEnd
+	popAbove := levels[level+2] - rawEnd
 	rawPop := rawEnd - rawBeg
 	oddPop := rawPop%2 == 1
 	adjBeg := rawBeg
-	if oddPop {
-		adjBeg++
-	}
 	adjPop := rawPop
 	if oddPop {
+		adjBeg++
 		adjPop--
 	}
 	halfAdjPop := adjPop / 2
 
-	//the following is specific to generic Items
-	myItemsArr := s.GetTotalItemsArray()
-	if level == 0 { // level zero might not be sorted, so we must sort it if we wish to compact it
-		lessFn := s.itemsSketchOp.lessFn()
-		tmpSlice := myItemsArr[adjBeg : adjBeg+adjPop]
-		sort.Slice(tmpSlice, func(a, b int) bool {
-			return lessFn(tmpSlice[a], tmpSlice[b])
-		})
+	if level == 0 && !isLevelZeroSorted { // level zero might not be sorted, so we must sort it if we wish to compact it
+		sortItems(items[adjBeg:adjBeg+adjPop], lessFn)
 	}
 	if popAbove == 0 {
-		randomlyHalveUpItems(myItemsArr, adjBeg, adjPop)
+		randomlyHalveUpItems(items, adjBeg, adjPop, random)
 	} else {
-		randomlyHalveDownItems(myItemsArr, adjBeg, adjPop)
-		mergeSortedItemsArrays(
-			myItemsArr, adjBeg, halfAdjPop,
-			myItemsArr, rawEnd, popAbove,
-			myItemsArr, adjBeg+halfAdjPop, s.itemsSketchOp.lessFn())
+		randomlyHalveDownItems(items, adjBeg, adjPop, random)
+		mergeSortedArrays(
+			items, adjBeg, halfAdjPop,
+			items, rawEnd, popAbove,
+			items, adjBeg+halfAdjPop, lessFn)
 	}
-	newIndex := myLevelsArr[level+1] - halfAdjPop // adjust boundaries of the level above
-	s.levels[level+1] = newIndex
-
+	levels[level+1] -= halfAdjPop // adjust boundaries of the level above
 	if oddPop {
-		s.levels[level] = myLevelsArr[level+1] - 1          // the current level now contains one item
-		myItemsArr[myLevelsArr[level]] = myItemsArr[rawBeg] // namely this leftover guy
+		levels[level] = levels[level+1] - 1  // the current level now contains one item
+		items[levels[level]] = items[rawBeg] // namely this leftover guy
 	} else {
-		s.levels[level] = myLevelsArr[level+1] // the current level is now empty
+		levels[level] = levels[level+1] // the current level is now empty
 	}
 
+	// shift the levels below up by halfAdjPop, copy handles the overlap of the ranges
 	if level > 0 {
-		amount := rawBeg - myLevelsArr[0] // adjust boundary
-
-		for i := amount; i > 0; i-- {
-			// Start from the end as we are shifting to the right,
-			// failing to do so will corrupt the items array.
-			tgtInx := myLevelsArr[0] + halfAdjPop + i - 1
-			stcInx := myLevelsArr[0] + i - 1
-			myItemsArr[tgtInx] = myItemsArr[stcInx]
-		}
+		amount := rawBeg - levels[0]
+		copy(items[levels[0]+halfAdjPop:levels[0]+halfAdjPop+amount], items[levels[0]:levels[0]+amount])
 	}
 	for lvl := uint8(0); lvl < level; lvl++ {
-		newIndex = myLevelsArr[lvl] + halfAdjPop //adjust boundary
-		s.levels[lvl] = newIndex
+		levels[lvl] += halfAdjPop
 	}
-	s.items = myItemsArr
+	return numLevels, levels, items
 }
 
-func (s *ItemsSketch[C]) addEmptyTopLevelToCompletelyFullSketch() {
-	myCurLevelsArr := s.getLevelsArray()
-	myCurNumLevels := s.numLevels
-	myCurTotalItemsCapacity := myCurLevelsArr[myCurNumLevels]
-
-	myCurItemsArr := s.GetTotalItemsArray()
-	minItem := s.minItem
-	maxItem := s.maxItem
-
-	deltaItemsCap := levelCapacity(s.k, myCurNumLevels+1, 0, s.m)
-	myNewTotalItemsCapacity := myCurTotalItemsCapacity + deltaItemsCap
-
-	// Check if growing the levels arr if required.
-	// Note that merging MIGHT over-grow levels_, in which case we might not have to grow it
-	growLevelsArr := len(myCurLevelsArr) < int(myCurNumLevels+2)
-
-	var (
-		myNewLevelsArr []uint32
-		myNewNumLevels uint8
-	)
-
-	//myNewLevelsArr := make([]uint32, myCurNumLevels+2)
-	// GROW LEVELS ARRAY
-	if growLevelsArr {
-		//grow levels arr by one and copy the old data to the new array, extra space at the top.
-		myNewLevelsArr = make([]uint32, myCurNumLevels+2)
-		copy(myNewLevelsArr, myCurLevelsArr)
-		myNewNumLevels = myCurNumLevels + 1
-		s.numLevels++ //increment for off-heap
-	} else {
-		myNewLevelsArr = myCurLevelsArr
-		myNewNumLevels = myCurNumLevels
-	}
+// addEmptyTopLevelToCompletelyFullSketch grows the items of a sketch by the capacity of a new level 0,
+// shifting the levels up, and returns the new number of levels, levels and items of the sketch.
+func addEmptyTopLevelToCompletelyFullSketch[C comparable](k uint16, m uint8, numLevels uint8, levels []uint32, items []C) (uint8, []uint32, []C) {
+	curTotalItemsCapacity := levels[numLevels]
+	deltaItemsCap := levelCapacity(k, numLevels+1, 0, m)
+	newTotalItemsCapacity := curTotalItemsCapacity + deltaItemsCap
 
-	// This loop updates all level indices EXCLUDING the "extra" index at the top
-	for level := uint8(0); level <= myNewNumLevels-1; level++ {
-		myNewLevelsArr[level] += deltaItemsCap
+	newLevelsArr := make([]uint32, numLevels+2)
+	for level := uint8(0); level < numLevels+1; level++ {
+		newLevelsArr[level] = levels[level] + deltaItemsCap
 	}
-	myNewLevelsArr[myNewNumLevels] = myNewTotalItemsCapacity // initialize the new "extra" index at the top
+	numLevels++
+	newLevelsArr[numLevels] = newTotalItemsCapacity // the new "extra" index at the top
 
-	// GROW items ARRAY
-	myNewItemsArr := make([]C, myNewTotalItemsCapacity)
-	for i := uint32(0); i < myCurTotalItemsCapacity; i++ {
-		myNewItemsArr[i+deltaItemsCap] = myCurItemsArr[i]
-	}
-
-	// update our sketch with new expanded spaces
-	s.numLevels = myNewNumLevels
-	s.levels = myNewLevelsArr
+	newItemsArr := make([]C, newTotalItemsCapacity)
+	copy(newItemsArr[deltaItemsCap:], items[:curTotalItemsCapacity])
+	return numLevels, newLevelsArr, newItemsArr
+}
 
-	s.minItem = minItem
-	s.maxItem = maxItem
-	s.items = myNewItemsArr
+// mergeUpperLevels merges the levels above level 0 of the other sketch into a sketch whose level 0
+// already received the items of the level 0 of the other one, compacts the result to the capacity
+// of the sketch and returns its new number of levels, levels and items.
+func mergeUpperLevels[C comparable](k uint16, m uint8, numLevels uint8, levels []uint32, items []C, isLevelZeroSorted bool,
+	otherNumLevels uint8, otherLevels []uint32, otherItems []C, finalN uint64,
+	lessFn common.LessFn[C], random *rand.Rand) (uint8, []uint32, []C) {
+	tmpSpaceNeeded := levels[numLevels] - levels[0] + getNumRetainedAboveLevelZero(otherNumLevels, otherLevels)
+	workbuf := make([]C, tmpSpaceNeeded)
+	ub := ubOnNumLevels(finalN)
+	worklevels := make([]uint32, ub+2) // ub+1 does not work
+	outlevels := make([]uint32, ub+2)
+
+	provisionalNumLevels := max(numLevels, otherNumLevels)
+
+	populateWorkArrays(workbuf, worklevels, provisionalNumLevels,
+		numLevels, levels, items,
+		otherNumLevels, otherLevels, otherItems, lessFn)
+
+	// workbuf is used as both the input and the output
+	result := generalCompress(k, m, provisionalNumLevels, workbuf, worklevels, workbuf, outlevels, isLevelZeroSorted, lessFn, random)
+	newNumLevels := uint8(result[0])
+	targetItemCount := result[1] // the capacity given k, m and the number of levels
+	curItemCount := result[2]
+
+	newItemsArr := items
+	if int(targetItemCount) != len(items) {
+		newItemsArr = make([]C, targetItemCount)
+	}
+	// shift the items to the top, the free space is at the bottom
+	freeSpaceAtBottom := targetItemCount - curItemCount
+	copy(newItemsArr[freeSpaceAtBottom:], workbuf[outlevels[0]:outlevels[0]+curItemCount])
+	theShift := freeSpaceAtBottom - outlevels[0]
+
+	newLevelsArr := make([]uint32, newNumLevels+1) // includes the "extra" index
+	for lvl := range newLevelsArr {
+		newLevelsArr[lvl] = outlevels[lvl] + theShift
+	}
+	return newNumLevels, newLevelsArr, newItemsArr
 }
 
 func findLevelToCompact(k uint16, m uint8, numLevels uint8, levels []uint32) uint8 {
@@ -861,35 +1192,56 @@ func intCapAuxAux(k uint16, depth uint8) uint32 {
 	return uint32(k)
 }
 
-func randomlyHalveUpItems[C comparable](buf []C, start uint32, length uint32) {
+// randomlyHalveUpItems keeps the items of even or odd index, chosen by a coin flip, of the sorted
+// items buf[start:start+length], and moves them to the upper half of the range.
+func randomlyHalveUpItems[C comparable](buf []C, start uint32, length uint32, random *rand.Rand) {
 	halfLength := length / 2
-	//offset := rand.Intn(2)
-	offset := 1
-	j := (start + length) - 1 - uint32(offset)
+	offset := coinFlip(random)
+	j := (start + length) - 1 - offset
 	for i := (start + length) - 1; i >= (start + halfLength); i-- {
 		buf[i] = buf[j]
 		j -= 2
 	}
 }
 
-func randomlyHalveDownItems[C comparable](buf []C, start uint32, length uint32) {
+// randomlyHalveDownItems is randomlyHalveUpItems moving the kept items to the lower half of the range.
+func randomlyHalveDownItems[C comparable](buf []C, start uint32, length uint32, random *rand.Rand) {
 	halfLength := length / 2
-	//offset := rand.Intn(2)
-	offset := 1
-	j := start + uint32(offset)
+	offset := coinFlip(random)
+	j := start + offset
 	for i := start; i < (start + halfLength); i++ {
 		buf[i] = buf[j]
 		j += 2
 	}
 }
 
-func mergeSortedItemsArrays[C comparable](bufA []C, startA uint32, lenA uint32,
+// coinFlip returns 0 or 1 with equal probability, from random or from math/rand if it is nil.
+func coinFlip(random *rand.Rand) uint32 {
+	if random == nil {
+		return uint32(rand.Int63() & 1)
+	}
+	return uint32(random.Int63() & 1)
+}
+
+// sortItems sorts the items in the order of lessFn.
+func sortItems[C comparable](items []C, lessFn common.LessFn[C]) {
+	slices.SortFunc(items, func(a, b C) int {
+		if lessFn(a, b) {
+			return -1
+		}
+		if lessFn(b, a) {
+			return 1
+		}
+		return 0
+	})
+}
+
+func mergeSortedArrays[C comparable](bufA []C, startA uint32, lenA uint32,
 	bufB []C, startB uint32, lenB uint32,
 	bufC []C, startC uint32, lessFn common.LessFn[C]) {
-	lenC := lenA + lenB
 	limA := startA + lenA
 	limB := startB + lenB
-	limC := startC + lenC
+	limC := startC + lenA + lenB
 
 	a := startA
 	b := startB
@@ -911,7 +1263,7 @@ func mergeSortedItemsArrays[C comparable](bufA []C, startA uint32, lenA uint32,
 	}
 }
 
-func populateItemWorkArrays[C comparable](workbuf []C, worklevels []uint32, provisionalNumLevels uint8,
+func populateWorkArrays[C comparable](workbuf []C, worklevels []uint32, provisionalNumLevels uint8,
 	myCurNumLevels uint8, myCurLevelsArr []uint32, myCurItemsArr []C,
 	otherNumLevels uint8, otherLevelsArr []uint32, otherItemsArr []C,
 	lessFn common.LessFn[C]) {
@@ -919,9 +1271,7 @@ func populateItemWorkArrays[C comparable](workbuf []C, worklevels []uint32, prov
 	worklevels[0] = 0
 	// Note: the level zero data from "other" was already inserted into "self"
 	selfPopZero := currentLevelSizeItems(0, myCurNumLevels, myCurLevelsArr)
-	for i := uint32(0); i < selfPopZero; i++ {
-		workbuf[worklevels[0]+i] = myCurItemsArr[myCurLevelsArr[0]+i]
-	}
+	copy(workbuf[worklevels[0]:], myCurItemsArr[myCurLevelsArr[0]:myCurLevelsArr[0]+selfPopZero])
 	worklevels[1] = worklevels[0] + selfPopZero
 
 	for lvl := uint8(1); lvl < provisionalNumLevels; lvl++ {
@@ -930,15 +1280,11 @@ func populateItemWorkArrays[C comparable](workbuf []C, worklevels []uint32, prov
 		worklevels[lvl+1] = worklevels[lvl] + selfPop + otherPop
 
 		if selfPop > 0 && otherPop == 0 {
-			for i := uint32(0); i < selfPop; i++ {
-				workbuf[worklevels[lvl]+i] = myCurItemsArr[myCurLevelsArr[lvl]+i]
-			}
+			copy(workbuf[worklevels[lvl]:], myCurItemsArr[myCurLevelsArr[lvl]:myCurLevelsArr[lvl]+selfPop])
 		} else if selfPop == 0 && otherPop > 0 {
-			for i := uint32(0); i < otherPop; i++ {
-				workbuf[worklevels[lvl]+i] = otherItemsArr[otherLevelsArr[lvl]+i]
-			}
+			copy(workbuf[worklevels[lvl]:], otherItemsArr[otherLevelsArr[lvl]:otherLevelsArr[lvl]+otherPop])
 		} else if selfPop > 0 && otherPop > 0 {
-			mergeSortedItemsArrays(
+			mergeSortedArrays(
 				myCurItemsArr, myCurLevelsArr[lvl], selfPop,
 				otherItemsArr, otherLevelsArr[lvl], otherPop,
 				workbuf, worklevels[lvl], lessFn)
@@ -946,7 +1292,7 @@ func populateItemWorkArrays[C comparable](workbuf []C, worklevels []uint32, prov
 	}
 }
 
-func generalItemsCompress[C comparable](
+func generalCompress[C comparable](
 	k uint16,
 	m uint8,
 	numLevelsIn uint8,
@@ -955,16 +1301,13 @@ func generalItemsCompress[C comparable](
 	outBuf []C,
 	outLevels []uint32,
 	isLevelZeroSorted bool,
-	lessFn common.LessFn[C]) []uint32 {
+	lessFn common.LessFn[C],
+	random *rand.Rand) []uint32 {
 	numLevels := numLevelsIn
 	currentItemCount := inLevels[numLevels] - inLevels[0]        // decreases with each compaction
 	targetItemCount := computeTotalItemCapacity(k, m, numLevels) // increases if we add levels
-	doneYet := false
 	outLevels[0] = 0
-	curLevel := -1
-	for !doneYet {
-		curLevel++ // start out at level 0
-
+	for curLevel := 0; curLevel < int(numLevels); curLevel++ {
 		// If we are at the current top level, add an empty level above it for convenience,
 		// but do not increment numLevels until later
 		if curLevel == (int(numLevels) - 1) {
@@ -975,71 +1318,58 @@ func generalItemsCompress[C comparable](
 		rawLim := inLevels[curLevel+1]
 		rawPop := rawLim - rawBeg
 
-		if (uint32(currentItemCount) < targetItemCount) || (rawPop < levelCapacity(k, numLevels, uint8(curLevel), m)) {
-			for i := uint32(0); i < rawPop; i++ {
-				outBuf[outLevels[curLevel]+i] = inBuf[rawBeg+i]
-			}
+		if (currentItemCount < targetItemCount) || (rawPop < levelCapacity(k, numLevels, uint8(curLevel), m)) {
+			copy(outBuf[outLevels[curLevel]:], inBuf[rawBeg:rawLim])
 			outLevels[curLevel+1] = outLevels[curLevel] + rawPop
-		} else {
-			// The sketch is too full AND this level is too full, so we compact it
-			// Note: this can add a level and thus change the sketch's capacity
-
-			popAbove := inLevels[curLevel+2] - rawLim
-			oddPop := rawPop%2 == 1
-			adjBeg := rawBeg
-			if oddPop {
-				adjBeg++
-			}
-			adjPop := rawPop
-			if oddPop {
-				adjPop--
-			}
-			halfAdjPop := adjPop / 2
+			continue
+		}
 
-			if oddPop {
-				outBuf[outLevels[curLevel]] = inBuf[rawBeg]
-				outLevels[curLevel+1] = outLevels[curLevel] + 1
-			} else {
-				outLevels[curLevel+1] = outLevels[curLevel]
-			}
+		// The sketch is too full AND this level is too full, so we compact it
+		// Note: this can add a level and thus change the sketch's capacity
+		popAbove := inLevels[curLevel+2] - rawLim
+		oddPop := rawPop%2 == 1
+		adjBeg := rawBeg
+		adjPop := rawPop
+		if oddPop {
+			adjBeg++
+			adjPop--
+		}
+		halfAdjPop := adjPop / 2
 
-			// level zero might not be sorted, so we must sort it if we wish to compact it
-			if (curLevel == 0) && !isLevelZeroSorted {
-				tmpSlice := inBuf[adjBeg : adjBeg+adjPop]
-				sort.Slice(tmpSlice, func(a, b int) bool {
-					return lessFn(tmpSlice[a], tmpSlice[b])
-				})
-			}
+		if oddPop {
+			outBuf[outLevels[curLevel]] = inBuf[rawBeg]
+			outLevels[curLevel+1] = outLevels[curLevel] + 1
+		} else {
+			outLevels[curLevel+1] = outLevels[curLevel]
+		}
 
-			if popAbove == 0 {
-				randomlyHalveUpItems(inBuf, adjBeg, adjPop)
-			} else {
-				randomlyHalveDownItems(inBuf, adjBeg, adjPop)
-				mergeSortedItemsArrays(
-					inBuf, adjBeg, halfAdjPop,
-					inBuf, rawLim, popAbove,
-					inBuf, adjBeg+halfAdjPop, lessFn)
-			}
+		// level zero might not be sorted, so we must sort it if we wish to compact it
+		if (curLevel == 0) && !isLevelZeroSorted {
+			sortItems(inBuf[adjBeg:adjBeg+adjPop], lessFn)
+		}
 
-			// track the fact that we just eliminated some data
-			currentItemCount -= halfAdjPop
+		if popAbove == 0 {
+			randomlyHalveUpItems(inBuf, adjBeg, adjPop, random)
+		} else {
+			randomlyHalveDownItems(inBuf, adjBeg, adjPop, random)
+			mergeSortedArrays(
+				inBuf, adjBeg, halfAdjPop,
+				inBuf, rawLim, popAbove,
+				inBuf, adjBeg+halfAdjPop, lessFn)
+		}
 
-			// Adjust the boundaries of the level above
-			inLevels[curLevel+1] = inLevels[curLevel+1] - halfAdjPop
+		// track the fact that we just eliminated some data
+		currentItemCount -= halfAdjPop
 
-			// Increment numLevels if we just compacted the old top level
-			// This creates some more capacity (the size of the new bottom level)
-			if curLevel == (int(numLevels) - 1) {
-				numLevels++
-				targetItemCount += levelCapacity(k, numLevels, 0, m)
-			}
-		} // end of code for compacting a level
+		// Adjust the boundaries of the level above
+		inLevels[curLevel+1] = inLevels[curLevel+1] - halfAdjPop
 
-		// determine whether we have processed all levels yet (including any new levels that we created)
+		// Increment numLevels if we just compacted the old top level
+		// This creates some more capacity (the size of the new bottom level)
 		if curLevel == (int(numLevels) - 1) {
-			doneYet = true
+			numLevels++
+			targetItemCount += levelCapacity(k, numLevels, 0, m)
 		}
-	} // end of loop over levels
-
+	}
 	return []uint32{uint32(numLevels), targetItemCount, currentItemCount}
 }
@@ -0,0 +1,233 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kll
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/apache/datasketches-go/common"
+	"github.com/apache/datasketches-go/internal"
+)
+
+// StringItemsSketchOp is the ItemSketchOp of string items, serialized as the Java ArrayOfStringsSerDe
+// does: each item is prefixed by its length in bytes as a 32-bit little-endian integer. Items are
+// ordered by byte-wise comparison and are not required to be valid UTF-8, so raw byte sequences can
+// be sketched as string(b).
+type StringItemsSketchOp struct{}
+
+func (StringItemsSketchOp) Identity() string {
+	return ""
+}
+
+func (StringItemsSketchOp) LessFn() common.LessFn[string] {
+	return func(a string, b string) bool {
+		return a < b
+	}
+}
+
+func (StringItemsSketchOp) SizeOf(item string) int {
+	return 4 + len(item)
+}
+
+func (StringItemsSketchOp) SizeOfMany(mem []byte, offsetBytes int, numItems int) (int, error) {
+	offset := offsetBytes
+	for i := 0; i < numItems; i++ {
+		if !checkBounds(offset, 4, len(mem)) {
+			return 0, errors.New("offset out of bounds")
+		}
+		itemLen := int(binary.LittleEndian.Uint32(mem[offset:]))
+		offset += 4
+		if !checkBounds(offset, itemLen, len(mem)) {
+			return 0, errors.New("offset out of bounds")
+		}
+		offset += itemLen
+	}
+	return offset - offsetBytes, nil
+}
+
+func (StringItemsSketchOp) SerializeOneToSlice(item string) []byte {
+	out := make([]byte, 4+len(item))
+	binary.LittleEndian.PutUint32(out, uint32(len(item)))
+	copy(out[4:], item)
+	return out
+}
+
+func (op StringItemsSketchOp) SerializeManyToSlice(items []string) []byte {
+	totalBytes := 0
+	for _, item := range items {
+		totalBytes += op.SizeOf(item)
+	}
+	out := make([]byte, totalBytes)
+	offset := 0
+	for _, item := range items {
+		binary.LittleEndian.PutUint32(out[offset:], uint32(len(item)))
+		offset += 4
+		offset += copy(out[offset:], item)
+	}
+	return out
+}
+
+func (StringItemsSketchOp) DeserializeFromSlice(mem []byte, offsetBytes int, numItems int) ([]string, error) {
+	if numItems <= 0 {
+		return []string{}, nil
+	}
+	items := make([]string, numItems)
+	offset := offsetBytes
+	for i := range items {
+		if !checkBounds(offset, 4, len(mem)) {
+			return nil, errors.New("offset out of bounds")
+		}
+		itemLen := int(binary.LittleEndian.Uint32(mem[offset:]))
+		offset += 4
+		if !checkBounds(offset, itemLen, len(mem)) {
+			return nil, errors.New("offset out of bounds")
+		}
+		items[i] = string(mem[offset : offset+itemLen])
+		offset += itemLen
+	}
+	return items, nil
+}
+
+// ReadItem implements ItemReader, see internal.ReadLengthPrefixed.
+func (StringItemsSketchOp) ReadItem(r io.Reader) (string, error) {
+	return internal.ReadLengthPrefixed(r)
+}
+
+// Int64ItemsSketchOp is the ItemSketchOp of int64 items, serialized as the Java ArrayOfLongsSerDe
+// does: 8 bytes per item in little-endian order.
+type Int64ItemsSketchOp struct{}
+
+func (Int64ItemsSketchOp) Identity() int64 {
+	return 0
+}
+
+func (Int64ItemsSketchOp) LessFn() common.LessFn[int64] {
+	return func(a int64, b int64) bool {
+		return a < b
+	}
+}
+
+func (Int64ItemsSketchOp) SizeOf(item int64) int {
+	return 8
+}
+
+func (Int64ItemsSketchOp) SizeOfMany(mem []byte, offsetBytes int, numItems int) (int, error) {
+	return sizeOfManyFixed(mem, offsetBytes, numItems, 8)
+}
+
+func (op Int64ItemsSketchOp) SerializeOneToSlice(item int64) []byte {
+	return op.SerializeManyToSlice([]int64{item})
+}
+
+func (Int64ItemsSketchOp) SerializeManyToSlice(items []int64) []byte {
+	out := make([]byte, 8*len(items))
+	for i, item := range items {
+		binary.LittleEndian.PutUint64(out[i*8:], uint64(item))
+	}
+	return out
+}
+
+func (Int64ItemsSketchOp) DeserializeFromSlice(mem []byte, offsetBytes int, numItems int) ([]int64, error) {
+	if _, err := sizeOfManyFixed(mem, offsetBytes, numItems, 8); err != nil {
+		return nil, err
+	}
+	items := make([]int64, max(numItems, 0))
+	for i := range items {
+		items[i] = int64(binary.LittleEndian.Uint64(mem[offsetBytes+i*8:]))
+	}
+	return items, nil
+}
+
+// ReadItem implements ItemReader.
+func (Int64ItemsSketchOp) ReadItem(r io.Reader) (int64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(buf[:])), nil
+}
+
+// Float64ItemsSketchOp is the ItemSketchOp of float64 items, serialized as the Java ArrayOfDoublesSerDe
+// does: the IEEE 754 bits of each item on 8 bytes in little-endian order. NaN is not ordered and
+// must not be presented to the sketch.
+type Float64ItemsSketchOp struct{}
+
+func (Float64ItemsSketchOp) Identity() float64 {
+	return 0
+}
+
+func (Float64ItemsSketchOp) LessFn() common.LessFn[float64] {
+	return func(a float64, b float64) bool {
+		return a < b
+	}
+}
+
+func (Float64ItemsSketchOp) SizeOf(item float64) int {
+	return 8
+}
+
+func (Float64ItemsSketchOp) SizeOfMany(mem []byte, offsetBytes int, numItems int) (int, error) {
+	return sizeOfManyFixed(mem, offsetBytes, numItems, 8)
+}
+
+func (op Float64ItemsSketchOp) SerializeOneToSlice(item float64) []byte {
+	return op.SerializeManyToSlice([]float64{item})
+}
+
+func (Float64ItemsSketchOp) SerializeManyToSlice(items []float64) []byte {
+	out := make([]byte, 8*len(items))
+	for i, item := range items {
+		binary.LittleEndian.PutUint64(out[i*8:], math.Float64bits(item))
+	}
+	return out
+}
+
+func (Float64ItemsSketchOp) DeserializeFromSlice(mem []byte, offsetBytes int, numItems int) ([]float64, error) {
+	if _, err := sizeOfManyFixed(mem, offsetBytes, numItems, 8); err != nil {
+		return nil, err
+	}
+	items := make([]float64, max(numItems, 0))
+	for i := range items {
+		items[i] = math.Float64frombits(binary.LittleEndian.Uint64(mem[offsetBytes+i*8:]))
+	}
+	return items, nil
+}
+
+// ReadItem implements ItemReader.
+func (Float64ItemsSketchOp) ReadItem(r io.Reader) (float64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(buf[:])), nil
+}
+
+// sizeOfManyFixed returns the size in bytes of numItems items of itemSize bytes, or an error if
+// mem is too small to hold them at offsetBytes.
+func sizeOfManyFixed(mem []byte, offsetBytes int, numItems int, itemSize int) (int, error) {
+	if numItems <= 0 {
+		return 0, nil
+	}
+	if numItems > len(mem)/itemSize || !checkBounds(offsetBytes, numItems*itemSize, len(mem)) {
+		return 0, errors.New("offset out of bounds")
+	}
+	return numItems * itemSize, nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kll
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemsSketchOp_Int64(t *testing.T) {
+	op := Int64ItemsSketchOp{}
+	sl := op.SerializeManyToSlice([]int64{1, -2})
+	assert.Equal(t, []byte{1, 0, 0, 0, 0, 0, 0, 0, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, sl)
+	size, err := op.SizeOfMany(sl, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 16, size)
+	_, err = op.SizeOfMany(sl, 8, 2)
+	assert.Error(t, err)
+	items, err := op.DeserializeFromSlice(sl, 8, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-2}, items)
+	_, err = op.DeserializeFromSlice(sl, 0, math.MaxInt32)
+	assert.Error(t, err)
+
+	sketch, err := NewItemsSketch[int64](200, op)
+	assert.NoError(t, err)
+	for i := int64(1); i <= 10000; i++ {
+		sketch.Update(i)
+	}
+	sl, err = sketch.ToSlice()
+	assert.NoError(t, err)
+	sketch2, err := NewItemsSketchFromSlice[int64](sl, op)
+	assert.NoError(t, err)
+	assert.Equal(t, sketch.GetN(), sketch2.GetN())
+	minV, err := sketch2.GetMinItem()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), minV)
+	maxV, err := sketch2.GetMaxItem()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10000), maxV)
+	median, err := sketch2.GetQuantile(0.5, true)
+	assert.NoError(t, err)
+	assert.InDelta(t, 5000, median, 10000*PMF_EPS_FOR_K_256)
+}
+
+func TestItemsSketchOp_Float64(t *testing.T) {
+	op := Float64ItemsSketchOp{}
+	sl := op.SerializeOneToSlice(1.5)
+	assert.Equal(t, math.Float64bits(1.5), binary.LittleEndian.Uint64(sl))
+	assert.Equal(t, op.SizeOf(1.5), len(sl))
+
+	sketch, err := NewItemsSketch[float64](200, op)
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		sketch.Update(float64(i) / 10000)
+	}
+	sl, err = sketch.ToSlice()
+	assert.NoError(t, err)
+	sketch2, err := NewItemsSketchFromSlice[float64](sl, op)
+	assert.NoError(t, err)
+	rank, err := sketch2.GetRank(0.25, true)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.25, rank, PMF_EPS_FOR_K_256)
+}
+
+func TestItemsSketchOp_String(t *testing.T) {
+	op := StringItemsSketchOp{}
+	items := []string{"", "abc", "\xff\x00"}
+	sl := op.SerializeManyToSlice(items)
+	assert.Equal(t, []byte{0, 0, 0, 0, 3, 0, 0, 0, 'a', 'b', 'c', 2, 0, 0, 0, 0xff, 0}, sl)
+	assert.Equal(t, op.SerializeOneToSlice(""), sl[:op.SizeOf("")])
+	size, err := op.SizeOfMany(sl, 0, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, len(sl), size)
+	_, err = op.SizeOfMany(sl[:len(sl)-1], 0, 3)
+	assert.Error(t, err)
+	deser, err := op.DeserializeFromSlice(sl, 0, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, items, deser)
+	_, err = op.DeserializeFromSlice(sl, 4, 3)
+	assert.Error(t, err)
+}
@@ -20,9 +20,13 @@ package kll
 import (
 	"errors"
 	"github.com/apache/datasketches-go/internal"
+	"slices"
 	"sort"
 )
 
+// ItemsSketchSortedView is the sorted view of an ItemsSketch, its items sorted along with their
+// cumulative weights. A view returned by ItemsSketch.GetSortedView is immutable: later updates of the
+// sketch build a new view, so that it can be shared by goroutines querying it concurrently.
 type ItemsSketchSortedView[C comparable] struct {
 	quantiles     []C
 	cumWeights    []int64
@@ -30,14 +34,36 @@ type ItemsSketchSortedView[C comparable] struct {
 	maxItem       C
 	minItem       C
 	itemsSketchOp ItemSketchOp[C]
+	minK          uint16 //of the sketch, for the bounds of the quantiles
 }
 
-func newItemsSketchSortedView[C comparable](sketch *ItemsSketch[C]) (*ItemsSketchSortedView[C], error) {
+// sortedViewBuffers holds the slices a sketch builds its sorted view into, so that they are reused
+// when the view is rebuilt after updates. The quantiles and the cumulative weights are those of the
+// current view, they are dropped once the view is handed out by GetSortedView.
+type sortedViewBuffers[C any] struct {
+	quantiles    []C
+	cumWeights   []int64
+	quantilesTmp []C //for the merge sort of the levels
+	weightsTmp   []int64
+}
+
+// detach drops the quantiles and the cumulative weights of the current view, which are then owned
+// by the view only.
+func (b *sortedViewBuffers[C]) detach() {
+	b.quantiles = nil
+	b.cumWeights = nil
+}
+
+// resize returns buf resized to n, reusing its array if it is large enough.
+func resize[T any](buf []T, n int) []T {
+	return slices.Grow(buf[:0], n)[:n]
+}
+
+func newItemsSketchSortedView[C comparable](sketch *ItemsSketch[C], bufs *sortedViewBuffers[C]) (*ItemsSketchSortedView[C], error) {
 	if sketch.IsEmpty() {
 		return nil, errors.New("empty sketch")
 	}
 	totalN := sketch.GetN()
-	srcQuantiles := sketch.GetTotalItemsArray()
 	srcLevels := sketch.levels
 	srcNumLevels := sketch.numLevels
 	maxItem, err := sketch.GetMaxItem()
@@ -52,23 +78,26 @@ func newItemsSketchSortedView[C comparable](sketch *ItemsSketch[C]) (*ItemsSketc
 	if totalN == 0 {
 		return nil, errors.New("empty sketch")
 	}
+	numQuantiles := srcLevels[srcNumLevels] - srcLevels[0]
+	bufs.quantiles = resize(bufs.quantiles, int(numQuantiles))
+	copy(bufs.quantiles, sketch.items[srcLevels[0]:srcLevels[srcNumLevels]])
 	if !sketch.IsLevelZeroSorted() {
-		subSlice := srcQuantiles[srcLevels[0]:srcLevels[1]]
-		lessFn := sketch.itemsSketchOp.lessFn()
+		subSlice := bufs.quantiles[:srcLevels[1]-srcLevels[0]]
+		lessFn := sketch.itemsSketchOp.LessFn()
 		sort.Slice(subSlice, func(a, b int) bool {
 			return lessFn(subSlice[a], subSlice[b])
 		})
 	}
-	numQuantiles := srcLevels[srcNumLevels] - srcLevels[0]
 
-	quantiles, cumWeights := populateFromSketch(srcQuantiles, srcLevels, srcNumLevels, numQuantiles, sketch.itemsSketchOp)
+	populateFromSketch(bufs, srcLevels, srcNumLevels, numQuantiles, sketch.itemsSketchOp)
 	return &ItemsSketchSortedView[C]{
-		quantiles:     quantiles,
-		cumWeights:    cumWeights,
+		quantiles:     bufs.quantiles,
+		cumWeights:    bufs.cumWeights,
 		totalN:        totalN,
 		maxItem:       maxItem,
 		minItem:       minItem,
 		itemsSketchOp: sketch.itemsSketchOp,
+		minK:          sketch.minK,
 	}, nil
 }
 
@@ -81,7 +110,7 @@ func (s *ItemsSketchSortedView[C]) GetRank(item C, inclusive bool) (float64, err
 	if inclusive {
 		crit = internal.InequalityLE
 	}
-	index := internal.FindWithInequality(s.quantiles, 0, length-1, item, crit, s.itemsSketchOp.lessFn())
+	index := internal.FindWithInequality(s.quantiles, 0, length-1, item, crit, s.itemsSketchOp.LessFn())
 	if index == -1 {
 		return 0, nil //EXCLUSIVE (LT) case: quantile <= minQuantile; INCLUSIVE (LE) case: quantile < minQuantile
 	}
@@ -90,21 +119,42 @@ func (s *ItemsSketchSortedView[C]) GetRank(item C, inclusive bool) (float64, err
 
 func (s *ItemsSketchSortedView[C]) GetQuantile(rank float64, inclusive bool) (C, error) {
 	if s.totalN == 0 {
-		return s.itemsSketchOp.identity(), errors.New("empty sketch")
+		return s.itemsSketchOp.Identity(), errors.New("empty sketch")
 	}
 	err := checkNormalizedRankBounds(rank)
 	if err != nil {
-		return s.itemsSketchOp.identity(), err
+		return s.itemsSketchOp.Identity(), err
 	}
 	index := s.getQuantileIndex(rank, inclusive)
 	return s.quantiles[index], nil
 }
 
+// GetQuantilesWithBounds returns the quantiles of the ranks, along with their lower and upper bounds
+// at a 99% confidence, the quantiles of the lower and upper bounds of the ranks for the rank error of
+// the sketch, see ItemsSketch.GetQuantileLowerBound. All are searched with the same criterion.
+func (s *ItemsSketchSortedView[C]) GetQuantilesWithBounds(ranks []float64, inclusive bool) (quantiles, lowerBounds, upperBounds []C, err error) {
+	if s.totalN == 0 {
+		return nil, nil, nil, errors.New("empty sketch")
+	}
+	quantiles = make([]C, len(ranks))
+	lowerBounds = make([]C, len(ranks))
+	upperBounds = make([]C, len(ranks))
+	for i, rank := range ranks {
+		if err := checkNormalizedRankBounds(rank); err != nil {
+			return nil, nil, nil, err
+		}
+		quantiles[i] = s.quantiles[s.getQuantileIndex(rank, inclusive)]
+		lowerBounds[i] = s.quantiles[s.getQuantileIndex(getRankLowerBound(rank, s.minK), inclusive)]
+		upperBounds[i] = s.quantiles[s.getQuantileIndex(getRankUpperBound(rank, s.minK), inclusive)]
+	}
+	return quantiles, lowerBounds, upperBounds, nil
+}
+
 func (s *ItemsSketchSortedView[C]) GetPMF(splitPoints []C, inclusive bool) ([]float64, error) {
 	if s.totalN == 0 {
 		return nil, errors.New("empty sketch")
 	}
-	err := checkItems(splitPoints, s.itemsSketchOp.lessFn())
+	err := checkItems(splitPoints, s.itemsSketchOp.LessFn())
 	if err != nil {
 		return nil, err
 	}
@@ -123,7 +173,7 @@ func (s *ItemsSketchSortedView[C]) GetCDF(splitPoints []C, inclusive bool) ([]fl
 	if s.totalN == 0 {
 		return nil, errors.New("empty sketch")
 	}
-	err := checkItems(splitPoints, s.itemsSketchOp.lessFn())
+	err := checkItems(splitPoints, s.itemsSketchOp.LessFn())
 	if err != nil {
 		return nil, err
 	}
@@ -162,6 +212,11 @@ func (s *ItemsSketchSortedView[C]) GetPartitionBoundaries(numEquallySized int, i
 	if s.totalN == 0 {
 		return nil, errors.New("empty sketch")
 	}
+	// the partitions start at the min item and end at the max item, the view itself is not modified
+	patched := *s
+	patched.quantiles = slices.Clone(s.quantiles)
+	patched.cumWeights = slices.Clone(s.cumWeights)
+	s = &patched
 	s.cumWeights[0] = 1
 	s.cumWeights[len(s.cumWeights)-1] = int64(s.totalN)
 	s.quantiles[0] = s.minItem
@@ -181,14 +236,13 @@ func (s *ItemsSketchSortedView[C]) GetPartitionBoundaries(numEquallySized int, i
 	return newItemsSketchPartitionBoundaries[C](s.totalN, evSpQuantiles, evSpNatRanks, evSpNormRanks, s.maxItem, s.minItem, inclusive)
 }
 
-func populateFromSketch[C comparable](srcQuantiles []C, levels []uint32, numLevels uint8, numQuantiles uint32, itemsSketchOp ItemSketchOp[C]) ([]C, []int64) {
-	quantiles := make([]C, numQuantiles)
-	cumWeights := make([]int64, numQuantiles)
+// populateFromSketch sorts the retained items copied to the quantiles of bufs, with level 0 sorted,
+// and sets their cumulative weights.
+func populateFromSketch[C comparable](bufs *sortedViewBuffers[C], levels []uint32, numLevels uint8, numQuantiles uint32, itemsSketchOp ItemSketchOp[C]) {
+	bufs.cumWeights = resize(bufs.cumWeights, int(numQuantiles))
+	cumWeights := bufs.cumWeights
 	myLevels := make([]uint32, numLevels+1)
 	offset := levels[0]
-	for i := uint32(0); i < numQuantiles; i++ {
-		quantiles[i] = srcQuantiles[i+offset]
-	}
 	srcLevel := uint8(0)
 	dstLevel := uint8(0)
 	weight := int64(1)
@@ -207,23 +261,22 @@ func populateFromSketch[C comparable](srcQuantiles []C, levels []uint32, numLeve
 		weight *= 2
 	}
 	numLevels = dstLevel
-	blockyTandemMergeSort(quantiles, cumWeights, myLevels, numLevels, itemsSketchOp) //create unit weights
+	blockyTandemMergeSort(bufs, myLevels, numLevels, itemsSketchOp) //create unit weights
 	convertToCumulative(cumWeights)
-	return quantiles, cumWeights
 }
 
-func blockyTandemMergeSort[C comparable](quantiles []C, weights []int64, levels []uint32, numLevels uint8, itemsSketchOp ItemSketchOp[C]) {
+func blockyTandemMergeSort[C comparable](bufs *sortedViewBuffers[C], levels []uint32, numLevels uint8, itemsSketchOp ItemSketchOp[C]) {
 	if numLevels == 1 {
 		return
 	}
 
 	// duplicate the input in preparation for the "ping-pong" copy reduction strategy.
-	quantilesTmp := make([]C, len(quantiles))
-	copy(quantilesTmp, quantiles)
-	weightsTmp := make([]int64, len(weights))
-	copy(weightsTmp, weights) // don't need the extra one here
+	bufs.quantilesTmp = resize(bufs.quantilesTmp, len(bufs.quantiles))
+	copy(bufs.quantilesTmp, bufs.quantiles)
+	bufs.weightsTmp = resize(bufs.weightsTmp, len(bufs.cumWeights))
+	copy(bufs.weightsTmp, bufs.cumWeights) // don't need the extra one here
 
-	blockyTandemMergeSortRecursion(quantilesTmp, weightsTmp, quantiles, weights, levels, 0, numLevels, itemsSketchOp)
+	blockyTandemMergeSortRecursion(bufs.quantilesTmp, bufs.weightsTmp, bufs.quantiles, bufs.cumWeights, levels, 0, numLevels, itemsSketchOp)
 }
 
 func blockyTandemMergeSortRecursion[C comparable](quantilesSrc []C, weightsSrc []int64, quantilesDst []C, weightsDst []int64, levels []uint32, startingLevel uint8, numLevels uint8, itemsSketchOp ItemSketchOp[C]) {
@@ -249,7 +302,7 @@ func tandemMerge[C comparable](quantilesSrc []C, weightsSrc []int64, quantilesDs
 	iSrc2 := fromIndex2
 	iDst := fromIndex1
 
-	lessFn := itemsSketchOp.lessFn()
+	lessFn := itemsSketchOp.LessFn()
 	for iSrc1 < toIndex1 && iSrc2 < toIndex2 {
 		if lessFn(quantilesSrc[iSrc1], quantilesSrc[iSrc2]) || quantilesSrc[iSrc1] == quantilesSrc[iSrc2] {
 			quantilesDst[iDst] = quantilesSrc[iSrc1]
@@ -18,132 +18,37 @@
 package kll
 
 import (
-	"encoding/binary"
-	"errors"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
 	"fmt"
-	"github.com/apache/datasketches-go/common"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"math"
+	"math/rand"
+	"strings"
+	"sync"
 	"testing"
-	"unsafe"
 )
 
-type stringItemsSketchOp struct {
-}
-
-func (f stringItemsSketchOp) identity() string {
-	return ""
-}
-
-func (f stringItemsSketchOp) lessFn() common.LessFn[string] {
-	return func(a string, b string) bool {
-		return a < b
-	}
-}
-
-func (f stringItemsSketchOp) sizeOf(item string) int {
-	if len(item) == 0 {
-		return int(unsafe.Sizeof(uint32(0)))
-	}
-	return len(item) + int(unsafe.Sizeof(uint32(0)))
-}
-
-func (f stringItemsSketchOp) sizeOfMany(mem []byte, offsetBytes int, numItems int) (int, error) {
-	if numItems <= 0 {
-		return 0, nil
-	}
-	reqLen := 4
-	offset := offsetBytes
-	memCap := len(mem)
-	for i := 0; i < numItems; i++ {
-		if !checkBounds(offset, reqLen, memCap) {
-			return 0, errors.New("offset out of bounds")
-		}
-		itemLenBytes := int(binary.LittleEndian.Uint32(mem[offset:]))
-		offset += 4
-		if offset+itemLenBytes > memCap {
-			return 0, errors.New("offset out of bounds")
-		}
-		offset += itemLenBytes
-	}
-	return offset - offsetBytes, nil
-}
-
-func (h stringItemsSketchOp) SerializeOneToSlice(item string) []byte {
-	if len(item) == 0 {
-		return []byte{}
-	}
-	utf8len := len(item)
-	bytesOut := make([]byte, utf8len+4)
-	binary.LittleEndian.PutUint32(bytesOut, uint32(utf8len))
-	copy(bytesOut[4:], []byte(item))
-	return bytesOut
-}
-
-func (h stringItemsSketchOp) SerializeManyToSlice(item []string) []byte {
-	if len(item) == 0 {
-		return []byte{}
-	}
-	totalBytes := 0
-	numItems := len(item)
-	serialized2DArray := make([][]byte, numItems)
-	for i := 0; i < numItems; i++ {
-		serialized2DArray[i] = []byte(item[i])
-		totalBytes += len(serialized2DArray[i]) + 4
-	}
-	bytesOut := make([]byte, totalBytes)
-	offset := 0
-	for i := 0; i < numItems; i++ {
-		utf8len := len(serialized2DArray[i])
-		binary.LittleEndian.PutUint32(bytesOut[offset:], uint32(utf8len))
-		offset += 4
-		copy(bytesOut[offset:], serialized2DArray[i])
-		offset += utf8len
-	}
-	return bytesOut
-}
-
-func (h stringItemsSketchOp) DeserializeFromSlice(mem []byte, offsetBytes int, numItems int) ([]string, error) {
-	if numItems <= 0 {
-		return []string{}, nil
-	}
-	array := make([]string, numItems)
-	offset := offsetBytes
-	intSize := int(unsafe.Sizeof(uint32(0)))
-	memCap := len(mem)
-	for i := 0; i < numItems; i++ {
-		if !checkBounds(offset, intSize, memCap) {
-			return nil, errors.New("offset out of bounds")
-		}
-		strLength := int(binary.LittleEndian.Uint32(mem[offset:]))
-		offset += intSize
-		utf8Bytes := make([]byte, strLength)
-		if !checkBounds(offset, strLength, memCap) {
-			return nil, errors.New("offset out of bounds")
-		}
-		copy(utf8Bytes, mem[offset:offset+strLength])
-		offset += strLength
-		array[i] = string(utf8Bytes)
-	}
-	return array, nil
-}
-
 const (
+	PMF_EPS_FOR_K_8         = 0.35  // PMF rank error (epsilon) for k=8
 	PMF_EPS_FOR_K_256       = 0.013 // PMF rank error (epsilon) for k=256
 	NUMERIC_NOISE_TOLERANCE = 1e-6
 )
 
 func TestItemsSketch_KLimits(t *testing.T) {
-	_, err := NewItemsSketch[string](uint16(_MIN_K), stringItemsSketchOp{})
+	_, err := NewItemsSketch[string](uint16(_MIN_K), StringItemsSketchOp{})
 	assert.NoError(t, err)
-	_, err = NewItemsSketch[string](uint16(_MAX_K), stringItemsSketchOp{})
+	_, err = NewItemsSketch[string](uint16(_MAX_K), StringItemsSketchOp{})
 	assert.NoError(t, err)
-	_, err = NewItemsSketch[string](uint16(_MIN_K-1), stringItemsSketchOp{})
+	_, err = NewItemsSketch[string](uint16(_MIN_K-1), StringItemsSketchOp{})
 	assert.Error(t, err)
 }
 
 func TestItemsSketch_Empty(t *testing.T) {
-	sketch, err := NewItemsSketch[string](200, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](200, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	assert.True(t, sketch.IsEmpty())
 	assert.False(t, sketch.IsEstimationMode())
@@ -165,7 +70,7 @@ func TestItemsSketch_Empty(t *testing.T) {
 }
 
 func TestItemsSketch_BadQuantile(t *testing.T) {
-	sketch, err := NewItemsSketch[string](200, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](200, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	sketch.Update("") // has to be non-empty to reach the check
 	_, err = sketch.GetQuantile(-1, true)
@@ -173,7 +78,7 @@ func TestItemsSketch_BadQuantile(t *testing.T) {
 }
 
 func TestItemsSketch_OneValue(t *testing.T) {
-	sketch, err := NewItemsSketch[string](200, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](200, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	sketch.Update("A")
 	assert.False(t, sketch.IsEmpty())
@@ -203,7 +108,7 @@ func TestItemsSketch_OneValue(t *testing.T) {
 
 func TestItemsSketch_TenValues(t *testing.T) {
 	tenStr := []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
-	sketch, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	strLen := len(tenStr)
 	dblStrLen := float64(strLen)
@@ -278,7 +183,7 @@ func TestItemsSketch_TenValues(t *testing.T) {
 }
 
 func TestItemsSketch_ManyValuesEstimationMode(T *testing.T) {
-	sketch, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(T, err)
 	n := 1_000_000
 	digits := numDigits(n)
@@ -338,7 +243,7 @@ func TestItemsSketch_ManyValuesEstimationMode(T *testing.T) {
 }
 
 func TestItemsSketch_GetRankGetCdfGetPmfConsistency(t *testing.T) {
-	sketch, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 1000
 	digits := numDigits(n)
@@ -385,9 +290,9 @@ func TestItemsSketch_GetRankGetCdfGetPmfConsistency(t *testing.T) {
 }
 
 func TestItemsSketch_Merge(t *testing.T) {
-	sketch1, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch1, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
-	sketch2, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch2, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 10000
 	digits := numDigits(2 * n)
@@ -410,7 +315,7 @@ func TestItemsSketch_Merge(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, intToFixedLengthString(2*n-1, digits), maxV)
 
-	sketch1.Merge(sketch2)
+	assert.NoError(t, sketch1.Merge(sketch2))
 	assert.False(t, sketch1.IsEmpty())
 	assert.Equal(t, uint64(2*n), sketch1.GetN())
 	minV, err = sketch1.GetMinItem()
@@ -425,12 +330,88 @@ func TestItemsSketch_Merge(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, median < upperBound)
 	assert.True(t, lowerBound < median)
+
+	// the levels and items of the sketch are read while they are merged into it
+	assert.NoError(t, sketch1.Merge(sketch1))
+	assert.Equal(t, uint64(4*n), sketch1.GetN())
+	median, err = sketch1.GetQuantile(0.5, false)
+	assert.NoError(t, err)
+	assert.True(t, median < upperBound)
+	assert.True(t, lowerBound < median)
+}
+
+func TestItemsSketch_UpdateWeighted(t *testing.T) {
+	sketch, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	assert.Error(t, sketch.UpdateWeighted("a", 0))
+	assert.Error(t, sketch.UpdateWeighted("a", 1<<61))
+	assert.True(t, sketch.IsEmpty())
+
+	// fits in level 0
+	assert.NoError(t, sketch.UpdateWeighted("b", 3))
+	assert.Equal(t, uint64(3), sketch.GetN())
+	assert.Equal(t, uint32(3), sketch.GetNumRetained())
+	// merged from a sketch of several levels
+	assert.NoError(t, sketch.UpdateWeighted("a", 1000))
+	assert.NoError(t, sketch.UpdateWeighted("c", 1<<40+5))
+	assert.Equal(t, uint64(3+1000+1<<40+5), sketch.GetN())
+	minV, err := sketch.GetMinItem()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", minV)
+	maxV, err := sketch.GetMaxItem()
+	assert.NoError(t, err)
+	assert.Equal(t, "c", maxV)
+
+	weight := int64(0)
+	it := sketch.GetIterator()
+	for it.Next() {
+		weight += it.GetWeight()
+	}
+	assert.Equal(t, int64(sketch.GetN()), weight)
+	view, err := sketch.GetSortedView()
+	assert.NoError(t, err)
+	viewIt := view.Iterator()
+	natRank := int64(0)
+	for viewIt.Next() {
+		natRank = viewIt.GetNaturalRank(true)
+	}
+	assert.Equal(t, int64(sketch.GetN()), natRank)
+	rank, err := sketch.GetRank("b", true)
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(1003)/float64(sketch.GetN()), rank, sketch.GetNormalizedRankError(false))
+}
+
+func TestItemsSketch_UpdateWeightedHistogram(t *testing.T) {
+	weighted, err := NewItemsSketch[int64](_DEFAULT_K, Int64ItemsSketchOp{}, WithRandSeed(1))
+	assert.NoError(t, err)
+	replayed, err := NewItemsSketch[int64](_DEFAULT_K, Int64ItemsSketchOp{}, WithRandSeed(1))
+	assert.NoError(t, err)
+	numBuckets := 200
+	cumWeights := make([]uint64, numBuckets)
+	total := uint64(0)
+	for i := 0; i < numBuckets; i++ {
+		weight := uint64(1 + (i*7919)%500)
+		assert.NoError(t, weighted.UpdateWeighted(int64(i), weight))
+		for j := uint64(0); j < weight; j++ {
+			replayed.Update(int64(i))
+		}
+		total += weight
+		cumWeights[i] = total
+	}
+	assert.Equal(t, total, weighted.GetN())
+	assert.Equal(t, replayed.GetN(), weighted.GetN())
+	eps := weighted.GetNormalizedRankError(false)
+	for i := 0; i < numBuckets; i++ {
+		rank, err := weighted.GetRank(int64(i), true)
+		assert.NoError(t, err)
+		assert.InDelta(t, float64(cumWeights[i])/float64(total), rank, eps)
+	}
 }
 
 func TestItemsSketch_MergeLowerK(t *testing.T) {
-	sketch1, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch1, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
-	sketch2, err := NewItemsSketch[string](_DEFAULT_K/2, stringItemsSketchOp{})
+	sketch2, err := NewItemsSketch[string](_DEFAULT_K/2, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 10000
 	digits := numDigits(2 * n)
@@ -453,7 +434,7 @@ func TestItemsSketch_MergeLowerK(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, intToFixedLengthString(2*n-1, digits), maxV)
 
-	sketch1.Merge(sketch2)
+	assert.NoError(t, sketch1.Merge(sketch2))
 
 	//sketch1 must get "contaminated" by the lower K in sketch2
 	assert.Equal(t, sketch1.GetNormalizedRankError(false), sketch2.GetNormalizedRankError(false))
@@ -476,9 +457,9 @@ func TestItemsSketch_MergeLowerK(t *testing.T) {
 }
 
 func TestItemsSketch_MergeEmptyLowerK(t *testing.T) {
-	sketch1, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch1, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
-	sketch2, err := NewItemsSketch[string](_DEFAULT_K/2, stringItemsSketchOp{})
+	sketch2, err := NewItemsSketch[string](_DEFAULT_K/2, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 10000
 	digits := numDigits(n)
@@ -488,7 +469,7 @@ func TestItemsSketch_MergeEmptyLowerK(t *testing.T) {
 
 	// rank error should not be affected by a merge with an empty sketch with lower K
 	rankErrorBeforeMerge := sketch1.GetNormalizedRankError(true)
-	sketch1.Merge(sketch2)
+	assert.NoError(t, sketch1.Merge(sketch2))
 	assert.Equal(t, sketch1.GetNormalizedRankError(true), rankErrorBeforeMerge)
 
 	{
@@ -510,7 +491,7 @@ func TestItemsSketch_MergeEmptyLowerK(t *testing.T) {
 	}
 	{
 		//merge the other way
-		sketch2.Merge(sketch1)
+		assert.NoError(t, sketch2.Merge(sketch1))
 		assert.False(t, sketch1.IsEmpty())
 		assert.False(t, sketch2.IsEmpty())
 		assert.Equal(t, uint64(n), sketch1.GetN())
@@ -537,9 +518,9 @@ func TestItemsSketch_MergeEmptyLowerK(t *testing.T) {
 }
 
 func TestItemsSketch_MergeExactModeLowerK(t *testing.T) {
-	sketch1, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch1, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
-	sketch2, err := NewItemsSketch[string](_DEFAULT_K/2, stringItemsSketchOp{})
+	sketch2, err := NewItemsSketch[string](_DEFAULT_K/2, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 10000
 	digits := numDigits(n)
@@ -550,34 +531,34 @@ func TestItemsSketch_MergeExactModeLowerK(t *testing.T) {
 
 	// rank error should not be affected by a merge with a sketch in exact mode with lower K
 	rankErrorBeforeMerge := sketch1.GetNormalizedRankError(true)
-	sketch1.Merge(sketch2)
+	assert.NoError(t, sketch1.Merge(sketch2))
 	assert.Equal(t, sketch1.GetNormalizedRankError(true), rankErrorBeforeMerge)
 }
 
 func TestItemsSketch_MergeMinMinValueFromOther(t *testing.T) {
-	sketch1, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch1, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
-	sketch2, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch2, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	sketch1.Update(intToFixedLengthString(1, 1))
 	sketch2.Update(intToFixedLengthString(2, 1))
-	sketch2.Merge(sketch1)
+	assert.NoError(t, sketch2.Merge(sketch1))
 	minV, err := sketch2.GetMinItem()
 	assert.NoError(t, err)
 	assert.Equal(t, intToFixedLengthString(1, 1), minV)
 }
 
 func TestItemsSketch_MergeMinAndMaxFromOther(t *testing.T) {
-	sketch1, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch1, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
-	sketch2, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch2, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 1_000_000
 	digits := numDigits(n)
 	for i := 1; i <= 1_000_000; i++ {
 		sketch1.Update(intToFixedLengthString(i, digits)) //sketch2 is empty
 	}
-	sketch2.Merge(sketch1)
+	assert.NoError(t, sketch2.Merge(sketch1))
 	minV, err := sketch2.GetMinItem()
 	assert.NoError(t, err)
 	assert.Equal(t, intToFixedLengthString(1, digits), minV)
@@ -587,18 +568,18 @@ func TestItemsSketch_MergeMinAndMaxFromOther(t *testing.T) {
 }
 
 func TestItemsSketch_KTooSmall(t *testing.T) {
-	_, err := NewItemsSketch[string](_MIN_K-1, stringItemsSketchOp{})
+	_, err := NewItemsSketch[string](_MIN_K-1, StringItemsSketchOp{})
 	assert.Error(t, err)
 }
 
 // cannot use _MAX_K + 1 (untyped int constant 65536) as uint16 value in argument to NewItemsSketch[string] (overflows)
 //func TestItemsSketch_KTooLarge(t *testing.T) {
-//	_, err := NewItemsSketch[string](_MAX_K+1, stringItemsSketchOp{})
+//	_, err := NewItemsSketch[string](_MAX_K+1, StringItemsSketchOp{})
 //	assert.Error(t, err)
 //}
 
 func TestItemsSketch_MinK(t *testing.T) {
-	sketch, err := NewItemsSketch[string](uint16(_DEFAULT_M), stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](uint16(_DEFAULT_M), StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 1000
 	digits := numDigits(n)
@@ -606,8 +587,8 @@ func TestItemsSketch_MinK(t *testing.T) {
 		sketch.Update(intToFixedLengthString(i, digits))
 	}
 	assert.Equal(t, sketch.GetK(), uint16(_DEFAULT_M))
-	upperBound := intToFixedLengthString(n/2+(int)(math.Ceil(float64(n)*PMF_EPS_FOR_K_256)), digits)
-	lowerBound := intToFixedLengthString(n/2-(int)(math.Ceil(float64(n)*PMF_EPS_FOR_K_256)), digits)
+	upperBound := intToFixedLengthString(n/2+(int)(math.Ceil(float64(n)*PMF_EPS_FOR_K_8)), digits)
+	lowerBound := intToFixedLengthString(n/2-(int)(math.Ceil(float64(n)*PMF_EPS_FOR_K_8)), digits)
 	median, err := sketch.GetQuantile(0.5, true)
 	assert.NoError(t, err)
 	assert.True(t, median < upperBound)
@@ -615,7 +596,7 @@ func TestItemsSketch_MinK(t *testing.T) {
 }
 
 func TestItemsSketch_MaxK(t *testing.T) {
-	sketch, err := NewItemsSketch[string](uint16(_MAX_K), stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](uint16(_MAX_K), StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 1000
 	digits := numDigits(n)
@@ -632,7 +613,7 @@ func TestItemsSketch_MaxK(t *testing.T) {
 }
 
 func TestItemsSketch_OutOfOrderSplitPoints(t *testing.T) {
-	sketch, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	s0 := intToFixedLengthString(0, 1)
 	s1 := intToFixedLengthString(1, 1)
@@ -642,7 +623,7 @@ func TestItemsSketch_OutOfOrderSplitPoints(t *testing.T) {
 }
 
 func TestItemsSketch_DuplicateSplitPoints(t *testing.T) {
-	sketch, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	sketch.Update("A")
 	sketch.Update("B")
@@ -657,7 +638,7 @@ func TestItemsSketch_DuplicateSplitPoints(t *testing.T) {
 }
 
 func TestItemsSketch_CheckReset(t *testing.T) {
-	sketch, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 100
 	digits := numDigits(n)
@@ -684,7 +665,7 @@ func TestItemsSketch_CheckReset(t *testing.T) {
 }
 
 func TestItemsSketch_SortedView(t *testing.T) {
-	sketch, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	sketch.Update("A")
 	sketch.Update("AB")
@@ -717,7 +698,7 @@ func TestItemsSketch_CDF_PDF(t *testing.T) {
 	pmfI := []float64{.25, .25, .25, .25, 0.0}
 	pmfE := []float64{0.0, .25, .25, .25, .25}
 	toll := 1e-10
-	sketch, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sketch, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	strIn := []string{"A", "AB", "ABC", "ABCD"}
 	for i := 0; i < len(strIn); i++ {
@@ -754,17 +735,17 @@ func TestItemsSketch_CDF_PDF(t *testing.T) {
 }
 
 func TestItemsSketch_DeserializeEmpty(t *testing.T) {
-	sk1, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sk1, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	mem, err := sk1.ToSlice()
 	assert.NoError(t, err)
 	assert.NotNil(t, mem)
-	memVal, err := newItemsSketchMemoryValidate[string](mem, stringItemsSketchOp{})
+	memVal, err := newItemsSketchMemoryValidate[string](mem, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	assert.Equal(t, memVal.sketchStructure, _COMPACT_EMPTY)
 	assert.Equal(t, len(mem), 8)
 
-	sk2, err := NewItemsSketchFromSlice[string](mem, stringItemsSketchOp{})
+	sk2, err := NewItemsSketchFromSlice[string](mem, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	assert.Equal(t, sk2.GetN(), uint64(0))
 	_, err = sk2.GetMinItem()
@@ -774,16 +755,16 @@ func TestItemsSketch_DeserializeEmpty(t *testing.T) {
 }
 
 func TestItemsSketch_DeserializeSingleItem(t *testing.T) {
-	sk1, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sk1, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	sk1.Update("A")
 	mem, err := sk1.ToSlice()
 	assert.NoError(t, err)
 	assert.NotNil(t, mem)
-	memVal, err := newItemsSketchMemoryValidate[string](mem, stringItemsSketchOp{})
+	memVal, err := newItemsSketchMemoryValidate[string](mem, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	assert.Equal(t, memVal.sketchStructure, _COMPACT_SINGLE)
-	sk2, err := NewItemsSketchFromSlice[string](mem, stringItemsSketchOp{})
+	sk2, err := NewItemsSketchFromSlice[string](mem, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	assert.Equal(t, sk2.GetN(), uint64(1))
 	minV, err := sk2.GetMinItem()
@@ -795,7 +776,7 @@ func TestItemsSketch_DeserializeSingleItem(t *testing.T) {
 }
 
 func TestItemsSketch_FewItems(t *testing.T) {
-	sk1, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sk1, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	sk1.Update("A")
 	sk1.Update("AB")
@@ -803,14 +784,14 @@ func TestItemsSketch_FewItems(t *testing.T) {
 	mem, err := sk1.ToSlice()
 	assert.NoError(t, err)
 	assert.NotNil(t, mem)
-	memVal, err := newItemsSketchMemoryValidate[string](mem, stringItemsSketchOp{})
+	memVal, err := newItemsSketchMemoryValidate[string](mem, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	assert.Equal(t, memVal.sketchStructure, _COMPACT_FULL)
 	assert.Equal(t, len(mem), memVal.sketchBytes)
 }
 
 func TestItemsSketch_ManyItems(t *testing.T) {
-	sk1, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sk1, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 109
 	digits := numDigits(n)
@@ -820,14 +801,14 @@ func TestItemsSketch_ManyItems(t *testing.T) {
 	mem, err := sk1.ToSlice()
 	assert.NoError(t, err)
 	assert.NotNil(t, mem)
-	memVal, err := newItemsSketchMemoryValidate[string](mem, stringItemsSketchOp{})
+	memVal, err := newItemsSketchMemoryValidate[string](mem, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	assert.Equal(t, memVal.sketchStructure, _COMPACT_FULL)
 	assert.Equal(t, len(mem), memVal.sketchBytes)
 }
 
 func TestItemsSketch_SortedViewAfterReset(t *testing.T) {
-	sk, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sk, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	sk.Update("1")
 	sv, err := sk.GetSortedView()
@@ -840,13 +821,118 @@ func TestItemsSketch_SortedViewAfterReset(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestItemsSketch_SortedViewImmutable(t *testing.T) {
+	sketch, err := NewItemsSketch[int64](_DEFAULT_K, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	for i := int64(0); i < 10000; i++ {
+		sketch.Update(i)
+	}
+	view, err := sketch.GetSortedView()
+	assert.NoError(t, err)
+	quantiles := make([]int64, 0)
+	for it := view.Iterator(); it.Next(); {
+		quantiles = append(quantiles, it.GetQuantile())
+	}
+	median, err := view.GetQuantile(0.5, true)
+	assert.NoError(t, err)
+
+	// the view is queried while the sketch is updated and queried
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				q, err := view.GetQuantile(0.5, true)
+				assert.NoError(t, err)
+				assert.Equal(t, median, q)
+				_, err = view.GetPartitionBoundaries(10, true)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	for i := int64(0); i < 10000; i++ {
+		sketch.Update(-i)
+		_, err := sketch.GetRank(0, true)
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+
+	// the view is not modified by the updates nor by GetPartitionBoundaries
+	q, err := view.GetQuantile(0.5, true)
+	assert.NoError(t, err)
+	assert.Equal(t, median, q)
+	i := 0
+	for it := view.Iterator(); it.Next(); i++ {
+		assert.Equal(t, quantiles[i], it.GetQuantile())
+	}
+	assert.Equal(t, len(quantiles), i)
+	q, err = view.GetQuantile(0, true)
+	assert.NoError(t, err)
+	assert.Equal(t, quantiles[0], q)
+}
+
+func TestItemsSketch_SortedViewBuffersReuse(t *testing.T) {
+	sketch, err := NewItemsSketch[int64](_DEFAULT_K, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	for i := int64(0); i < 10000; i++ {
+		sketch.Update(i)
+	}
+	_, err = sketch.GetRank(0, true)
+	assert.NoError(t, err)
+	quantiles := sketch.sortedViewBufs.quantiles
+	// the view is rebuilt in the same buffers as long as they are large enough
+	for i := 0; i < 1000; i++ {
+		sketch.Update(int64(i))
+		_, err = sketch.GetRank(0, true)
+		assert.NoError(t, err)
+		if sketch.GetNumRetained() <= uint32(cap(quantiles)) {
+			assert.Same(t, &quantiles[:1][0], &sketch.sortedView.quantiles[0])
+		} else {
+			quantiles = sketch.sortedViewBufs.quantiles
+		}
+	}
+
+	// a view handed out by GetSortedView is not reused
+	view, err := sketch.GetSortedView()
+	assert.NoError(t, err)
+	sketch.Update(0)
+	_, err = sketch.GetRank(0, true)
+	assert.NoError(t, err)
+	assert.NotSame(t, &view.quantiles[0], &sketch.sortedView.quantiles[0])
+}
+
+func TestItemsSketch_SortedViewBuffersAllocs(t *testing.T) {
+	sketch, err := NewItemsSketch[int64](_DEFAULT_K, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	for i := int64(0); i < 100000; i++ {
+		sketch.Update(i)
+	}
+	_, err = sketch.GetQuantile(0.5, true)
+	assert.NoError(t, err)
+	item := int64(0)
+	queryAllocs := testing.AllocsPerRun(1000, func() {
+		item++
+		sketch.Update(item)
+		_, _ = sketch.GetQuantile(0.5, true)
+	})
+	viewAllocs := testing.AllocsPerRun(1000, func() {
+		item++
+		sketch.Update(item)
+		_, _ = sketch.GetSortedView()
+	})
+	// the quantiles and the cumulative weights are only allocated for a view handed out
+	assert.GreaterOrEqual(t, viewAllocs, queryAllocs+2)
+	assert.Zero(t, testing.AllocsPerRun(100, func() { _, _ = sketch.GetSortedView() }))
+}
+
 func TestItemsSketch_SerializeDeserializeEmpty(t *testing.T) {
-	sk1, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sk1, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	mem, err := sk1.ToSlice()
 	assert.NoError(t, err)
 	assert.NotNil(t, mem)
-	sk2, err := NewItemsSketchFromSlice[string](mem, stringItemsSketchOp{})
+	sk2, err := NewItemsSketchFromSlice[string](mem, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	s, err := sk1.GetSerializedSizeBytes()
 	assert.NoError(t, err)
@@ -870,13 +956,13 @@ func TestItemsSketch_SerializeDeserializeEmpty(t *testing.T) {
 }
 
 func TestItemsSketch_SerializeDeserializeOneValue(t *testing.T) {
-	sk1, err := NewItemsSketch[string](20, stringItemsSketchOp{})
+	sk1, err := NewItemsSketch[string](20, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	sk1.Update(" 1")
 	mem, err := sk1.ToSlice()
 	assert.NoError(t, err)
 	assert.NotNil(t, mem)
-	sk2, err := NewItemsSketchFromSlice[string](mem, stringItemsSketchOp{})
+	sk2, err := NewItemsSketchFromSlice[string](mem, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	s1SizeBytes, err := sk1.GetSerializedSizeBytes()
 	assert.Equal(t, len(mem), s1SizeBytes)
@@ -901,7 +987,7 @@ func TestItemsSketch_SerializeDeserializeOneValue(t *testing.T) {
 }
 
 func TestItemsSketch_SerializeDeserializeMultipleValue(t *testing.T) {
-	sk1, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+	sk1, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	n := 1000
 	for i := 0; i < n; i++ {
@@ -916,7 +1002,7 @@ func TestItemsSketch_SerializeDeserializeMultipleValue(t *testing.T) {
 	mem, err := sk1.ToSlice()
 	assert.NoError(t, err)
 	assert.NotNil(t, mem)
-	sk2, err := NewItemsSketchFromSlice[string](mem, stringItemsSketchOp{})
+	sk2, err := NewItemsSketchFromSlice[string](mem, StringItemsSketchOp{})
 	assert.NoError(t, err)
 	s1, err := sk2.GetSerializedSizeBytes()
 	assert.NoError(t, err)
@@ -942,7 +1028,7 @@ func TestSerializeDeserialize(t *testing.T) {
 	nArr := []int{0, 1, 10, 100, 1000, 10000, 100000, 1000000}
 	for _, n := range nArr {
 		digits := numDigits(n)
-		sk, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+		sk, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 		assert.NoError(t, err)
 		for i := 1; i <= n; i++ {
 			sk.Update(intToFixedLengthString(i, digits))
@@ -950,7 +1036,7 @@ func TestSerializeDeserialize(t *testing.T) {
 		slc, err := sk.ToSlice()
 		assert.NoError(t, err)
 
-		sketch, err := NewItemsSketchFromSlice[string](slc, stringItemsSketchOp{})
+		sketch, err := NewItemsSketchFromSlice[string](slc, StringItemsSketchOp{})
 		if err != nil {
 			return
 		}
@@ -979,7 +1065,7 @@ func TestSerializeDeserialize(t *testing.T) {
 
 			weight := int64(0)
 			it := sketch.GetIterator()
-			lessFn := stringItemsSketchOp{}.lessFn()
+			lessFn := StringItemsSketchOp{}.LessFn()
 			for it.Next() {
 				qut := it.GetQuantile()
 				assert.True(t, lessFn(minV, qut) || minV == qut, fmt.Sprintf("min: \"%v\" \"%v\"", minV, qut))
@@ -990,3 +1076,337 @@ func TestSerializeDeserialize(t *testing.T) {
 		}
 	}
 }
+
+func TestItemsSketch_MarshalBinary(t *testing.T) {
+	for _, n := range []int{0, 1, 1000} {
+		sketch, err := NewItemsSketch[string](200, StringItemsSketchOp{})
+		assert.NoError(t, err)
+		for i := 0; i < n; i++ {
+			sketch.Update(intToFixedLengthString(i, 4))
+		}
+		data, err := sketch.MarshalBinary()
+		assert.NoError(t, err)
+		slc, err := sketch.ToSlice()
+		assert.NoError(t, err)
+		assert.Equal(t, slc, data)
+
+		var buf bytes.Buffer
+		assert.NoError(t, gob.NewEncoder(&buf).Encode(sketch))
+		decoded, err := NewItemsSketch[string](8, StringItemsSketchOp{})
+		assert.NoError(t, err)
+		assert.NoError(t, gob.NewDecoder(&buf).Decode(decoded))
+		assert.Equal(t, sketch.GetK(), decoded.GetK())
+		assert.Equal(t, sketch.GetN(), decoded.GetN())
+		if n > 0 {
+			q1, err := sketch.GetQuantile(0.5, true)
+			assert.NoError(t, err)
+			q2, err := decoded.GetQuantile(0.5, true)
+			assert.NoError(t, err)
+			assert.Equal(t, q1, q2)
+		}
+	}
+
+	var noOp ItemsSketch[string]
+	assert.Error(t, noOp.UnmarshalBinary([]byte{}))
+}
+
+func TestItemsSketch_WriteToReadFrom(t *testing.T) {
+	var images [][]byte
+	var buf bytes.Buffer
+	// the images are written one after another into a single compressed stream
+	zw := gzip.NewWriter(&buf)
+	for _, n := range []int{0, 1, 1000, 100000} {
+		sketch, err := NewItemsSketch[string](200, StringItemsSketchOp{})
+		assert.NoError(t, err)
+		for i := 0; i < n; i++ {
+			sketch.Update(intToFixedLengthString(i, 6))
+		}
+		slc, err := sketch.ToSlice()
+		assert.NoError(t, err)
+		written, err := sketch.WriteTo(zw)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(slc)), written)
+		images = append(images, slc)
+
+		updatable, err := sketch.ToUpdatableSlice()
+		assert.NoError(t, err)
+		_, err = zw.Write(updatable)
+		assert.NoError(t, err)
+		images = append(images, updatable)
+	}
+	assert.NoError(t, zw.Close())
+
+	zr, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+	for i, slc := range images {
+		decoded, err := NewItemsSketch[string](8, StringItemsSketchOp{})
+		assert.NoError(t, err)
+		read, err := decoded.ReadFrom(zr)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(slc)), read)
+		decodedSlc, err := decoded.ToSlice()
+		assert.NoError(t, err)
+		// every other image is updatable, it decodes into the sketch of the previous compact image
+		assert.Equal(t, images[i-i%2], decodedSlc)
+	}
+	decoded, err := NewItemsSketch[string](8, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	read, err := decoded.ReadFrom(zr)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, int64(0), read)
+
+	for _, slc := range images[2:] {
+		for _, size := range []int{4, len(slc) - 1} {
+			_, err = decoded.ReadFrom(bytes.NewReader(slc[:size]))
+			assert.Equal(t, io.ErrUnexpectedEOF, err)
+		}
+	}
+
+	var noOp ItemsSketch[string]
+	_, err = noOp.ReadFrom(bytes.NewReader(images[0]))
+	assert.Error(t, err)
+}
+
+// sizedStringOp hides the ItemReader of StringItemsSketchOp, its items are sized with SizeOfMany.
+type sizedStringOp struct {
+	ItemSketchOp[string]
+}
+
+func TestItemsSketch_ReadFromSizedItems(t *testing.T) {
+	sketch, err := NewItemsSketch[string](200, sizedStringOp{StringItemsSketchOp{}})
+	assert.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		sketch.Update(intToFixedLengthString(i, 1+i%100))
+	}
+	slc, err := sketch.ToSlice()
+	assert.NoError(t, err)
+	tail := []byte("next image")
+
+	// the items are sized on the bytes buffered by a bufio.Reader, which are consumed up to the image
+	br := bufio.NewReaderSize(io.MultiReader(bytes.NewReader(slc), bytes.NewReader(tail)), 128)
+	decoded, err := NewItemsSketch[string](8, sizedStringOp{StringItemsSketchOp{}})
+	assert.NoError(t, err)
+	read, err := decoded.ReadFrom(br)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(slc)), read)
+	decodedSlc, err := decoded.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, slc, decodedSlc)
+	rest, err := io.ReadAll(br)
+	assert.NoError(t, err)
+	assert.Equal(t, tail, rest)
+
+	_, err = decoded.ReadFrom(bufio.NewReaderSize(bytes.NewReader(slc[:len(slc)-1]), 128))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	// an item larger than the buffer of the reader
+	large, err := NewItemsSketch[string](200, sizedStringOp{StringItemsSketchOp{}})
+	assert.NoError(t, err)
+	large.Update(intToFixedLengthString(1, 100))
+	slc, err = large.ToSlice()
+	assert.NoError(t, err)
+	_, err = decoded.ReadFrom(bufio.NewReaderSize(bytes.NewReader(slc), 16))
+	assert.Equal(t, bufio.ErrBufferFull, err)
+	// without ItemReader the reader must be buffered
+	_, err = decoded.ReadFrom(bytes.NewReader(slc))
+	assert.Error(t, err)
+}
+
+func TestStringItemsSketchOp_ReadItem(t *testing.T) {
+	op := StringItemsSketchOp{}
+	item := strings.Repeat("x", 10000)
+	slc := op.SerializeOneToSlice(item)
+	read, err := op.ReadItem(bytes.NewReader(slc))
+	assert.NoError(t, err)
+	assert.Equal(t, item, read)
+
+	_, err = op.ReadItem(bytes.NewReader(nil))
+	assert.Equal(t, io.EOF, err)
+	_, err = op.ReadItem(bytes.NewReader(slc[:2]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = op.ReadItem(bytes.NewReader(slc[:4]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = op.ReadItem(bytes.NewReader(slc[:len(slc)-1]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	// a corrupted length
+	_, err = op.ReadItem(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 'a'}))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+type failingWriter struct {
+	remaining int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.remaining {
+		n := w.remaining
+		w.remaining = 0
+		return n, io.ErrShortWrite
+	}
+	w.remaining -= len(p)
+	return len(p), nil
+}
+
+func TestItemsSketch_WriteToError(t *testing.T) {
+	sketch, err := NewItemsSketch[string](200, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		sketch.Update(intToFixedLengthString(i, 6))
+	}
+	written, err := sketch.WriteTo(&failingWriter{remaining: 100})
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+	assert.Equal(t, int64(100), written)
+}
+
+func TestItemsSketch_RandomCompactionRankError(t *testing.T) {
+	const (
+		n      = 100000
+		trials = 20
+		points = 100
+	)
+	sumErr := 0.0
+	for trial := 0; trial < trials; trial++ {
+		sketch, err := NewItemsSketch[int64](200, Int64ItemsSketchOp{}, WithRandSeed(int64(trial)))
+		assert.NoError(t, err)
+		// sorted input is the adversarial case of a fixed compaction offset
+		for i := int64(0); i < n; i++ {
+			sketch.Update(i)
+		}
+		eps := sketch.GetNormalizedRankError(false)
+		for p := 1; p < points; p++ {
+			item := int64(p * n / points)
+			rank, err := sketch.GetRank(item, false)
+			assert.NoError(t, err)
+			assert.InDelta(t, float64(item)/n, rank, eps)
+			sumErr += rank - float64(item)/n
+		}
+	}
+	// the coin flips keep the rank estimates unbiased
+	assert.InDelta(t, 0, sumErr/(trials*(points-1)), getNormalizedRankError(200, false)/10)
+}
+
+func TestItemsSketch_RandSeed(t *testing.T) {
+	image := func(opts ...SketchOption) []byte {
+		sketch, err := NewItemsSketch[int64](200, Int64ItemsSketchOp{}, opts...)
+		assert.NoError(t, err)
+		for i := int64(0); i < 10000; i++ {
+			sketch.Update(i)
+		}
+		sl, err := sketch.ToSlice()
+		assert.NoError(t, err)
+		return sl
+	}
+	assert.Equal(t, image(WithRandSeed(1)), image(WithRandSeed(1)))
+	assert.NotEqual(t, image(WithRandSeed(1)), image(WithRandSeed(2)))
+	assert.Equal(t, image(WithRandSeed(3)), image(WithRandSource(rand.NewSource(3))))
+}
+
+func TestItemsSketch_Downsample(t *testing.T) {
+	sketch, err := NewItemsSketch[int64](_DEFAULT_K, Int64ItemsSketchOp{}, WithRandSeed(1))
+	assert.NoError(t, err)
+	assert.Error(t, sketch.Downsample(_MIN_K-1))
+	assert.Error(t, sketch.Downsample(_DEFAULT_K+1))
+	assert.NoError(t, sketch.Downsample(_DEFAULT_K/2))
+	assert.True(t, sketch.IsEmpty())
+	assert.Equal(t, _DEFAULT_K/2, sketch.GetK())
+
+	n := int64(100000)
+	for i := int64(0); i < n; i++ {
+		sketch.Update(i)
+	}
+	numRetained := sketch.GetNumRetained()
+	assert.NoError(t, sketch.Downsample(_DEFAULT_K/2))
+	assert.Equal(t, numRetained, sketch.GetNumRetained())
+	assert.NoError(t, sketch.Downsample(_MIN_K*2))
+
+	small, err := NewItemsSketch[int64](_MIN_K*2, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	assert.Equal(t, _MIN_K*2, sketch.GetK())
+	assert.Equal(t, small.GetNormalizedRankError(false), sketch.GetNormalizedRankError(false))
+	assert.Equal(t, small.GetNormalizedRankError(true), sketch.GetNormalizedRankError(true))
+	assert.Equal(t, uint64(n), sketch.GetN())
+	assert.Less(t, sketch.GetNumRetained(), numRetained)
+	minV, err := sketch.GetMinItem()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), minV)
+	maxV, err := sketch.GetMaxItem()
+	assert.NoError(t, err)
+	assert.Equal(t, n-1, maxV)
+	for _, rank := range []float64{0.1, 0.5, 0.9} {
+		quantile, err := sketch.GetQuantile(rank, true)
+		assert.NoError(t, err)
+		assert.InDelta(t, rank, float64(quantile)/float64(n), 2*sketch.GetNormalizedRankError(false))
+	}
+
+	// the downsampled sketch is valid
+	sl, err := sketch.ToSlice()
+	assert.NoError(t, err)
+	sketch2, err := NewItemsSketchFromSlice[int64](sl, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	assert.Equal(t, sketch.GetN(), sketch2.GetN())
+	sketch2.Update(n)
+	assert.Equal(t, uint64(n+1), sketch2.GetN())
+}
+
+func TestItemsSketch_RankAndQuantileBounds(t *testing.T) {
+	sketch, err := NewItemsSketch[int64](_DEFAULT_K, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	_, err = sketch.GetQuantileLowerBound(0.5)
+	assert.Error(t, err)
+
+	n := int64(100000)
+	for i := int64(0); i < n; i++ {
+		sketch.Update(i)
+	}
+	eps := sketch.GetNormalizedRankError(false)
+	assert.Equal(t, 0.5-eps, sketch.GetRankLowerBound(0.5))
+	assert.Equal(t, 0.5+eps, sketch.GetRankUpperBound(0.5))
+	assert.Equal(t, 0.0, sketch.GetRankLowerBound(eps/2))
+	assert.Equal(t, 1.0, sketch.GetRankUpperBound(1-eps/2))
+
+	_, err = sketch.GetQuantileUpperBound(1.5)
+	assert.Error(t, err)
+	ranks := []float64{0, 0.01, 0.5, 0.99, 1}
+	for _, rank := range ranks {
+		quantile, err := sketch.GetQuantile(rank, true)
+		assert.NoError(t, err)
+		lb, err := sketch.GetQuantileLowerBound(rank)
+		assert.NoError(t, err)
+		ub, err := sketch.GetQuantileUpperBound(rank)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, lb, quantile)
+		assert.LessOrEqual(t, quantile, ub)
+		// the exact quantile of the stream is within the bounds
+		if rank > 0 && rank < 1 {
+			exact := int64(math.Ceil(rank*float64(n))) - 1
+			assert.LessOrEqual(t, lb, exact)
+			assert.GreaterOrEqual(t, ub, exact)
+		}
+	}
+	// the bounds are clamped to the ranks of the retained items
+	lb, err := sketch.GetQuantileLowerBound(0)
+	assert.NoError(t, err)
+	quantile, err := sketch.GetQuantile(0, true)
+	assert.NoError(t, err)
+	assert.Equal(t, quantile, lb)
+	ub, err := sketch.GetQuantileUpperBound(1)
+	assert.NoError(t, err)
+	assert.Equal(t, n-1, ub)
+
+	view, err := sketch.GetSortedView()
+	assert.NoError(t, err)
+	quantiles, lowerBounds, upperBounds, err := view.GetQuantilesWithBounds(ranks, true)
+	assert.NoError(t, err)
+	for i, rank := range ranks {
+		quantile, err := sketch.GetQuantile(rank, true)
+		assert.NoError(t, err)
+		assert.Equal(t, quantile, quantiles[i])
+		lb, err := sketch.GetQuantileLowerBound(rank)
+		assert.NoError(t, err)
+		assert.Equal(t, lb, lowerBounds[i])
+		ub, err := sketch.GetQuantileUpperBound(rank)
+		assert.NoError(t, err)
+		assert.Equal(t, ub, upperBounds[i])
+	}
+	_, _, _, err = view.GetQuantilesWithBounds([]float64{-1}, true)
+	assert.Error(t, err)
+}
@@ -51,36 +51,57 @@ type itemsSketchMemoryValidate[C comparable] struct {
 
 	// derived.
 	sketchBytes int //used by KllPreambleUtil
-	typeBytes   int //always 0 for generic
+	typeBytes   int //0 for generic items, the size of a value for a NumericSketch
 }
 
 func newItemsSketchMemoryValidate[C comparable](srcMem []byte, itemSketchOp ItemSketchOp[C]) (*itemsSketchMemoryValidate[C], error) {
-	capa := cap(srcMem)
-	if capa < 8 {
-		return nil, fmt.Errorf("Memory too small: %d", capa)
+	return newSketchMemoryValidate[C](srcMem, itemSketchOp, 0)
+}
+
+// newSketchMemoryValidate validates the image of a sketch whose items are sized by itemSketchOp if
+// typeBytes is 0, or have a fixed size of typeBytes otherwise, in which case itemSketchOp is not used.
+func newSketchMemoryValidate[C comparable](srcMem []byte, itemSketchOp ItemSketchOp[C], typeBytes int) (*itemsSketchMemoryValidate[C], error) {
+	vlid, offsetBytes, numItems, err := newSketchPreambleValidate[C](srcMem, itemSketchOp, typeBytes)
+	if err != nil {
+		return nil, err
+	}
+	v, err := sizeOfMany(srcMem, offsetBytes, numItems, typeBytes, itemSketchOp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptImage, err)
+	}
+	vlid.sketchBytes = offsetBytes + v
+	return vlid, nil
+}
+
+// newSketchPreambleValidate validates the preamble of an image, including its levels, and returns
+// the offset and the number of the serialized items that follow it, min and max included.
+// srcMem only has to hold the preamble, the items are not read.
+func newSketchPreambleValidate[C comparable](srcMem []byte, itemSketchOp ItemSketchOp[C], typeBytes int) (*itemsSketchMemoryValidate[C], int, int, error) {
+	if len(srcMem) < _DATA_START_ADR_SINGLE_ITEM {
+		return nil, 0, 0, fmt.Errorf("%w: image too small: %d", ErrCorruptImage, len(srcMem))
 	}
 	preInts := getPreInts(srcMem)
 	serVer := getSerVer(srcMem)
-	sketchStructure := getSketchStructure(preInts, serVer)
+	sketchStructure, err := getSketchStructure(preInts, serVer)
+	if err != nil {
+		return nil, 0, 0, err
+	}
 	familyID := getFamilyID(srcMem)
 	if familyID != internal.FamilyEnum.Kll.Id {
-		return nil, fmt.Errorf("Source not KLL: %d", familyID)
+		return nil, 0, 0, fmt.Errorf("%w: source not KLL: %d", ErrCorruptImage, familyID)
 	}
 	flags := getFlags(srcMem)
 	k := getK(srcMem)
 	m := getM(srcMem)
-	err := checkM(m)
-	if err != nil {
-		return nil, err
+	if err := checkM(m); err != nil {
+		return nil, 0, 0, fmt.Errorf("%w: %v", ErrCorruptImage, err)
 	}
-	err = checkK(k, m)
-	if err != nil {
-		return nil, err
+	if err := checkK(k, m); err != nil {
+		return nil, 0, 0, fmt.Errorf("%w: %v", ErrCorruptImage, err)
 	}
 	//flags
 	emptyFlag := getEmptyFlag(srcMem)
 	level0SortedFlag := getLevelZeroSortedFlag(srcMem)
-	typeBytes := 0
 	vlid := &itemsSketchMemoryValidate[C]{
 		srcMem:           srcMem,
 		itemSketchOp:     itemSketchOp,
@@ -95,78 +116,184 @@ func newItemsSketchMemoryValidate[C comparable](srcMem []byte, itemSketchOp Item
 		level0SortedFlag: level0SortedFlag,
 		typeBytes:        typeBytes,
 	}
-	err = vlid.validate()
-	return vlid, err
+	offsetBytes, numItems, err := vlid.validate()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return vlid, offsetBytes, numItems, nil
 }
 
-func (vlid *itemsSketchMemoryValidate[C]) validate() error {
+// validate checks the preamble according to the structure of the image and returns the offset and
+// the number of the serialized items.
+func (vlid *itemsSketchMemoryValidate[C]) validate() (int, int, error) {
 	switch vlid.sketchStructure {
 	case _COMPACT_FULL:
 		if vlid.emptyFlag {
-			return fmt.Errorf("Empty flag and compact full")
+			return 0, 0, fmt.Errorf("%w: empty flag and compact full", ErrCorruptImage)
+		}
+		if err := vlid.validateFullPreamble(false); err != nil {
+			return 0, 0, err
 		}
-		vlid.n = getN(vlid.srcMem)
-		vlid.minK = getMinK(vlid.srcMem)
-		vlid.numLevels = getNumLevels(vlid.srcMem)
-		// Get Levels Arr and add the last element
-		vlid.levelsArr = make([]uint32, vlid.numLevels+1)
-		for i := uint8(0); i < vlid.numLevels; i++ {
-			vlid.levelsArr[i] = binary.LittleEndian.Uint32(vlid.srcMem[_DATA_START_ADR+i*4 : _DATA_START_ADR+i*4+4])
+		retainedItems := vlid.levelsArr[vlid.numLevels] - vlid.levelsArr[0]
+		return _DATA_START_ADR + int(vlid.numLevels)*4, int(retainedItems) + 2, nil //2 for min & max
+
+	case _UPDATABLE:
+		if err := vlid.validateFullPreamble(true); err != nil {
+			return 0, 0, err
 		}
-		capacityItems := computeTotalItemCapacity(uint16(vlid.k), uint8(vlid.m), uint8(vlid.numLevels))
-		vlid.levelsArr[vlid.numLevels] = capacityItems //load the last one
-		sb, err := computeSketchBytes(vlid.srcMem, vlid.levelsArr, vlid.typeBytes, vlid.itemSketchOp)
-		if err != nil {
-			return err
+		if vlid.emptyFlag != (vlid.n == 0) {
+			return 0, 0, fmt.Errorf("%w: empty flag and n %d", ErrCorruptImage, vlid.n)
 		}
-		vlid.sketchBytes = sb
+		// the levels hold the last one, the items include the free space of level 0
+		return _DATA_START_ADR + (int(vlid.numLevels)+1)*4, int(vlid.levelsArr[vlid.numLevels]) + 2, nil //2 for min & max
 
 	case _COMPACT_EMPTY:
 		if !vlid.emptyFlag {
-			return fmt.Errorf("Empty flag and compact empty")
+			return 0, 0, fmt.Errorf("%w: empty flag not set and compact empty", ErrCorruptImage)
 		}
 		vlid.n = 0 //assumed
 		vlid.minK = uint16(vlid.k)
 		vlid.numLevels = 1 //assumed
 		vlid.levelsArr = []uint32{uint32(vlid.k), uint32(vlid.k)}
-		vlid.sketchBytes = _DATA_START_ADR_SINGLE_ITEM
-	case _COMPACT_SINGLE:
+		return _DATA_START_ADR_SINGLE_ITEM, 0, nil
+
+	default: // _COMPACT_SINGLE
 		if vlid.emptyFlag {
-			return fmt.Errorf("Empty flag and compact single")
+			return 0, 0, fmt.Errorf("%w: empty flag and compact single", ErrCorruptImage)
 		}
 		vlid.n = 1 //assumed
 		vlid.minK = uint16(vlid.k)
 		vlid.numLevels = 1 //assumed
 		vlid.levelsArr = []uint32{uint32(vlid.k) - 1, uint32(vlid.k)}
-		v, err := vlid.itemSketchOp.sizeOfMany(vlid.srcMem, _DATA_START_ADR_SINGLE_ITEM, 1)
-		if err != nil {
-			return err
+		return _DATA_START_ADR_SINGLE_ITEM, 1, nil
+	}
+}
+
+// validateFullPreamble reads and checks n, min K, the number of levels and the levels of the full
+// preamble of a _COMPACT_FULL or an _UPDATABLE image. The last level, the capacity of the sketch,
+// is only stored by _UPDATABLE images and must match the one computed from K, M and the number of levels.
+func (vlid *itemsSketchMemoryValidate[C]) validateFullPreamble(updatable bool) error {
+	if len(vlid.srcMem) < _DATA_START_ADR {
+		return fmt.Errorf("%w: image too small for a full preamble: %d", ErrCorruptImage, len(vlid.srcMem))
+	}
+	vlid.n = getN(vlid.srcMem)
+	vlid.minK = getMinK(vlid.srcMem)
+	vlid.numLevels = getNumLevels(vlid.srcMem)
+	if vlid.numLevels == 0 || vlid.numLevels > _MAX_NUM_LEVELS {
+		return fmt.Errorf("%w: invalid number of levels: %d", ErrCorruptImage, vlid.numLevels)
+	}
+	if vlid.minK < _MIN_K || vlid.minK > vlid.k {
+		return fmt.Errorf("%w: invalid min K %d for K %d", ErrCorruptImage, vlid.minK, vlid.k)
+	}
+	numLevelsInImage := int(vlid.numLevels)
+	if updatable {
+		numLevelsInImage++
+	}
+	if len(vlid.srcMem) < _DATA_START_ADR+numLevelsInImage*4 {
+		return fmt.Errorf("%w: image too small for %d levels: %d", ErrCorruptImage, vlid.numLevels, len(vlid.srcMem))
+	}
+	// Get Levels Arr and add the last element
+	vlid.levelsArr = make([]uint32, vlid.numLevels+1)
+	for i := 0; i < numLevelsInImage; i++ {
+		vlid.levelsArr[i] = binary.LittleEndian.Uint32(vlid.srcMem[_DATA_START_ADR+i*4:])
+	}
+	capacityItems := computeTotalItemCapacity(vlid.k, vlid.m, vlid.numLevels)
+	if updatable && vlid.levelsArr[vlid.numLevels] != capacityItems {
+		return fmt.Errorf("%w: the last level %d differs from the capacity %d", ErrCorruptImage, vlid.levelsArr[vlid.numLevels], capacityItems)
+	}
+	vlid.levelsArr[vlid.numLevels] = capacityItems //load the last one
+	return checkLevels(vlid.levelsArr, vlid.n)
+}
+
+// checkLevels checks that the levels are increasing up to the capacity of the sketch, its last
+// element, and that the weights of the retained items, 2^level for each item of a level, add up to n.
+func checkLevels(levelsArr []uint32, n uint64) error {
+	numLevels := len(levelsArr) - 1
+	weight := uint64(0)
+	for level := 0; level < numLevels; level++ {
+		if levelsArr[level] > levelsArr[level+1] {
+			return fmt.Errorf("%w: levels not in increasing order: %d, %d", ErrCorruptImage, levelsArr[level], levelsArr[level+1])
 		}
-		vlid.sketchBytes = _DATA_START_ADR_SINGLE_ITEM + v
-	default:
-		return fmt.Errorf("Invalid preamble ints and serial version combo")
+		pop := uint64(levelsArr[level+1] - levelsArr[level])
+		if pop > (n-weight)>>level {
+			return fmt.Errorf("%w: the weight of the retained items exceeds n %d", ErrCorruptImage, n)
+		}
+		weight += pop << level
+	}
+	if weight != n {
+		return fmt.Errorf("%w: the weight of the retained items %d differs from n %d", ErrCorruptImage, weight, n)
 	}
 	return nil
 }
 
-func computeSketchBytes[C comparable](srcMem []byte, levelsArr []uint32, typeBytes int, itemSketchOp ItemSketchOp[C]) (int, error) {
-	numLevels := len(levelsArr) - 1
-	retainedItems := levelsArr[numLevels] - levelsArr[0]
-	levelsLen := len(levelsArr) - 1
-	numItems := retainedItems
-	offsetBytes := _DATA_START_ADR + levelsLen*4
-	if typeBytes == 1 {
-		v, err := itemSketchOp.sizeOfMany(srcMem, offsetBytes, int(numItems))
-		if err != nil {
-			return 0, err
+// sizeOfMany returns the size in bytes of numItems items at offsetBytes, see newSketchMemoryValidate.
+func sizeOfMany[C comparable](srcMem []byte, offsetBytes int, numItems int, typeBytes int, itemSketchOp ItemSketchOp[C]) (int, error) {
+	if typeBytes > 0 {
+		return sizeOfManyFixed(srcMem, offsetBytes, numItems, typeBytes)
+	}
+	return itemSketchOp.SizeOfMany(srcMem, offsetBytes, numItems)
+}
+
+// ImageInfo is the preamble of a serialized image, see InspectImage.
+type ImageInfo struct {
+	// Structure is the layout of the image: COMPACT_EMPTY, COMPACT_SINGLE, COMPACT_FULL or UPDATABLE.
+	Structure string
+	// PreambleBytes is the size in bytes of the preamble announced by its first byte, levels excluded.
+	PreambleBytes int
+	Flags         int
+	K             uint16
+	M             uint8
+	// FullPreamble is true if N, MinK, NumLevels and Levels were read from a full preamble. Levels are
+	// as stored in the image, the last one, the capacity of the sketch, is only stored by UPDATABLE images.
+	FullPreamble bool
+	N            uint64
+	MinK         uint16
+	NumLevels    uint8
+	Levels       []uint32
+	// NumItems is the number of serialized items, min and max included, starting at ItemsOffsetBytes.
+	ItemsOffsetBytes int
+	NumItems         int
+}
+
+// InspectImage decodes and validates the preamble of a serialized image, its levels included, without
+// deserializing the items, whose serialization depends on the ItemSketchOp. The fields decoded before
+// the preamble was found invalid are returned along with the error.
+func InspectImage(image []byte) (ImageInfo, error) {
+	if len(image) < _DATA_START_ADR_SINGLE_ITEM {
+		return ImageInfo{}, fmt.Errorf("%w: image too small: %d", ErrCorruptImage, len(image))
+	}
+	info := ImageInfo{
+		PreambleBytes: getPreInts(image) * 4,
+		Flags:         getFlags(image),
+		K:             getK(image),
+		M:             getM(image),
+	}
+	structure, err := getSketchStructure(getPreInts(image), getSerVer(image))
+	if err != nil {
+		return info, err
+	}
+	info.Structure = structure.String()
+	if (structure == _COMPACT_FULL || structure == _UPDATABLE) && len(image) >= _DATA_START_ADR {
+		info.FullPreamble = true
+		info.N = getN(image)
+		info.MinK = getMinK(image)
+		info.NumLevels = getNumLevels(image)
+		numLevelsInImage := int(info.NumLevels)
+		if structure == _UPDATABLE {
+			numLevelsInImage++
 		}
-		offsetBytes += v + 2 //2 for min & max
-	} else {
-		v, err := itemSketchOp.sizeOfMany(srcMem, offsetBytes, int(numItems)+2) //2 for min & max
-		if err != nil {
-			return 0, err
+		for i := 0; i < numLevelsInImage && _DATA_START_ADR+(i+1)*4 <= len(image); i++ {
+			info.Levels = append(info.Levels, binary.LittleEndian.Uint32(image[_DATA_START_ADR+i*4:]))
 		}
-		offsetBytes += v
 	}
-	return offsetBytes, nil
+	_, offsetBytes, numItems, err := newSketchPreambleValidate[string](image, nil, 0)
+	if err != nil {
+		return info, err
+	}
+	info.ItemsOffsetBytes = offsetBytes
+	info.NumItems = numItems
+	if numItems > 0 && len(image) <= offsetBytes {
+		return info, fmt.Errorf("%w: image without its %d items: %d", ErrCorruptImage, numItems, len(image))
+	}
+	return info, nil
 }
@@ -18,9 +18,14 @@
 package kll
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/apache/datasketches-go/internal"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
 	"os"
 	"testing"
 )
@@ -33,7 +38,7 @@ func TestGenerateGoFiles(t *testing.T) {
 	nArr := []int{0, 1, 10, 100, 1000, 10000, 100000, 1000000}
 	for _, n := range nArr {
 		digits := numDigits(n)
-		sk, err := NewItemsSketch[string](_DEFAULT_K, stringItemsSketchOp{})
+		sk, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
 		assert.NoError(t, err)
 		for i := 1; i <= n; i++ {
 			sk.Update(intToFixedLengthString(i, digits))
@@ -42,6 +47,23 @@ func TestGenerateGoFiles(t *testing.T) {
 		assert.NoError(t, err)
 		err = os.WriteFile(fmt.Sprintf("%s/kll_string_n%d_go.sk", internal.GoPath, n), slc, 0644)
 		assert.NoError(t, err)
+
+		doubles, err := NewDoublesSketch(_DEFAULT_K)
+		assert.NoError(t, err)
+		floats, err := NewFloatsSketch(_DEFAULT_K)
+		assert.NoError(t, err)
+		for i := 1; i <= n; i++ {
+			doubles.Update(float64(i))
+			floats.Update(float32(i))
+		}
+		slc, err = doubles.ToSlice()
+		assert.NoError(t, err)
+		err = os.WriteFile(fmt.Sprintf("%s/kll_double_n%d_go.sk", internal.GoPath, n), slc, 0644)
+		assert.NoError(t, err)
+		slc, err = floats.ToSlice()
+		assert.NoError(t, err)
+		err = os.WriteFile(fmt.Sprintf("%s/kll_float_n%d_go.sk", internal.GoPath, n), slc, 0644)
+		assert.NoError(t, err)
 	}
 }
 
@@ -52,8 +74,8 @@ func TestJavaCompat(t *testing.T) {
 			digits := numDigits(n)
 			bytes, err := os.ReadFile(fmt.Sprintf("%s/kll_string_n%d_java.sk", internal.JavaPath, n))
 			assert.NoError(t, err)
-			sketch, err := NewItemsSketchFromSlice[string](bytes, stringItemsSketchOp{})
-			if err != nil {
+			sketch, err := NewItemsSketchFromSlice[string](bytes, StringItemsSketchOp{})
+			if !assert.NoError(t, err) {
 				return
 			}
 
@@ -81,7 +103,7 @@ func TestJavaCompat(t *testing.T) {
 
 				weight := int64(0)
 				it := sketch.GetIterator()
-				lessFn := stringItemsSketchOp{}.lessFn()
+				lessFn := StringItemsSketchOp{}.LessFn()
 				for it.Next() {
 					qut := it.GetQuantile()
 					assert.True(t, lessFn(minV, qut) || minV == qut, fmt.Sprintf("min: \"%v\" \"%v\"", minV, qut))
@@ -92,4 +114,349 @@ func TestJavaCompat(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("Java KLL Double", func(t *testing.T) {
+		for _, n := range []int{0, 1, 10, 100, 1000, 10000, 100000, 1000000} {
+			checkNumericCompat(t, fmt.Sprintf("%s/kll_double_n%d_java.sk", internal.JavaPath, n), n, NewDoublesSketchFromSlice)
+		}
+	})
+
+	t.Run("Java KLL Float", func(t *testing.T) {
+		for _, n := range []int{0, 1, 10, 100, 1000, 10000, 100000, 1000000} {
+			checkNumericCompat(t, fmt.Sprintf("%s/kll_float_n%d_java.sk", internal.JavaPath, n), n, NewFloatsSketchFromSlice)
+		}
+	})
+}
+
+func TestCppCompat(t *testing.T) {
+	t.Run("Cpp KLL Double", func(t *testing.T) {
+		for _, n := range []int{0, 1, 10, 100, 1000, 10000, 100000, 1000000} {
+			checkNumericCompat(t, fmt.Sprintf("%s/kll_double_n%d_cpp.sk", internal.CppPath, n), n, NewDoublesSketchFromSlice)
+		}
+	})
+
+	t.Run("Cpp KLL Float", func(t *testing.T) {
+		for _, n := range []int{0, 1, 10, 100, 1000, 10000, 100000, 1000000} {
+			checkNumericCompat(t, fmt.Sprintf("%s/kll_float_n%d_cpp.sk", internal.CppPath, n), n, NewFloatsSketchFromSlice)
+		}
+	})
+}
+
+// checkNumericCompat deserializes the image of a sketch of the items 1 to n written by another
+// library, checks its content and that it serializes back to the same image.
+// The check is skipped when the image is not part of the test data.
+func checkNumericCompat[T float32 | float64](t *testing.T, path string, n int, fromSlice func([]byte, ...SketchOption) (*NumericSketch[T], error)) {
+	t.Run(fmt.Sprintf("n%d", n), func(t *testing.T) {
+		bytes, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			t.Skipf("%s not found", path)
+		}
+		assert.NoError(t, err)
+		sketch, err := fromSlice(bytes)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, uint16(200), sketch.GetK())
+		assert.Equal(t, uint64(n), sketch.GetN())
+		assert.Equal(t, n == 0, sketch.IsEmpty())
+		assert.Equal(t, n > 100, sketch.IsEstimationMode())
+		if n > 0 {
+			minV, err := sketch.GetMinItem()
+			assert.NoError(t, err)
+			assert.Equal(t, T(1), minV)
+			maxV, err := sketch.GetMaxItem()
+			assert.NoError(t, err)
+			assert.Equal(t, T(n), maxV)
+
+			weight := int64(0)
+			it := sketch.GetIterator()
+			for it.Next() {
+				qut := it.GetQuantile()
+				assert.True(t, qut >= minV && qut <= maxV, "%v not in [%v, %v]", qut, minV, maxV)
+				weight += it.GetWeight()
+			}
+			assert.Equal(t, int64(n), weight)
+		}
+
+		slc, err := sketch.ToSlice()
+		assert.NoError(t, err)
+		assert.Equal(t, bytes, slc)
+	})
+}
+
+func TestItemsSketch_SingleItemImage(t *testing.T) {
+	// a single item image is the 8 bytes preamble followed by the item, as written by Java
+	javaBytes, err := os.ReadFile(fmt.Sprintf("%s/kll_string_n1_java.sk", internal.JavaPath))
+	assert.NoError(t, err)
+	strings, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	strings.Update("1")
+	slc, err := strings.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, javaBytes, slc)
+	size, err := strings.GetSerializedSizeBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, 13, size)
+
+	longs, err := NewItemsSketch[int64](_DEFAULT_K, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	longs.Update(-2)
+	slc, err = longs.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x02, 0x02, 0x0f, 0x04, 0xc8, 0x00, 0x08, 0x00,
+		0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}, slc)
+	size, err = longs.GetSerializedSizeBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, 16, size)
+
+	doubles, err := NewDoublesSketch(_DEFAULT_K)
+	assert.NoError(t, err)
+	doubles.Update(-2)
+	doublesSlc, err := doubles.ToSlice()
+	assert.NoError(t, err)
+	items, err := NewItemsSketch[float64](_DEFAULT_K, Float64ItemsSketchOp{})
+	assert.NoError(t, err)
+	items.Update(-2)
+	slc, err = items.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, doublesSlc, slc)
+}
+
+func TestInspectImage(t *testing.T) {
+	sketch, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	sketch.Update("a")
+	slc, err := sketch.ToSlice()
+	assert.NoError(t, err)
+	info, err := InspectImage(slc)
+	assert.NoError(t, err)
+	assert.Equal(t, "COMPACT_SINGLE", info.Structure)
+	assert.False(t, info.FullPreamble)
+	assert.Equal(t, _DATA_START_ADR_SINGLE_ITEM, info.ItemsOffsetBytes)
+	assert.Equal(t, 1, info.NumItems)
+	_, err = InspectImage(slc[:_DATA_START_ADR_SINGLE_ITEM])
+	assert.ErrorIs(t, err, ErrCorruptImage)
+
+	for i := 0; i < 1000; i++ {
+		sketch.Update(intToFixedLengthString(i, 4))
+	}
+	slc, err = sketch.ToUpdatableSlice()
+	assert.NoError(t, err)
+	info, err = InspectImage(slc)
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATABLE", info.Structure)
+	assert.True(t, info.FullPreamble)
+	assert.Equal(t, uint64(1001), info.N)
+	assert.Equal(t, int(sketch.numLevels), int(info.NumLevels))
+	assert.Equal(t, sketch.levels, info.Levels)
+	assert.Equal(t, int(sketch.levels[sketch.numLevels])+2, info.NumItems)
+
+	slc[_NUM_LEVELS_BYTE_ADR] = 0
+	info, err = InspectImage(slc)
+	assert.ErrorIs(t, err, ErrCorruptImage)
+	assert.Equal(t, "UPDATABLE", info.Structure)
+}
+
+func TestItemsSketch_DeserializeCorruptImage(t *testing.T) {
+	sketch, err := NewItemsSketch[string](20, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		sketch.Update(intToFixedLengthString(i, 4))
+	}
+	slc, err := sketch.ToSlice()
+	assert.NoError(t, err)
+
+	corrupt := func(f func(b []byte) []byte) error {
+		b := f(append([]byte{}, slc...))
+		_, err := NewItemsSketchFromSlice[string](b, StringItemsSketchOp{})
+		return err
+	}
+
+	err = corrupt(func(b []byte) []byte { return b[:4] })
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+	err = corrupt(func(b []byte) []byte { return b[:len(b)-1] })
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+	err = corrupt(func(b []byte) []byte { b[_FAMILY_BYTE_ADR] = 7; return b })
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+	err = corrupt(func(b []byte) []byte { b[_NUM_LEVELS_BYTE_ADR] = 0; return b })
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+	err = corrupt(func(b []byte) []byte { b[_NUM_LEVELS_BYTE_ADR] = 255; return b })
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+	err = corrupt(func(b []byte) []byte {
+		binary.LittleEndian.PutUint64(b[_N_LONG_ADR:], sketch.GetN()+1)
+		return b
+	})
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+	err = corrupt(func(b []byte) []byte {
+		binary.LittleEndian.PutUint32(b[_DATA_START_ADR:], 1<<31)
+		return b
+	})
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+	err = corrupt(func(b []byte) []byte { b[_SER_VER_BYTE_ADR] = 9; return b })
+	assert.True(t, errors.Is(err, ErrUnsupportedVersion), err)
+	// a compact image is not an updatable one
+	err = corrupt(func(b []byte) []byte { b[_SER_VER_BYTE_ADR] = _SERIAL_VERSION_UPDATABLE; return b })
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+
+	slc, err = sketch.ToUpdatableSlice()
+	assert.NoError(t, err)
+	err = corrupt(func(b []byte) []byte { return b[:len(b)-1] })
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+	err = corrupt(func(b []byte) []byte {
+		binary.LittleEndian.PutUint32(b[_DATA_START_ADR+int(sketch.numLevels)*4:], 1000)
+		return b
+	})
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+	err = corrupt(func(b []byte) []byte { b[_FLAGS_BYTE_ADR] |= _EMPTY_BIT_MASK; return b })
+	assert.True(t, errors.Is(err, ErrCorruptImage), err)
+}
+
+func TestItemsSketch_UpdatableSerialization(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 100, 1000, 10000} {
+		t.Run(fmt.Sprintf("n%d", n), func(t *testing.T) {
+			sketch, err := NewItemsSketch[string](20, StringItemsSketchOp{})
+			assert.NoError(t, err)
+			for i := 0; i < n; i++ {
+				sketch.Update(intToFixedLengthString(i, 5))
+			}
+			slc, err := sketch.ToUpdatableSlice()
+			assert.NoError(t, err)
+			assert.Equal(t, sketch.GetUpdatableSerializedSizeBytes(), len(slc))
+			assert.Equal(t, byte(_PREAMBLE_INTS_FULL), slc[_PREAMBLE_INTS_BYTE_ADR])
+			assert.Equal(t, byte(_SERIAL_VERSION_UPDATABLE), slc[_SER_VER_BYTE_ADR])
+
+			sketch2, err := NewItemsSketchFromSlice[string](slc, StringItemsSketchOp{})
+			assert.NoError(t, err)
+			assert.Equal(t, sketch.GetN(), sketch2.GetN())
+			assert.Equal(t, sketch.GetNumRetained(), sketch2.GetNumRetained())
+			assert.Equal(t, sketch.levels, sketch2.levels)
+			assert.Equal(t, len(sketch.items), len(sketch2.items))
+			slc2, err := sketch2.ToUpdatableSlice()
+			assert.NoError(t, err)
+			assert.Equal(t, slc, slc2)
+			compact, err := sketch.ToSlice()
+			assert.NoError(t, err)
+			compact2, err := sketch2.ToSlice()
+			assert.NoError(t, err)
+			assert.Equal(t, compact, compact2)
+
+			// the reloaded sketch keeps on being updated
+			for i := n; i < n+1000; i++ {
+				sketch2.Update(intToFixedLengthString(i, 5))
+			}
+			assert.Equal(t, uint64(n+1000), sketch2.GetN())
+			minV, err := sketch2.GetMinItem()
+			assert.NoError(t, err)
+			assert.Equal(t, intToFixedLengthString(0, 5), minV)
+			maxV, err := sketch2.GetMaxItem()
+			assert.NoError(t, err)
+			assert.Equal(t, intToFixedLengthString(n+999, 5), maxV)
+		})
+	}
+}
+
+// addItemsFuzzSeeds adds the Java string images and the images of a Go string sketch to the corpus.
+func addItemsFuzzSeeds(f *testing.F) {
+	for _, n := range []int{0, 1, 10, 100, 1000} {
+		bytes, err := os.ReadFile(fmt.Sprintf("%s/kll_string_n%d_java.sk", internal.JavaPath, n))
+		assert.NoError(f, err)
+		f.Add(bytes)
+	}
+	sketch, err := NewItemsSketch[string](_MIN_K, StringItemsSketchOp{})
+	assert.NoError(f, err)
+	for i := 0; i < 100; i++ {
+		sketch.Update(intToFixedLengthString(i, 3))
+	}
+	slc, err := sketch.ToSlice()
+	assert.NoError(f, err)
+	f.Add(slc)
+	slc, err = sketch.ToUpdatableSlice()
+	assert.NoError(f, err)
+	f.Add(slc)
+}
+
+func FuzzItemsSketchFromSlice(f *testing.F) {
+	addItemsFuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		sketch, err := NewItemsSketchFromSlice[string](b, StringItemsSketchOp{})
+		if err != nil {
+			if !errors.Is(err, ErrCorruptImage) && !errors.Is(err, ErrUnsupportedVersion) {
+				t.Fatalf("untyped error: %v", err)
+			}
+			return
+		}
+		if _, err := sketch.ToSlice(); err != nil {
+			t.Fatal(err)
+		}
+		if sketch.IsEmpty() {
+			return
+		}
+		if _, err := sketch.GetQuantile(0.5, true); err != nil {
+			t.Fatal(err)
+		}
+		other, err := NewItemsSketch[string](_MIN_K, StringItemsSketchOp{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := other.Merge(sketch); err != nil {
+			t.Fatal(err)
+		}
+		sketch.Update("")
+	})
+}
+
+func FuzzItemsSketchReadFrom(f *testing.F) {
+	addItemsFuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		sketch, err := NewItemsSketch[string](_MIN_K, StringItemsSketchOp{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkReadFrom(t, b, sketch.ReadFrom, func(image []byte) ([]byte, error) {
+			decoded, err := NewItemsSketchFromSlice[string](image, StringItemsSketchOp{})
+			if err != nil {
+				return nil, err
+			}
+			return decoded.ToSlice()
+		}, sketch.ToSlice)
+	})
+}
+
+// checkReadFrom checks that readFrom consumes exactly the bytes it reports, fails with a typed error
+// if and only if the slice constructor rejects b, and otherwise decodes the image the slice
+// constructor decodes from the bytes it read. fromSlice and toSlice return the compact images of
+// the sketch decoded by the slice constructor and by readFrom.
+func checkReadFrom(t *testing.T, b []byte, readFrom func(r io.Reader) (int64, error),
+	fromSlice func(image []byte) ([]byte, error), toSlice func() ([]byte, error)) {
+	r := bytes.NewReader(b)
+	n, err := readFrom(r)
+	if n != int64(len(b)-r.Len()) {
+		t.Fatalf("read %d bytes, reported %d", len(b)-r.Len(), n)
+	}
+	if err != nil {
+		if !errors.Is(err, ErrCorruptImage) && !errors.Is(err, ErrUnsupportedVersion) &&
+			!errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("untyped error: %v", err)
+		}
+		if _, sliceErr := fromSlice(b); sliceErr == nil {
+			t.Fatalf("ReadFrom rejected an image accepted by the slice constructor: %v", err)
+		}
+		return
+	}
+	want, err := fromSlice(b[:n])
+	if err != nil {
+		t.Fatalf("ReadFrom accepted an image rejected by the slice constructor: %v", err)
+	}
+	got, err := toSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Fatalf("ReadFrom decoded %x, the slice constructor %x", got, want)
+	}
 }
@@ -0,0 +1,700 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kll
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"slices"
+	"unsafe"
+
+	"github.com/apache/datasketches-go/internal"
+)
+
+// NumericSketch is the KLL sketch specialized for float64 (DoublesSketch) and float32 (FloatsSketch)
+// values. Unlike ItemsSketch[C], values are compared natively instead of through the LessFn of an
+// ItemSketchOp, and its image is the one of the KllDoublesSketch and KllFloatsSketch of Java and the
+// kll_sketch<double> and kll_sketch<float> of C++.
+//
+// NaN values are ignored by Update and are rejected as split points and query items.
+type NumericSketch[T float32 | float64] struct {
+	k                 uint16
+	m                 uint8
+	minK              uint16
+	numLevels         uint8
+	isLevelZeroSorted bool
+	n                 uint64
+	levels            []uint32
+	items             []T
+	minItem           T
+	maxItem           T
+	sortedView        *NumericSketchSortedView[T]
+	sortedViewBufs    sortedViewBuffers[T]
+	random            *rand.Rand
+}
+
+// DoublesSketch is the KLL sketch of float64 values, see NumericSketch.
+type DoublesSketch = NumericSketch[float64]
+
+// FloatsSketch is the KLL sketch of float32 values, see NumericSketch.
+type FloatsSketch = NumericSketch[float32]
+
+// NewDoublesSketch returns an empty DoublesSketch of parameter k, which controls the accuracy and
+// the size of the sketch, between 8 and 65535, 200 being the default of the other libraries.
+func NewDoublesSketch(k uint16, opts ...SketchOption) (*DoublesSketch, error) {
+	return newNumericSketch[float64](k, opts...)
+}
+
+// NewFloatsSketch returns an empty FloatsSketch of parameter k, see NewDoublesSketch.
+func NewFloatsSketch(k uint16, opts ...SketchOption) (*FloatsSketch, error) {
+	return newNumericSketch[float32](k, opts...)
+}
+
+// NewDoublesSketchFromSlice returns the DoublesSketch of the given image, as written by ToSlice or
+// by the Java and C++ libraries for a sketch of doubles.
+func NewDoublesSketchFromSlice(sl []byte, opts ...SketchOption) (*DoublesSketch, error) {
+	return newNumericSketchFromSlice[float64](sl, opts...)
+}
+
+// NewFloatsSketchFromSlice returns the FloatsSketch of the given image, as written by ToSlice or
+// by the Java and C++ libraries for a sketch of floats.
+func NewFloatsSketchFromSlice(sl []byte, opts ...SketchOption) (*FloatsSketch, error) {
+	return newNumericSketchFromSlice[float32](sl, opts...)
+}
+
+func newNumericSketch[T float32 | float64](k uint16, opts ...SketchOption) (*NumericSketch[T], error) {
+	if k < _MIN_K || k > _MAX_K {
+		return nil, fmt.Errorf("k must be >= %d and <= %d: %d", _MIN_K, _MAX_K, k)
+	}
+	return &NumericSketch[T]{
+		k:         k,
+		m:         _DEFAULT_M,
+		minK:      k,
+		numLevels: uint8(1),
+		levels:    []uint32{uint32(k), uint32(k)},
+		items:     make([]T, k),
+		minItem:   T(math.NaN()),
+		maxItem:   T(math.NaN()),
+		random:    newSketchOptions(opts).random,
+	}, nil
+}
+
+func newNumericSketchFromSlice[T float32 | float64](sl []byte, opts ...SketchOption) (*NumericSketch[T], error) {
+	itemBytes := numericItemBytes[T]()
+	memVal, err := newSketchMemoryValidate[T](sl, nil, itemBytes)
+	if err != nil {
+		return nil, err
+	}
+	s := &NumericSketch[T]{
+		k:                 memVal.k,
+		m:                 memVal.m,
+		minK:              memVal.minK,
+		numLevels:         memVal.numLevels,
+		isLevelZeroSorted: memVal.level0SortedFlag,
+		n:                 memVal.n,
+		levels:            memVal.levelsArr,
+		items:             make([]T, memVal.levelsArr[memVal.numLevels]),
+		minItem:           T(math.NaN()),
+		maxItem:           T(math.NaN()),
+		random:            newSketchOptions(opts).random,
+	}
+	switch memVal.sketchStructure {
+	case _COMPACT_SINGLE:
+		item := getNumericItem[T](sl, _DATA_START_ADR_SINGLE_ITEM)
+		s.minItem = item
+		s.maxItem = item
+		s.items[s.k-1] = item
+	case _COMPACT_FULL:
+		offset := _DATA_START_ADR + int(memVal.numLevels)*4
+		s.minItem = getNumericItem[T](sl, offset)
+		s.maxItem = getNumericItem[T](sl, offset+itemBytes)
+		offset += 2 * itemBytes
+		for i := s.levels[0]; i < s.levels[s.numLevels]; i++ {
+			s.items[i] = getNumericItem[T](sl, offset)
+			offset += itemBytes
+		}
+	case _UPDATABLE:
+		offset := _DATA_START_ADR + (int(memVal.numLevels)+1)*4
+		if s.n > 0 {
+			s.minItem = getNumericItem[T](sl, offset)
+			s.maxItem = getNumericItem[T](sl, offset+itemBytes)
+		}
+		offset += 2 * itemBytes
+		for i := range s.items {
+			s.items[i] = getNumericItem[T](sl, offset)
+			offset += itemBytes
+		}
+	}
+	return s, nil
+}
+
+func (s *NumericSketch[T]) IsEmpty() bool {
+	return s.n == 0
+}
+
+func (s *NumericSketch[T]) GetN() uint64 {
+	return s.n
+}
+
+func (s *NumericSketch[T]) GetK() uint16 {
+	return s.k
+}
+
+func (s *NumericSketch[T]) GetNumRetained() uint32 {
+	return s.levels[s.numLevels] - s.levels[0]
+}
+
+func (s *NumericSketch[T]) GetMinItem() (T, error) {
+	if s.IsEmpty() {
+		return T(math.NaN()), fmt.Errorf("operation is undefined for an empty sketch")
+	}
+	return s.minItem, nil
+}
+
+func (s *NumericSketch[T]) GetMaxItem() (T, error) {
+	if s.IsEmpty() {
+		return T(math.NaN()), fmt.Errorf("operation is undefined for an empty sketch")
+	}
+	return s.maxItem, nil
+}
+
+func (s *NumericSketch[T]) IsEstimationMode() bool {
+	return s.numLevels > 1
+}
+
+func (s *NumericSketch[T]) IsLevelZeroSorted() bool {
+	return s.isLevelZeroSorted
+}
+
+func (s *NumericSketch[T]) GetTotalItemsArray() []T {
+	outArr := make([]T, len(s.items))
+	copy(outArr, s.items)
+	return outArr
+}
+
+func (s *NumericSketch[T]) GetRank(item T, inclusive bool) (float64, error) {
+	if s.IsEmpty() {
+		return 0, fmt.Errorf("operation is undefined for an empty sketch")
+	}
+	s.setupSortedView()
+	return s.sortedView.GetRank(item, inclusive)
+}
+
+func (s *NumericSketch[T]) GetRanks(items []T, inclusive bool) ([]float64, error) {
+	if s.IsEmpty() {
+		return nil, fmt.Errorf("operation is undefined for an empty sketch")
+	}
+	s.setupSortedView()
+	ranks := make([]float64, len(items))
+	for i := range items {
+		var err error
+		if ranks[i], err = s.sortedView.GetRank(items[i], inclusive); err != nil {
+			return nil, err
+		}
+	}
+	return ranks, nil
+}
+
+func (s *NumericSketch[T]) GetQuantile(rank float64, inclusive bool) (T, error) {
+	if s.IsEmpty() {
+		return T(math.NaN()), fmt.Errorf("operation is undefined for an empty sketch")
+	}
+	s.setupSortedView()
+	return s.sortedView.GetQuantile(rank, inclusive)
+}
+
+func (s *NumericSketch[T]) GetQuantiles(ranks []float64, inclusive bool) ([]T, error) {
+	if s.IsEmpty() {
+		return nil, fmt.Errorf("operation is undefined for an empty sketch")
+	}
+	s.setupSortedView()
+	quantiles := make([]T, len(ranks))
+	for i := range ranks {
+		var err error
+		if quantiles[i], err = s.sortedView.GetQuantile(ranks[i], inclusive); err != nil {
+			return nil, err
+		}
+	}
+	return quantiles, nil
+}
+
+func (s *NumericSketch[T]) GetPMF(splitPoints []T, inclusive bool) ([]float64, error) {
+	if s.IsEmpty() {
+		return nil, fmt.Errorf("operation is undefined for an empty sketch")
+	}
+	s.setupSortedView()
+	return s.sortedView.GetPMF(splitPoints, inclusive)
+}
+
+func (s *NumericSketch[T]) GetCDF(splitPoints []T, inclusive bool) ([]float64, error) {
+	if s.IsEmpty() {
+		return nil, fmt.Errorf("operation is undefined for an empty sketch")
+	}
+	s.setupSortedView()
+	return s.sortedView.GetCDF(splitPoints, inclusive)
+}
+
+func (s *NumericSketch[T]) GetNormalizedRankError(pmf bool) float64 {
+	return getNormalizedRankError(s.minK, pmf)
+}
+
+// GetRankLowerBound returns the lower bound of the rank, see ItemsSketch.GetRankLowerBound.
+func (s *NumericSketch[T]) GetRankLowerBound(rank float64) float64 {
+	return getRankLowerBound(rank, s.minK)
+}
+
+// GetRankUpperBound returns the upper bound of the rank, see ItemsSketch.GetRankUpperBound.
+func (s *NumericSketch[T]) GetRankUpperBound(rank float64) float64 {
+	return getRankUpperBound(rank, s.minK)
+}
+
+// GetQuantileLowerBound returns the lower bound of the quantile, see ItemsSketch.GetQuantileLowerBound.
+func (s *NumericSketch[T]) GetQuantileLowerBound(rank float64) (T, error) {
+	if err := checkNormalizedRankBounds(rank); err != nil {
+		return T(math.NaN()), err
+	}
+	return s.GetQuantile(s.GetRankLowerBound(rank), true)
+}
+
+// GetQuantileUpperBound returns the upper bound of the quantile, see ItemsSketch.GetQuantileUpperBound.
+func (s *NumericSketch[T]) GetQuantileUpperBound(rank float64) (T, error) {
+	if err := checkNormalizedRankBounds(rank); err != nil {
+		return T(math.NaN()), err
+	}
+	return s.GetQuantile(s.GetRankUpperBound(rank), true)
+}
+
+func (s *NumericSketch[T]) GetPartitionBoundaries(numEquallySized int, inclusive bool) (*ItemsSketchPartitionBoundaries[T], error) {
+	if s.IsEmpty() {
+		return nil, fmt.Errorf("operation is undefined for an empty sketch")
+	}
+	s.setupSortedView()
+	return s.sortedView.GetPartitionBoundaries(numEquallySized, inclusive)
+}
+
+// GetSortedView returns the sorted view of the sketch, which is immutable, see ItemsSketch.GetSortedView.
+func (s *NumericSketch[T]) GetSortedView() (*NumericSketchSortedView[T], error) {
+	if s.IsEmpty() {
+		return nil, fmt.Errorf("operation is undefined for an empty sketch")
+	}
+	s.setupSortedView()
+	// the view is handed out, its buffers can no longer be reused
+	s.sortedViewBufs.detach()
+	return s.sortedView, nil
+}
+
+func (s *NumericSketch[T]) GetIterator() *ItemsSketchIterator[T] {
+	return NewItemsSketchIterator[T](s.GetTotalItemsArray(), s.getLevelsArray(), int(s.numLevels))
+}
+
+// Update presents a value to the sketch, NaN is ignored.
+func (s *NumericSketch[T]) Update(item T) {
+	if item != item {
+		return
+	}
+	s.updateItem(item)
+	s.sortedView = nil
+}
+
+// UpdateWeighted updates the sketch with the given value as many times as its weight, which must be
+// between 1 and 2^61 - 1, see ItemsSketch.UpdateWeighted. NaN is ignored.
+func (s *NumericSketch[T]) UpdateWeighted(item T, weight uint64) error {
+	if err := checkWeight(s.n, weight); err != nil {
+		return err
+	}
+	if item != item {
+		return nil
+	}
+	if weight < uint64(s.levels[0]) {
+		for i := uint64(0); i < weight; i++ {
+			s.updateItem(item)
+		}
+		s.sortedView = nil
+		return nil
+	}
+	numLevels, levels, items := weightedLevels(item, weight)
+	return s.Merge(&NumericSketch[T]{
+		k:         s.k,
+		m:         s.m,
+		minK:      s.k,
+		numLevels: numLevels,
+		n:         weight,
+		levels:    levels,
+		items:     items,
+		minItem:   item,
+		maxItem:   item,
+	})
+}
+
+func (s *NumericSketch[T]) Reset() {
+	s.n = 0
+	s.minK = s.k
+	s.isLevelZeroSorted = false
+	s.numLevels = 1
+	s.levels = []uint32{uint32(s.k), uint32(s.k)}
+	s.minItem = T(math.NaN())
+	s.maxItem = T(math.NaN())
+	s.items = make([]T, s.k)
+	s.sortedView = nil
+}
+
+// Merge merges the other sketch into this one, whose k is kept while its rank error becomes the
+// one of the smallest k of the two sketches. It returns an error, leaving this sketch unchanged, if
+// the other sketch is nil.
+func (s *NumericSketch[T]) Merge(other *NumericSketch[T]) error {
+	if other == nil {
+		return errors.New("nil sketch")
+	}
+	if other.IsEmpty() {
+		return nil
+	}
+	s.mergeNumericSketch(other)
+	s.sortedView = nil
+	return nil
+}
+
+// Downsample reduces the k of the sketch to newK, see ItemsSketch.Downsample.
+func (s *NumericSketch[T]) Downsample(newK uint16) error {
+	if newK < _MIN_K || newK > s.k {
+		return fmt.Errorf("new k must be >= %d and <= %d: %d", _MIN_K, s.k, newK)
+	}
+	if newK == s.k {
+		return nil
+	}
+	tgt, err := newNumericSketch[T](newK)
+	if err != nil {
+		return err
+	}
+	tgt.m = s.m
+	tgt.minK = min(newK, s.minK)
+	tgt.random = s.random
+	if err := tgt.Merge(s); err != nil {
+		return err
+	}
+	*s = *tgt
+	return nil
+}
+
+func (s *NumericSketch[T]) ToSlice() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(s.GetSerializedSizeBytes())
+	if _, err := s.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ToUpdatableSlice returns the image of the sketch in the updatable layout of the Java KLL sketches,
+// see ItemsSketch.ToUpdatableSlice. The min and max items of an empty sketch are NaN.
+func (s *NumericSketch[T]) ToUpdatableSlice() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(s.GetUpdatableSerializedSizeBytes())
+	if _, err := s.writeTo(&buf, _UPDATABLE); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo implements io.WriterTo, it writes the image of ToSlice to w.
+func (s *NumericSketch[T]) WriteTo(w io.Writer) (int64, error) {
+	return s.writeTo(w, s.serializedStructure())
+}
+
+func (s *NumericSketch[T]) writeTo(w io.Writer, tgtStructure sketchStructure) (int64, error) {
+	itemBytes := numericItemBytes[T]()
+	cw := internal.NewCountingWriter(w)
+
+	flags := byte(0)
+	if s.IsEmpty() {
+		flags |= _EMPTY_BIT_MASK
+	}
+	if s.isLevelZeroSorted {
+		flags |= _LEVEL_ZERO_SORTED_BIT_MASK
+	}
+	if s.n == 1 {
+		flags |= _SINGLE_ITEM_BIT_MASK
+	}
+	var preamble [_DATA_START_ADR]byte
+	preamble[0] = byte(tgtStructure.getPreInts())
+	preamble[1] = byte(tgtStructure.getSerVer())
+	preamble[2] = byte(internal.FamilyEnum.Kll.Id)
+	preamble[3] = flags
+	binary.LittleEndian.PutUint16(preamble[4:6], s.k)
+	preamble[6] = s.m
+
+	switch tgtStructure {
+	case _COMPACT_EMPTY:
+		cw.Write(preamble[:_N_LONG_ADR])
+		return cw.Count(), cw.Err()
+	case _COMPACT_SINGLE:
+		cw.Write(preamble[:_DATA_START_ADR_SINGLE_ITEM])
+		cw.Write(putNumericItems(make([]byte, itemBytes), s.items[s.k-1]))
+		return cw.Count(), cw.Err()
+	}
+
+	binary.LittleEndian.PutUint64(preamble[8:16], s.n)
+	binary.LittleEndian.PutUint16(preamble[16:18], s.minK)
+	preamble[18] = s.numLevels
+	cw.Write(preamble[:])
+
+	numLevels := int(s.numLevels)
+	start := s.levels[0]
+	if tgtStructure == _UPDATABLE {
+		numLevels++ // the last level
+		start = 0   // the free space of level 0
+	}
+	lvlsBytes := make([]byte, numLevels*4)
+	for i := 0; i < numLevels; i++ {
+		binary.LittleEndian.PutUint32(lvlsBytes[i*4:], s.levels[i])
+	}
+	cw.Write(lvlsBytes)
+	cw.Write(putNumericItems(make([]byte, 2*itemBytes), s.minItem, s.maxItem))
+
+	chunk := make([]byte, internal.StreamChunkItems*itemBytes)
+	end := s.levels[s.numLevels]
+	for i := start; i < end && cw.Err() == nil; i += internal.StreamChunkItems {
+		items := s.items[i:min(i+internal.StreamChunkItems, end)]
+		cw.Write(putNumericItems(chunk[:len(items)*itemBytes], items...))
+	}
+	return cw.Count(), cw.Err()
+}
+
+// ReadFrom implements io.ReaderFrom, it replaces the content of the sketch with the image read from
+// r, see UnmarshalBinary. Unlike a plain io.ReaderFrom, it reads exactly one image and leaves the rest
+// of r unread, so that consecutive images can be read from the same stream, io.EOF is returned if r is
+// already at its end.
+// The preamble and the levels are read and validated first, then the items by chunks of
+// internal.StreamChunkItems items, without holding the image.
+func (s *NumericSketch[T]) ReadFrom(r io.Reader) (int64, error) {
+	itemBytes := numericItemBytes[T]()
+	cr := internal.NewCountingReader(r)
+	chunk := make([]byte, internal.StreamChunkItems*itemBytes)
+	vlid, minMax, items, err := readSketch[T](cr, itemBytes, func(dst []T) error {
+		for i := 0; i < len(dst); i += internal.StreamChunkItems {
+			part := dst[i:min(i+internal.StreamChunkItems, len(dst))]
+			buf := chunk[:len(part)*itemBytes]
+			if err := cr.ReadFull(buf); err != nil {
+				return err
+			}
+			for j := range part {
+				part[j] = getNumericItem[T](buf, j*itemBytes)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return cr.Count(), err
+	}
+	sketch := &NumericSketch[T]{
+		k:                 vlid.k,
+		m:                 vlid.m,
+		minK:              vlid.minK,
+		numLevels:         vlid.numLevels,
+		isLevelZeroSorted: vlid.level0SortedFlag,
+		n:                 vlid.n,
+		levels:            vlid.levelsArr,
+		items:             items,
+		minItem:           T(math.NaN()),
+		maxItem:           T(math.NaN()),
+		random:            s.random,
+	}
+	if minMax != nil {
+		sketch.minItem, sketch.maxItem = minMax[0], minMax[1]
+	}
+	*s = *sketch
+	return cr.Count(), nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, it returns the image of ToSlice.
+func (s *NumericSketch[T]) MarshalBinary() ([]byte, error) {
+	return s.ToSlice()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, it replaces the content of the sketch with
+// the given image.
+func (s *NumericSketch[T]) UnmarshalBinary(data []byte) error {
+	sketch, err := newNumericSketchFromSlice[T](data)
+	if err != nil {
+		return err
+	}
+	sketch.random = s.random
+	*s = *sketch
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder, see MarshalBinary.
+func (s *NumericSketch[T]) GobEncode() ([]byte, error) {
+	return s.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder, see UnmarshalBinary.
+func (s *NumericSketch[T]) GobDecode(data []byte) error {
+	return s.UnmarshalBinary(data)
+}
+
+func (s *NumericSketch[T]) GetSerializedSizeBytes() int {
+	itemBytes := numericItemBytes[T]()
+	switch s.serializedStructure() {
+	case _COMPACT_EMPTY:
+		return _N_LONG_ADR
+	case _COMPACT_SINGLE:
+		return _DATA_START_ADR_SINGLE_ITEM + itemBytes
+	default:
+		return _DATA_START_ADR + int(s.numLevels)*4 + (2+int(s.GetNumRetained()))*itemBytes
+	}
+}
+
+// GetUpdatableSerializedSizeBytes returns the size in bytes of the image of ToUpdatableSlice.
+func (s *NumericSketch[T]) GetUpdatableSerializedSizeBytes() int {
+	return _DATA_START_ADR + (int(s.numLevels)+1)*4 + (2+int(s.levels[s.numLevels]))*numericItemBytes[T]()
+}
+
+func (s *NumericSketch[T]) serializedStructure() sketchStructure {
+	switch s.n {
+	case 0:
+		return _COMPACT_EMPTY
+	case 1:
+		return _COMPACT_SINGLE
+	default:
+		return _COMPACT_FULL
+	}
+}
+
+func (s *NumericSketch[T]) getLevelsArray() []uint32 {
+	levels := make([]uint32, len(s.levels))
+	copy(levels, s.levels)
+	return levels
+}
+
+// setupSortedView builds the sorted view of the sketch if it was invalidated by an update, see
+// ItemsSketch.setupSortedView.
+func (s *NumericSketch[T]) setupSortedView() {
+	if s.sortedView == nil {
+		s.sortedView = newNumericSketchSortedView[T](s, &s.sortedViewBufs)
+	}
+}
+
+func (s *NumericSketch[T]) updateItem(item T) {
+	if s.IsEmpty() {
+		s.minItem = item
+		s.maxItem = item
+	} else {
+		s.minItem = min(s.minItem, item)
+		s.maxItem = max(s.maxItem, item)
+	}
+	level0space := s.levels[0]
+	if level0space == 0 {
+		// level 0 is sorted here if it is compacted, which is faster than the sort with numericLess,
+		// but for the leftover first item of an odd level, as compressWhileUpdating does
+		levelZeroSorted := findLevelToCompact(s.k, s.m, s.numLevels, s.levels) == 0
+		if levelZeroSorted {
+			slices.Sort(s.items[s.levels[0]+(s.levels[1]-s.levels[0])%2 : s.levels[1]])
+		}
+		s.numLevels, s.levels, s.items = compressWhileUpdating(s.k, s.m, s.numLevels, s.levels, s.items, levelZeroSorted, numericLess[T], s.random)
+		level0space = s.levels[0]
+	}
+	s.n++
+	s.isLevelZeroSorted = false
+	nextPos := level0space - 1
+	s.levels[0] = nextPos
+	s.items[nextPos] = item
+}
+
+func (s *NumericSketch[T]) mergeNumericSketch(other *NumericSketch[T]) {
+	if other == s {
+		// the items of the other sketch are read while this one is updated
+		other = &NumericSketch[T]{
+			minK:      s.minK,
+			numLevels: s.numLevels,
+			n:         s.n,
+			levels:    s.getLevelsArray(),
+			items:     s.GetTotalItemsArray(),
+			minItem:   s.minItem,
+			maxItem:   s.maxItem,
+		}
+	}
+	myEmpty := s.IsEmpty()
+	myMin, myMax := s.minItem, s.maxItem
+	myMinK := s.minK
+	finalN := s.n + other.n
+
+	otherNumLevels := other.numLevels
+	otherLevelsArr := other.levels
+	otherItemsArr := other.items
+
+	// update this sketch with level0 items from the other sketch
+	for i := otherLevelsArr[0]; i < otherLevelsArr[1]; i++ {
+		s.updateItem(otherItemsArr[i])
+	}
+
+	// merge higher levels if they exist
+	if otherNumLevels > 1 {
+		s.numLevels, s.levels, s.items = mergeUpperLevels(s.k, s.m, s.numLevels, s.levels, s.items, s.isLevelZeroSorted,
+			otherNumLevels, otherLevelsArr, otherItemsArr, finalN, numericLess[T], s.random)
+	}
+
+	s.n = finalN
+	if other.IsEstimationMode() {
+		s.minK = min(myMinK, other.minK)
+	}
+	if myEmpty {
+		s.minItem = other.minItem
+		s.maxItem = other.maxItem
+	} else {
+		s.minItem = min(myMin, other.minItem)
+		s.maxItem = max(myMax, other.maxItem)
+	}
+}
+
+// numericLess is the ordering of the values of a NumericSketch, which ignores NaN.
+func numericLess[T float32 | float64](a T, b T) bool {
+	return a < b
+}
+
+// numericItemBytes returns the serialized size of a value: 4 bytes for float32, 8 for float64.
+func numericItemBytes[T float32 | float64]() int {
+	var item T
+	return int(unsafe.Sizeof(item))
+}
+
+// putNumericItems serializes the items in little-endian order to buf and returns it.
+func putNumericItems[T float32 | float64](buf []byte, items ...T) []byte {
+	if numericItemBytes[T]() == 4 {
+		for i, item := range items {
+			binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(float32(item)))
+		}
+	} else {
+		for i, item := range items {
+			binary.LittleEndian.PutUint64(buf[i*8:], math.Float64bits(float64(item)))
+		}
+	}
+	return buf
+}
+
+// getNumericItem deserializes the item at the given offset of the image.
+func getNumericItem[T float32 | float64](sl []byte, offset int) T {
+	if numericItemBytes[T]() == 4 {
+		return T(math.Float32frombits(binary.LittleEndian.Uint32(sl[offset:])))
+	}
+	return T(math.Float64frombits(binary.LittleEndian.Uint64(sl[offset:])))
+}
@@ -0,0 +1,260 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kll
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+// NumericSketchSortedView is the sorted view of a NumericSketch, its values sorted along with
+// their cumulative weights. A view returned by NumericSketch.GetSortedView is immutable, see
+// ItemsSketchSortedView.
+type NumericSketchSortedView[T float32 | float64] struct {
+	quantiles  []T
+	cumWeights []int64
+	totalN     uint64
+	maxItem    T
+	minItem    T
+	minK       uint16 //of the sketch, for the bounds of the quantiles
+}
+
+func newNumericSketchSortedView[T float32 | float64](sketch *NumericSketch[T], bufs *sortedViewBuffers[T]) *NumericSketchSortedView[T] {
+	srcLevels := sketch.levels
+	srcNumLevels := sketch.numLevels
+	if !sketch.isLevelZeroSorted {
+		slices.Sort(sketch.items[srcLevels[0]:srcLevels[1]])
+		sketch.isLevelZeroSorted = true
+	}
+	numQuantiles := srcLevels[srcNumLevels] - srcLevels[0]
+	bufs.quantiles = resize(bufs.quantiles, int(numQuantiles))
+	bufs.cumWeights = resize(bufs.cumWeights, int(numQuantiles))
+	quantiles := bufs.quantiles
+	cumWeights := bufs.cumWeights
+	copy(quantiles, sketch.items[srcLevels[0]:srcLevels[srcNumLevels]])
+
+	myLevels := make([]uint32, srcNumLevels+1)
+	offset := srcLevels[0]
+	dstLevel := uint8(0)
+	weight := int64(1)
+	for srcLevel := uint8(0); srcLevel < srcNumLevels; srcLevel++ {
+		fromIndex := srcLevels[srcLevel] - offset
+		toIndex := srcLevels[srcLevel+1] - offset // exclusive
+		if fromIndex < toIndex {                  // if equal, skip empty level
+			for i := fromIndex; i < toIndex; i++ {
+				cumWeights[i] = weight
+			}
+			myLevels[dstLevel] = fromIndex
+			myLevels[dstLevel+1] = toIndex
+			dstLevel++
+		}
+		weight *= 2
+	}
+	if dstLevel > 1 {
+		bufs.quantilesTmp = append(bufs.quantilesTmp[:0], quantiles...)
+		bufs.weightsTmp = append(bufs.weightsTmp[:0], cumWeights...)
+		numericTandemMergeSortRecursion(bufs.quantilesTmp, bufs.weightsTmp, quantiles, cumWeights, myLevels, 0, dstLevel)
+	}
+	convertToCumulative(cumWeights)
+	return &NumericSketchSortedView[T]{
+		quantiles:  quantiles,
+		cumWeights: cumWeights,
+		totalN:     sketch.n,
+		maxItem:    sketch.maxItem,
+		minItem:    sketch.minItem,
+		minK:       sketch.minK,
+	}
+}
+
+func (s *NumericSketchSortedView[T]) GetRank(item T, inclusive bool) (float64, error) {
+	if s.totalN == 0 {
+		return 0, errors.New("empty sketch")
+	}
+	if item != item {
+		return 0, errors.New("item must not be NaN")
+	}
+	// the number of quantiles < item (exclusive) or <= item (inclusive)
+	lo, hi := 0, len(s.quantiles)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if s.quantiles[mid] < item || (inclusive && s.quantiles[mid] == item) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo == 0 {
+		return 0, nil
+	}
+	return float64(s.cumWeights[lo-1]) / float64(s.totalN), nil
+}
+
+func (s *NumericSketchSortedView[T]) GetQuantile(rank float64, inclusive bool) (T, error) {
+	if s.totalN == 0 {
+		return T(math.NaN()), errors.New("empty sketch")
+	}
+	if err := checkNormalizedRankBounds(rank); err != nil {
+		return T(math.NaN()), err
+	}
+	return s.quantiles[s.getQuantileIndex(rank, inclusive)], nil
+}
+
+// GetQuantilesWithBounds returns the quantiles of the ranks along with their lower and upper bounds,
+// see ItemsSketchSortedView.GetQuantilesWithBounds.
+func (s *NumericSketchSortedView[T]) GetQuantilesWithBounds(ranks []float64, inclusive bool) (quantiles, lowerBounds, upperBounds []T, err error) {
+	if s.totalN == 0 {
+		return nil, nil, nil, errors.New("empty sketch")
+	}
+	quantiles = make([]T, len(ranks))
+	lowerBounds = make([]T, len(ranks))
+	upperBounds = make([]T, len(ranks))
+	for i, rank := range ranks {
+		if err := checkNormalizedRankBounds(rank); err != nil {
+			return nil, nil, nil, err
+		}
+		quantiles[i] = s.quantiles[s.getQuantileIndex(rank, inclusive)]
+		lowerBounds[i] = s.quantiles[s.getQuantileIndex(getRankLowerBound(rank, s.minK), inclusive)]
+		upperBounds[i] = s.quantiles[s.getQuantileIndex(getRankUpperBound(rank, s.minK), inclusive)]
+	}
+	return quantiles, lowerBounds, upperBounds, nil
+}
+
+func (s *NumericSketchSortedView[T]) GetPMF(splitPoints []T, inclusive bool) ([]float64, error) {
+	buckets, err := s.GetCDF(splitPoints, inclusive)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(buckets) - 1; i > 0; i-- {
+		buckets[i] -= buckets[i-1]
+	}
+	return buckets, nil
+}
+
+func (s *NumericSketchSortedView[T]) GetCDF(splitPoints []T, inclusive bool) ([]float64, error) {
+	if s.totalN == 0 {
+		return nil, errors.New("empty sketch")
+	}
+	if err := checkNumericSplitPoints(splitPoints); err != nil {
+		return nil, err
+	}
+	buckets := make([]float64, len(splitPoints)+1)
+	for i := range splitPoints {
+		buckets[i], _ = s.GetRank(splitPoints[i], inclusive)
+	}
+	buckets[len(splitPoints)] = 1.0
+	return buckets, nil
+}
+
+func (s *NumericSketchSortedView[T]) GetPartitionBoundaries(numEquallySized int, inclusive bool) (*ItemsSketchPartitionBoundaries[T], error) {
+	if s.totalN == 0 {
+		return nil, errors.New("empty sketch")
+	}
+	// the partitions start at the min item and end at the max item, the view itself is not modified
+	patched := *s
+	patched.quantiles = slices.Clone(s.quantiles)
+	patched.cumWeights = slices.Clone(s.cumWeights)
+	s = &patched
+	s.cumWeights[0] = 1
+	s.cumWeights[len(s.cumWeights)-1] = int64(s.totalN)
+	s.quantiles[0] = s.minItem
+	s.quantiles[len(s.quantiles)-1] = s.maxItem
+
+	evSpNormRanks, err := evenlySpacedDoubles(0, 1.0, numEquallySized+1)
+	if err != nil {
+		return nil, err
+	}
+	evSpQuantiles := make([]T, len(evSpNormRanks))
+	evSpNatRanks := make([]int64, len(evSpNormRanks))
+	for i := range evSpNormRanks {
+		index := s.getQuantileIndex(evSpNormRanks[i], inclusive)
+		evSpQuantiles[i] = s.quantiles[index]
+		evSpNatRanks[i] = s.cumWeights[index]
+	}
+	return newItemsSketchPartitionBoundaries[T](s.totalN, evSpQuantiles, evSpNatRanks, evSpNormRanks, s.maxItem, s.minItem, inclusive)
+}
+
+func (s *NumericSketchSortedView[T]) Iterator() *ItemsSketchSortedViewIterator[T] {
+	return newItemsSketchSortedViewIterator(s.quantiles, s.cumWeights)
+}
+
+// getQuantileIndex returns the index of the first cumulative weight >= (inclusive) or > (exclusive)
+// the natural rank, or the last index if there is none.
+func (s *NumericSketchSortedView[T]) getQuantileIndex(rank float64, inclusive bool) int {
+	naturalRank := getNaturalRank(rank, s.totalN, inclusive)
+	lo, hi := 0, len(s.cumWeights)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if s.cumWeights[mid] < naturalRank || (!inclusive && s.cumWeights[mid] == naturalRank) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo == len(s.cumWeights) {
+		return lo - 1
+	}
+	return lo
+}
+
+func numericTandemMergeSortRecursion[T float32 | float64](quantilesSrc []T, weightsSrc []int64, quantilesDst []T, weightsDst []int64, levels []uint32, startingLevel uint8, numLevels uint8) {
+	if numLevels == 1 {
+		return
+	}
+	numLevels1 := numLevels / 2
+	numLevels2 := numLevels - numLevels1
+	startingLevel1 := startingLevel
+	startingLevel2 := startingLevel + numLevels1
+	// swap roles of src and dst
+	numericTandemMergeSortRecursion(quantilesDst, weightsDst, quantilesSrc, weightsSrc, levels, startingLevel1, numLevels1)
+	numericTandemMergeSortRecursion(quantilesDst, weightsDst, quantilesSrc, weightsSrc, levels, startingLevel2, numLevels2)
+
+	toIndex1 := levels[startingLevel1+numLevels1] // exclusive
+	toIndex2 := levels[startingLevel2+numLevels2] // exclusive
+	iSrc1 := levels[startingLevel1]
+	iSrc2 := levels[startingLevel2]
+	iDst := iSrc1
+	for iSrc1 < toIndex1 && iSrc2 < toIndex2 {
+		if quantilesSrc[iSrc1] <= quantilesSrc[iSrc2] {
+			quantilesDst[iDst] = quantilesSrc[iSrc1]
+			weightsDst[iDst] = weightsSrc[iSrc1]
+			iSrc1++
+		} else {
+			quantilesDst[iDst] = quantilesSrc[iSrc2]
+			weightsDst[iDst] = weightsSrc[iSrc2]
+			iSrc2++
+		}
+		iDst++
+	}
+	if iSrc1 < toIndex1 {
+		copy(quantilesDst[iDst:], quantilesSrc[iSrc1:toIndex1])
+		copy(weightsDst[iDst:], weightsSrc[iSrc1:toIndex1])
+	} else if iSrc2 < toIndex2 {
+		copy(quantilesDst[iDst:], quantilesSrc[iSrc2:toIndex2])
+		copy(weightsDst[iDst:], weightsSrc[iSrc2:toIndex2])
+	}
+}
+
+func checkNumericSplitPoints[T float32 | float64](splitPoints []T) error {
+	for i := range splitPoints {
+		if splitPoints[i] != splitPoints[i] || (i > 0 && splitPoints[i-1] >= splitPoints[i]) {
+			return errors.New("split points must be unique, monotonically increasing and not NaN")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,671 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kll
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoublesSketch_Empty(t *testing.T) {
+	sketch, err := NewDoublesSketch(200)
+	assert.NoError(t, err)
+	assert.True(t, sketch.IsEmpty())
+	assert.False(t, sketch.IsEstimationMode())
+	assert.Equal(t, uint32(0), sketch.GetNumRetained())
+	_, err = sketch.GetMinItem()
+	assert.Error(t, err)
+	_, err = sketch.GetRank(0, true)
+	assert.Error(t, err)
+	q, err := sketch.GetQuantile(0.5, true)
+	assert.Error(t, err)
+	assert.True(t, math.IsNaN(q))
+	_, err = sketch.GetPMF([]float64{0}, true)
+	assert.Error(t, err)
+
+	sketch.Update(math.NaN())
+	assert.True(t, sketch.IsEmpty())
+
+	_, err = NewDoublesSketch(_MIN_K - 1)
+	assert.Error(t, err)
+}
+
+func TestDoublesSketch_OneValue(t *testing.T) {
+	sketch, err := NewDoublesSketch(200)
+	assert.NoError(t, err)
+	sketch.Update(1)
+	assert.Equal(t, uint64(1), sketch.GetN())
+	rank, err := sketch.GetRank(1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, rank)
+	rank, err = sketch.GetRank(1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, rank)
+	q, err := sketch.GetQuantile(0.5, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, q)
+	_, err = sketch.GetRank(math.NaN(), true)
+	assert.Error(t, err)
+}
+
+func TestDoublesSketch_ManyValuesEstimationMode(t *testing.T) {
+	sketch, err := NewDoublesSketch(200)
+	assert.NoError(t, err)
+	n := 1000000
+	for i := 0; i < n; i++ {
+		sketch.Update(float64(i))
+	}
+	assert.Equal(t, uint64(n), sketch.GetN())
+	assert.True(t, sketch.IsEstimationMode())
+	minV, err := sketch.GetMinItem()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, minV)
+	maxV, err := sketch.GetMaxItem()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(n-1), maxV)
+
+	eps := sketch.GetNormalizedRankError(false)
+	for i := 0; i < n; i += n / 100 {
+		rank, err := sketch.GetRank(float64(i), false)
+		assert.NoError(t, err)
+		assert.InDelta(t, float64(i)/float64(n), rank, eps)
+	}
+	q, err := sketch.GetQuantile(0.99, true)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.99*float64(n), q, eps*float64(n))
+
+	pmf, err := sketch.GetPMF([]float64{float64(n) / 2}, false)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.5, pmf[0], eps)
+	assert.InDelta(t, 0.5, pmf[1], eps)
+	_, err = sketch.GetCDF([]float64{2, 1}, true)
+	assert.Error(t, err)
+	_, err = sketch.GetCDF([]float64{math.NaN()}, true)
+	assert.Error(t, err)
+
+	weight := int64(0)
+	it := sketch.GetIterator()
+	for it.Next() {
+		weight += it.GetWeight()
+	}
+	assert.Equal(t, int64(n), weight)
+
+	boundaries, err := sketch.GetPartitionBoundaries(4, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(boundaries.GetBoundaries()))
+	assert.Equal(t, 0.0, boundaries.GetBoundaries()[0])
+	assert.Equal(t, float64(n-1), boundaries.GetBoundaries()[4])
+}
+
+func TestFloatsSketch_ManyValues(t *testing.T) {
+	sketch, err := NewFloatsSketch(100)
+	assert.NoError(t, err)
+	n := 100000
+	for i := n; i > 0; i-- {
+		sketch.Update(float32(i))
+	}
+	eps := sketch.GetNormalizedRankError(false)
+	rank, err := sketch.GetRank(float32(n/4), true)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.25, rank, eps)
+
+	sl, err := sketch.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, sketch.GetSerializedSizeBytes(), len(sl))
+	sketch2, err := NewFloatsSketchFromSlice(sl)
+	assert.NoError(t, err)
+	assert.Equal(t, sketch.GetN(), sketch2.GetN())
+	assert.Equal(t, sketch.GetNumRetained(), sketch2.GetNumRetained())
+	rank2, err := sketch2.GetRank(float32(n/4), true)
+	assert.NoError(t, err)
+	assert.Equal(t, rank, rank2)
+	sl2, err := sketch2.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, sl, sl2)
+}
+
+func TestDoublesSketch_Merge(t *testing.T) {
+	sketch1, err := NewDoublesSketch(200)
+	assert.NoError(t, err)
+	sketch2, err := NewDoublesSketch(100)
+	assert.NoError(t, err)
+	n := 10000
+	for i := 0; i < n; i++ {
+		sketch1.Update(float64(i))
+		sketch2.Update(float64(2*n - i - 1))
+	}
+	assert.NoError(t, sketch1.Merge(sketch2))
+	assert.Equal(t, uint64(2*n), sketch1.GetN())
+	assert.Equal(t, getNormalizedRankError(100, false), sketch1.GetNormalizedRankError(false))
+	minV, err := sketch1.GetMinItem()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, minV)
+	maxV, err := sketch1.GetMaxItem()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2*n-1), maxV)
+	median, err := sketch1.GetQuantile(0.5, true)
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(n), median, float64(n)*2*sketch1.GetNormalizedRankError(false))
+
+	assert.NoError(t, sketch1.Merge(sketch1))
+	assert.Equal(t, uint64(4*n), sketch1.GetN())
+	assert.Error(t, sketch1.Merge(nil))
+	assert.Equal(t, uint64(4*n), sketch1.GetN())
+
+	empty, err := NewDoublesSketch(200)
+	assert.NoError(t, err)
+	assert.NoError(t, empty.Merge(sketch2))
+	assert.Equal(t, sketch2.GetN(), empty.GetN())
+	minV, err = empty.GetMinItem()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(n), minV)
+}
+
+func TestDoublesSketch_Serialization(t *testing.T) {
+	sketch, err := NewDoublesSketch(200)
+	assert.NoError(t, err)
+	sl, err := sketch.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2, 1, 15, 1, 200, 0, 8, 0}, sl)
+	sketch2, err := NewDoublesSketchFromSlice(sl)
+	assert.NoError(t, err)
+	assert.True(t, sketch2.IsEmpty())
+
+	sketch.Update(1)
+	sl, err = sketch.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2, 2, 15, 4, 200, 0, 8, 0, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f}, sl)
+	sketch2, err = NewDoublesSketchFromSlice(sl)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), sketch2.GetN())
+	minV, err := sketch2.GetMinItem()
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, minV)
+
+	floats, err := NewFloatsSketch(300)
+	assert.NoError(t, err)
+	floats.Update(1)
+	sl, err = floats.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2, 2, 15, 4, 0x2c, 1, 8, 0, 0, 0, 0x80, 0x3f}, sl)
+	floats2, err := NewFloatsSketchFromSlice(sl)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(300), floats2.GetK())
+
+	// a full image is truncated
+	for i := 0; i < 1000; i++ {
+		sketch.Update(float64(i))
+	}
+	sl, err = sketch.ToSlice()
+	assert.NoError(t, err)
+	_, err = NewDoublesSketchFromSlice(sl[:len(sl)-1])
+	assert.Error(t, err)
+}
+
+func TestDoublesSketch_UpdatableSerialization(t *testing.T) {
+	sketch, err := NewDoublesSketch(200)
+	assert.NoError(t, err)
+	sl, err := sketch.ToUpdatableSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, sketch.GetUpdatableSerializedSizeBytes(), len(sl))
+	assert.Equal(t, []byte{5, 3, 15, 1, 200, 0, 8, 0}, sl[:8])
+	// the min and max of an empty sketch are NaN
+	assert.True(t, math.IsNaN(math.Float64frombits(binary.LittleEndian.Uint64(sl[_DATA_START_ADR+8:]))))
+	sketch2, err := NewDoublesSketchFromSlice(sl)
+	assert.NoError(t, err)
+	assert.True(t, sketch2.IsEmpty())
+
+	for _, n := range []int{1, 100, 10000} {
+		for i := sketch.GetN(); i < uint64(n); i++ {
+			sketch.Update(float64(i))
+		}
+		sl, err = sketch.ToUpdatableSlice()
+		assert.NoError(t, err)
+		assert.Equal(t, sketch.GetUpdatableSerializedSizeBytes(), len(sl))
+		sketch2, err = NewDoublesSketchFromSlice(sl)
+		assert.NoError(t, err)
+		assert.Equal(t, sketch.GetN(), sketch2.GetN())
+		assert.Equal(t, sketch.levels, sketch2.levels)
+		assert.Equal(t, sketch.items, sketch2.items)
+		sl2, err := sketch2.ToUpdatableSlice()
+		assert.NoError(t, err)
+		assert.Equal(t, sl, sl2)
+
+		// the reloaded sketch keeps on being updated
+		sketch2.Update(-1)
+		minV, err := sketch2.GetMinItem()
+		assert.NoError(t, err)
+		assert.Equal(t, -1.0, minV)
+		maxV, err := sketch2.GetMaxItem()
+		assert.NoError(t, err)
+		assert.Equal(t, float64(n-1), maxV)
+	}
+
+	floats, err := NewFloatsSketch(200)
+	assert.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		floats.Update(float32(i))
+	}
+	sl, err = floats.ToUpdatableSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, floats.GetUpdatableSerializedSizeBytes(), len(sl))
+	floats2, err := NewFloatsSketchFromSlice(sl)
+	assert.NoError(t, err)
+	assert.Equal(t, floats.GetN(), floats2.GetN())
+	assert.Equal(t, floats.items, floats2.items)
+}
+
+func TestDoublesSketch_SameImageAsItemsSketch(t *testing.T) {
+	// the compactions of both sketches flip the same coins
+	doubles, err := NewDoublesSketch(200, WithRandSeed(1))
+	assert.NoError(t, err)
+	items, err := NewItemsSketch[float64](200, Float64ItemsSketchOp{}, WithRandSeed(1))
+	assert.NoError(t, err)
+	for i := 0; i < 100000; i++ {
+		v := float64((i * 7919) % 100003)
+		doubles.Update(v)
+		items.Update(v)
+	}
+	other, err := NewDoublesSketch(200, WithRandSeed(2))
+	assert.NoError(t, err)
+	otherItems, err := NewItemsSketch[float64](200, Float64ItemsSketchOp{}, WithRandSeed(2))
+	assert.NoError(t, err)
+	for i := 0; i < 5000; i++ {
+		other.Update(float64(i))
+		otherItems.Update(float64(i))
+	}
+	assert.NoError(t, doubles.Merge(other))
+	assert.NoError(t, items.Merge(otherItems))
+
+	sl1, err := doubles.ToSlice()
+	assert.NoError(t, err)
+	sl2, err := items.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, sl2, sl1)
+	sl1, err = doubles.ToUpdatableSlice()
+	assert.NoError(t, err)
+	sl2, err = items.ToUpdatableSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, sl2, sl1)
+
+	// an image of the items sketch of float64 is an image of a DoublesSketch
+	sketch, err := NewDoublesSketchFromSlice(sl2)
+	assert.NoError(t, err)
+	assert.Equal(t, items.GetN(), sketch.GetN())
+}
+
+func TestDoublesSketch_UpdateWeighted(t *testing.T) {
+	sketch, err := NewDoublesSketch(_DEFAULT_K, WithRandSeed(1))
+	assert.NoError(t, err)
+	assert.Error(t, sketch.UpdateWeighted(1, 0))
+	assert.Error(t, sketch.UpdateWeighted(1, 1<<61))
+	assert.NoError(t, sketch.UpdateWeighted(math.NaN(), 10))
+	assert.True(t, sketch.IsEmpty())
+
+	// fits in level 0
+	assert.NoError(t, sketch.UpdateWeighted(2, 3))
+	assert.Equal(t, uint64(3), sketch.GetN())
+	assert.Equal(t, uint32(3), sketch.GetNumRetained())
+	// merged from a sketch of several levels
+	assert.NoError(t, sketch.UpdateWeighted(1, 1000))
+	assert.NoError(t, sketch.UpdateWeighted(3, 1<<40+5))
+	assert.Equal(t, uint64(3+1000+1<<40+5), sketch.GetN())
+	minV, err := sketch.GetMinItem()
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, minV)
+	maxV, err := sketch.GetMaxItem()
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, maxV)
+	rank, err := sketch.GetRank(2, true)
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(1003)/float64(sketch.GetN()), rank, sketch.GetNormalizedRankError(false))
+
+	// the weighted updates of both sketches flip the same coins
+	items, err := NewItemsSketch[float64](_DEFAULT_K, Float64ItemsSketchOp{}, WithRandSeed(1))
+	assert.NoError(t, err)
+	for _, update := range []struct {
+		item   float64
+		weight uint64
+	}{{2, 3}, {1, 1000}, {3, 1<<40 + 5}} {
+		assert.NoError(t, items.UpdateWeighted(update.item, update.weight))
+	}
+	for i := 0; i < 1000; i++ {
+		v, weight := float64((i*7919)%1009), uint64(1+(i*31)%700)
+		assert.NoError(t, sketch.UpdateWeighted(v, weight))
+		assert.NoError(t, items.UpdateWeighted(v, weight))
+	}
+	sl1, err := sketch.ToSlice()
+	assert.NoError(t, err)
+	sl2, err := items.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, sl2, sl1)
+}
+
+func TestKllSketch_UpdateWeightedOverflow(t *testing.T) {
+	doubles, err := NewDoublesSketch(_DEFAULT_K)
+	assert.NoError(t, err)
+	floats, err := NewFloatsSketch(_DEFAULT_K)
+	assert.NoError(t, err)
+	items, err := NewItemsSketch[string](_DEFAULT_K, StringItemsSketchOp{})
+	assert.NoError(t, err)
+	maxWeight := uint64(1)<<_MAX_NUM_LEVELS - 1
+	for i := 0; i < 8; i++ {
+		assert.NoError(t, doubles.UpdateWeighted(float64(i), maxWeight))
+		assert.NoError(t, floats.UpdateWeighted(float32(i), maxWeight))
+		assert.NoError(t, items.UpdateWeighted(strconv.Itoa(i), maxWeight))
+	}
+	n := 8 * maxWeight
+	assert.Equal(t, n, doubles.GetN())
+	assert.Equal(t, n, floats.GetN())
+	assert.Equal(t, n, items.GetN())
+
+	// n is 2^64 - 8, 7 more fit and 8 overflow, leaving the sketches unchanged
+	assert.ErrorContains(t, doubles.UpdateWeighted(0, 8), "overflow")
+	assert.ErrorContains(t, floats.UpdateWeighted(0, 8), "overflow")
+	assert.ErrorContains(t, items.UpdateWeighted("0", 8), "overflow")
+	assert.Equal(t, n, doubles.GetN())
+	assert.Equal(t, n, floats.GetN())
+	assert.Equal(t, n, items.GetN())
+	assert.NoError(t, doubles.UpdateWeighted(0, 7))
+	assert.NoError(t, floats.UpdateWeighted(0, 7))
+	assert.NoError(t, items.UpdateWeighted("0", 7))
+	assert.Equal(t, uint64(math.MaxUint64), doubles.GetN())
+	assert.Equal(t, uint64(math.MaxUint64), floats.GetN())
+	assert.Equal(t, uint64(math.MaxUint64), items.GetN())
+	assert.Error(t, doubles.UpdateWeighted(0, 1))
+}
+
+func TestDoublesSketch_Gob(t *testing.T) {
+	sketch, err := NewDoublesSketch(200)
+	assert.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		sketch.Update(float64(i))
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(sketch))
+	var decoded DoublesSketch
+	assert.NoError(t, gob.NewDecoder(&buf).Decode(&decoded))
+	assert.Equal(t, sketch.GetN(), decoded.GetN())
+	q1, err := sketch.GetQuantile(0.3, true)
+	assert.NoError(t, err)
+	q2, err := decoded.GetQuantile(0.3, true)
+	assert.NoError(t, err)
+	assert.Equal(t, q1, q2)
+
+	sketch.Reset()
+	assert.True(t, sketch.IsEmpty())
+}
+
+func FuzzDoublesSketchFromSlice(f *testing.F) {
+	fuzzNumericSketchFromSlice[float64](f)
+}
+
+func FuzzFloatsSketchFromSlice(f *testing.F) {
+	fuzzNumericSketchFromSlice[float32](f)
+}
+
+func FuzzDoublesSketchReadFrom(f *testing.F) {
+	fuzzNumericSketchReadFrom[float64](f)
+}
+
+func FuzzFloatsSketchReadFrom(f *testing.F) {
+	fuzzNumericSketchReadFrom[float32](f)
+}
+
+// addNumericFuzzSeeds adds the compact and updatable images of sketches of a few sizes to the corpus.
+func addNumericFuzzSeeds[T float32 | float64](f *testing.F) {
+	for _, n := range []int{0, 1, 10, 1000} {
+		sketch, err := newNumericSketch[T](_MIN_K)
+		assert.NoError(f, err)
+		for i := 0; i < n; i++ {
+			sketch.Update(T(i))
+		}
+		slc, err := sketch.ToSlice()
+		assert.NoError(f, err)
+		f.Add(slc)
+		slc, err = sketch.ToUpdatableSlice()
+		assert.NoError(f, err)
+		f.Add(slc)
+	}
+}
+
+func fuzzNumericSketchFromSlice[T float32 | float64](f *testing.F) {
+	addNumericFuzzSeeds[T](f)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		sketch, err := newNumericSketchFromSlice[T](b)
+		if err != nil {
+			if !errors.Is(err, ErrCorruptImage) && !errors.Is(err, ErrUnsupportedVersion) {
+				t.Fatalf("untyped error: %v", err)
+			}
+			return
+		}
+		if _, err := sketch.ToSlice(); err != nil {
+			t.Fatal(err)
+		}
+		if sketch.IsEmpty() {
+			return
+		}
+		if _, err := sketch.GetQuantile(0.5, true); err != nil {
+			t.Fatal(err)
+		}
+		other, err := newNumericSketch[T](_MIN_K)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := other.Merge(sketch); err != nil {
+			t.Fatal(err)
+		}
+		sketch.Update(0)
+	})
+}
+
+func fuzzNumericSketchReadFrom[T float32 | float64](f *testing.F) {
+	addNumericFuzzSeeds[T](f)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		sketch, err := newNumericSketch[T](_MIN_K)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkReadFrom(t, b, sketch.ReadFrom, func(image []byte) ([]byte, error) {
+			decoded, err := newNumericSketchFromSlice[T](image)
+			if err != nil {
+				return nil, err
+			}
+			return decoded.ToSlice()
+		}, sketch.ToSlice)
+	})
+}
+
+func BenchmarkKllUpdate(b *testing.B) {
+	b.Run("DoublesSketch", func(b *testing.B) {
+		sketch, _ := NewDoublesSketch(200)
+		for i := 0; i < b.N; i++ {
+			sketch.Update(float64(i))
+		}
+	})
+	b.Run("ItemsSketch float64", func(b *testing.B) {
+		sketch, _ := NewItemsSketch[float64](200, Float64ItemsSketchOp{})
+		for i := 0; i < b.N; i++ {
+			sketch.Update(float64(i))
+		}
+	})
+}
+
+func TestDoublesSketch_WriteToReadFrom(t *testing.T) {
+	var images [][]byte
+	var buf bytes.Buffer
+	// the images are written one after another into a single stream
+	for _, n := range []int{0, 1, 1000, 100000} {
+		sketch, err := NewDoublesSketch(200)
+		assert.NoError(t, err)
+		for i := 0; i < n; i++ {
+			sketch.Update(float64(i))
+		}
+		slc, err := sketch.ToSlice()
+		assert.NoError(t, err)
+		written, err := sketch.WriteTo(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(slc)), written)
+		updatable, err := sketch.ToUpdatableSlice()
+		assert.NoError(t, err)
+		buf.Write(updatable)
+		images = append(images, slc, updatable)
+	}
+
+	for i, slc := range images {
+		var decoded NumericSketch[float64]
+		read, err := decoded.ReadFrom(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(slc)), read)
+		decodedSlc, err := decoded.ToSlice()
+		assert.NoError(t, err)
+		// every other image is updatable, it decodes into the sketch of the previous compact image
+		assert.Equal(t, images[i-i%2], decodedSlc)
+	}
+	var decoded NumericSketch[float64]
+	read, err := decoded.ReadFrom(&buf)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, int64(0), read)
+
+	for _, slc := range images[2:] {
+		for _, size := range []int{4, len(slc) - 1} {
+			_, err = decoded.ReadFrom(bytes.NewReader(slc[:size]))
+			assert.Equal(t, io.ErrUnexpectedEOF, err)
+		}
+	}
+}
+
+func TestDoublesSketch_Downsample(t *testing.T) {
+	sketch, err := NewDoublesSketch(_DEFAULT_K, WithRandSeed(1))
+	assert.NoError(t, err)
+	items, err := NewItemsSketch[float64](_DEFAULT_K, Float64ItemsSketchOp{}, WithRandSeed(1))
+	assert.NoError(t, err)
+	for i := 0; i < 100000; i++ {
+		sketch.Update(float64(i))
+		items.Update(float64(i))
+	}
+	assert.Error(t, sketch.Downsample(_DEFAULT_K+1))
+	assert.NoError(t, sketch.Downsample(50))
+	assert.NoError(t, items.Downsample(50))
+	assert.Equal(t, uint16(50), sketch.GetK())
+	assert.Equal(t, getNormalizedRankError(50, false), sketch.GetNormalizedRankError(false))
+	assert.Equal(t, uint64(100000), sketch.GetN())
+
+	// both sketches flip the same coins
+	sl1, err := sketch.ToSlice()
+	assert.NoError(t, err)
+	sl2, err := items.ToSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, sl2, sl1)
+}
+
+func TestDoublesSketch_RankAndQuantileBounds(t *testing.T) {
+	sketch, err := NewDoublesSketch(_DEFAULT_K)
+	assert.NoError(t, err)
+	_, err = sketch.GetQuantileUpperBound(0.5)
+	assert.Error(t, err)
+	for i := 0; i < 100000; i++ {
+		sketch.Update(float64(i))
+	}
+	eps := sketch.GetNormalizedRankError(false)
+	assert.Equal(t, 0.99-eps, sketch.GetRankLowerBound(0.99))
+	assert.Equal(t, 1.0, sketch.GetRankUpperBound(0.99))
+
+	ranks := []float64{0.5, 0.99}
+	view, err := sketch.GetSortedView()
+	assert.NoError(t, err)
+	quantiles, lowerBounds, upperBounds, err := view.GetQuantilesWithBounds(ranks, true)
+	assert.NoError(t, err)
+	for i, rank := range ranks {
+		quantile, err := sketch.GetQuantile(rank, true)
+		assert.NoError(t, err)
+		assert.Equal(t, quantile, quantiles[i])
+		lb, err := sketch.GetQuantileLowerBound(rank)
+		assert.NoError(t, err)
+		assert.Equal(t, lb, lowerBounds[i])
+		ub, err := sketch.GetQuantileUpperBound(rank)
+		assert.NoError(t, err)
+		assert.Equal(t, ub, upperBounds[i])
+		assert.LessOrEqual(t, lb, rank*100000)
+		assert.GreaterOrEqual(t, ub, rank*100000-1)
+	}
+	assert.Equal(t, 99999.0, upperBounds[1])
+}
+
+func TestDoublesSketch_SortedViewImmutable(t *testing.T) {
+	sketch, err := NewDoublesSketch(_DEFAULT_K)
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		sketch.Update(float64(i))
+	}
+	view, err := sketch.GetSortedView()
+	assert.NoError(t, err)
+	minV, err := view.GetQuantile(0, true)
+	assert.NoError(t, err)
+	median, err := view.GetQuantile(0.5, true)
+	assert.NoError(t, err)
+	_, err = view.GetPartitionBoundaries(10, true)
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		sketch.Update(float64(-i))
+		_, err := sketch.GetRank(0, true)
+		assert.NoError(t, err)
+	}
+	q, err := view.GetQuantile(0, true)
+	assert.NoError(t, err)
+	assert.Equal(t, minV, q)
+	q, err = view.GetQuantile(0.5, true)
+	assert.NoError(t, err)
+	assert.Equal(t, median, q)
+	assert.NotSame(t, &view.quantiles[0], &sketch.sortedView.quantiles[0])
+}
+
+func TestDoublesSketch_SortedViewBuffersAllocs(t *testing.T) {
+	sketch, err := NewDoublesSketch(_DEFAULT_K)
+	assert.NoError(t, err)
+	for i := 0; i < 100000; i++ {
+		sketch.Update(float64(i))
+	}
+	_, err = sketch.GetQuantile(0.5, true)
+	assert.NoError(t, err)
+	item := 0.0
+	queryAllocs := testing.AllocsPerRun(1000, func() {
+		item++
+		sketch.Update(item)
+		_, _ = sketch.GetQuantile(0.5, true)
+	})
+	viewAllocs := testing.AllocsPerRun(1000, func() {
+		item++
+		sketch.Update(item)
+		_, _ = sketch.GetSortedView()
+	})
+	// only the view and its levels are allocated when the buffers are reused
+	assert.Equal(t, 2.0, queryAllocs)
+	assert.GreaterOrEqual(t, viewAllocs, queryAllocs+2)
+	assert.Zero(t, testing.AllocsPerRun(100, func() { _, _ = sketch.GetSortedView() }))
+}
@@ -17,7 +17,19 @@
 
 package kll
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	// ErrCorruptImage is wrapped by the errors returned when deserializing an image that is not a
+	// valid KLL sketch image.
+	ErrCorruptImage = errors.New("possible corruption")
+	// ErrUnsupportedVersion is wrapped by the errors returned when deserializing a KLL image whose
+	// serial version and preamble size are not supported.
+	ErrUnsupportedVersion = errors.New("unsupported serial version")
+)
 
 const (
 	_PREAMBLE_INTS_BYTE_ADR = 0
@@ -73,7 +85,7 @@ func getEmptyFlag(mem []byte) bool {
 }
 
 func getK(mem []byte) uint16 {
-	return uint16(mem[_K_SHORT_ADR]) & 0xFFFF
+	return binary.LittleEndian.Uint16(mem[_K_SHORT_ADR : _K_SHORT_ADR+2])
 }
 
 func getM(mem []byte) uint8 {
@@ -17,6 +17,8 @@
 
 package kll
 
+import "fmt"
+
 type sketchStructure struct {
 	preInts int
 	serVer  int
@@ -33,19 +35,33 @@ func (s sketchStructure) getPreInts() int { return s.preInts }
 
 func (s sketchStructure) getSerVer() int { return s.serVer }
 
-func getSketchStructure(preInts, serVer int) sketchStructure {
+func getSketchStructure(preInts, serVer int) (sketchStructure, error) {
 	if preInts == _PREAMBLE_INTS_EMPTY_SINGLE {
 		if serVer == _SERIAL_VERSION_EMPTY_FULL {
-			return _COMPACT_EMPTY
+			return _COMPACT_EMPTY, nil
 		} else if serVer == _SERIAL_VERSION_SINGLE {
-			return _COMPACT_SINGLE
+			return _COMPACT_SINGLE, nil
 		}
 	} else if preInts == _PREAMBLE_INTS_FULL {
 		if serVer == _SERIAL_VERSION_EMPTY_FULL {
-			return _COMPACT_FULL
+			return _COMPACT_FULL, nil
 		} else if serVer == _SERIAL_VERSION_UPDATABLE {
-			return _UPDATABLE
+			return _UPDATABLE, nil
 		}
 	}
-	panic("Invalid preamble ints and serial version combo")
+	return sketchStructure{}, fmt.Errorf("%w: invalid preamble ints and serial version combo: %d, %d", ErrUnsupportedVersion, preInts, serVer)
+}
+
+func (s sketchStructure) String() string {
+	switch s {
+	case _COMPACT_EMPTY:
+		return "COMPACT_EMPTY"
+	case _COMPACT_SINGLE:
+		return "COMPACT_SINGLE"
+	case _COMPACT_FULL:
+		return "COMPACT_FULL"
+	case _UPDATABLE:
+		return "UPDATABLE"
+	}
+	return fmt.Sprintf("UNKNOWN(%d, %d)", s.preInts, s.serVer)
 }
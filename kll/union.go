@@ -0,0 +1,267 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kll
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// _UNION_BATCH_SIZE is the number of sketches buffered by a Union before they are merged.
+const _UNION_BATCH_SIZE = 64
+
+// WithWorkers sets the maximum number of goroutines merging sketches at once. Defaults to GOMAXPROCS.
+// It is only used by NewUnion.
+func WithWorkers(workers int) SketchOption {
+	return func(o *sketchOptions) {
+		o.workers = workers
+	}
+}
+
+// Union merges many ItemsSketch, such as the sketches of the shards of a stream, into a sketch of its
+// k, whose rank error is the one of the smallest k of the sketches, as if they were merged one after
+// the other with Merge.
+//
+// The sketches are merged as a tree: they are buffered, then merged by pairs, the results merged by
+// pairs and so on, each round of merges being run in parallel on a pool of goroutines.
+//
+// All the methods of Union are safe for concurrent use, the sketches can be fed from many goroutines.
+type Union[C comparable] struct {
+	mu     sync.Mutex
+	merged *sync.Cond // signaled when a batch of sketches is merged
+
+	k             uint16
+	itemsSketchOp ItemSketchOp[C]
+	random        *rand.Rand
+	workers       int
+
+	sketches []*ItemsSketch[C] // owned by the union, of its k
+	merging  int               // number of batches being merged
+}
+
+// NewUnion returns a new empty union merging sketches into a sketch of the given k.
+//
+//   - k, the k of the result, must be >= 8 and <= 65535.
+//   - itemsSketchOp, the ItemSketchOp of the items.
+//   - opts, optional parameters such as WithRandSeed and WithWorkers.
+func NewUnion[C comparable](k uint16, itemsSketchOp ItemSketchOp[C], opts ...SketchOption) (*Union[C], error) {
+	if k < _MIN_K || k > _MAX_K {
+		return nil, fmt.Errorf("k must be >= %d and <= %d: %d", _MIN_K, _MAX_K, k)
+	}
+	options := newSketchOptions(opts)
+	if options.workers == 0 {
+		options.workers = runtime.GOMAXPROCS(0)
+	}
+	if options.workers < 1 {
+		return nil, fmt.Errorf("workers must be > 0: %d", options.workers)
+	}
+	u := &Union[C]{
+		k:             k,
+		itemsSketchOp: itemsSketchOp,
+		random:        options.random,
+		workers:       options.workers,
+	}
+	u.merged = sync.NewCond(&u.mu)
+	return u, nil
+}
+
+// Update merges the given sketch into the union. The sketch is copied, it can be updated once Update
+// returns.
+func (u *Union[C]) Update(sketch *ItemsSketch[C]) error {
+	if sketch == nil {
+		return errors.New("nil sketch")
+	}
+	if sketch.IsEmpty() {
+		return nil
+	}
+	u.mu.Lock()
+	cp := u.newSketch()
+	u.mu.Unlock()
+	if err := cp.Merge(sketch); err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	u.sketches = append(u.sketches, cp)
+	if len(u.sketches) < _UNION_BATCH_SIZE {
+		u.mu.Unlock()
+		return nil
+	}
+	batch := u.sketches
+	u.sketches = nil
+	u.merging++
+	u.mu.Unlock()
+
+	batch, err := mergeTree(batch, u.workers)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	// after a failed merge the sketches of the batch are kept to be merged again
+	u.sketches = append(u.sketches, batch...)
+	u.merging--
+	u.merged.Broadcast()
+	return err
+}
+
+// GetResult returns a new sketch of the k of the union holding all the sketches merged so far.
+// It waits for the batches being merged by Update and blocks Update while it merges the buffered sketches.
+func (u *Union[C]) GetResult() (*ItemsSketch[C], error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for u.merging > 0 {
+		u.merged.Wait()
+	}
+	result := u.newSketch()
+	if len(u.sketches) == 0 {
+		return result, nil
+	}
+	var err error
+	if u.sketches, err = mergeTree(u.sketches, u.workers); err != nil {
+		return nil, err
+	}
+	if err := result.Merge(u.sketches[0]); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Reset resets the union to empty.
+func (u *Union[C]) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for u.merging > 0 {
+		u.merged.Wait()
+	}
+	u.sketches = nil
+}
+
+// newSketch returns a new empty sketch of the k of the union, whose coin flips are seeded by the
+// source of the union if it has one. The lock must be held.
+func (u *Union[C]) newSketch() *ItemsSketch[C] {
+	sketch, _ := NewItemsSketch[C](u.k, u.itemsSketchOp) // k is checked by NewUnion
+	sketch.random = newDerivedRandom(u.random)
+	return sketch
+}
+
+// MergeAll merges the other sketches into this one, like Merge, but as a tree run in parallel on up to
+// GOMAXPROCS goroutines, see Union. The other sketches are not modified.
+func (s *ItemsSketch[C]) MergeAll(others ...*ItemsSketch[C]) error {
+	nonEmpty := make([]*ItemsSketch[C], 0, len(others))
+	for _, other := range others {
+		if other == nil {
+			return errors.New("nil sketch")
+		}
+		if !other.IsEmpty() {
+			nonEmpty = append(nonEmpty, other)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil
+	}
+	workers := runtime.GOMAXPROCS(0)
+
+	// the first round merges the sketches by pairs into new sketches, later ones merge these in place
+	leaves := make([]*ItemsSketch[C], (len(nonEmpty)+1)/2)
+	for i := range leaves {
+		leaf, err := NewItemsSketch[C](s.k, s.itemsSketchOp)
+		if err != nil {
+			return err
+		}
+		leaf.m = s.m
+		leaf.random = newDerivedRandom(s.random)
+		leaves[i] = leaf
+	}
+	err := runOnWorkers(len(leaves), workers, func(i int) error {
+		for _, other := range nonEmpty[2*i : min(2*i+2, len(nonEmpty))] {
+			if err := leaves[i].Merge(other); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	merged, err := mergeTree(leaves, workers)
+	if err != nil {
+		return err
+	}
+	return s.Merge(merged[0])
+}
+
+// mergeTree merges the sketches by pairs, the right sketch of a pair into the left one, in rounds run
+// on up to workers goroutines until a single sketch is left. The sketches are modified. A failed merge
+// leaves its pair unchanged, the sketches returned hold all the items: the single merged sketch, or
+// the sketches left by the failed round along with its error.
+func mergeTree[C comparable](sketches []*ItemsSketch[C], workers int) ([]*ItemsSketch[C], error) {
+	for len(sketches) > 1 {
+		failed := make([]bool, len(sketches)/2)
+		err := runOnWorkers(len(failed), workers, func(i int) error {
+			err := sketches[2*i].Merge(sketches[2*i+1])
+			failed[i] = err != nil
+			return err
+		})
+		// keep the left sketches of the pairs, the right ones of the failed merges and the odd one out
+		next := sketches[:0]
+		for i := 0; i < len(sketches); i += 2 {
+			next = append(next, sketches[i])
+			if i/2 < len(failed) && failed[i/2] {
+				next = append(next, sketches[i+1])
+			}
+		}
+		sketches = next
+		if err != nil {
+			return sketches, err
+		}
+	}
+	return sketches, nil
+}
+
+// runOnWorkers runs task for 0 <= i < n on up to workers goroutines, and returns the errors of the tasks.
+func runOnWorkers(n int, workers int, task func(i int) error) error {
+	errs := make([]error, n)
+	tasks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				errs[i] = task(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// newDerivedRandom returns a new source of coin flips seeded by random, or nil if random is nil, so that
+// sketches merged concurrently do not share a source while staying reproducible.
+func newDerivedRandom(random *rand.Rand) *rand.Rand {
+	if random == nil {
+		return nil
+	}
+	return rand.New(rand.NewSource(random.Int63()))
+}
@@ -0,0 +1,205 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kll
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newShards returns numShards sketches of k of the values 0 to numShards*shardSize - 1, dealt round-robin.
+func newShards(t *testing.T, numShards int, shardSize int, k uint16) []*ItemsSketch[int64] {
+	shards := make([]*ItemsSketch[int64], numShards)
+	for i := range shards {
+		shard, err := NewItemsSketch[int64](k, Int64ItemsSketchOp{})
+		assert.NoError(t, err)
+		for j := 0; j < shardSize; j++ {
+			shard.Update(int64(j*numShards + i))
+		}
+		shards[i] = shard
+	}
+	return shards
+}
+
+func checkUniformResult(t *testing.T, result *ItemsSketch[int64], n int64) {
+	assert.Equal(t, uint64(n), result.GetN())
+	minV, err := result.GetMinItem()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), minV)
+	maxV, err := result.GetMaxItem()
+	assert.NoError(t, err)
+	assert.Equal(t, n-1, maxV)
+	for _, rank := range []float64{0.01, 0.25, 0.5, 0.75, 0.99} {
+		quantile, err := result.GetQuantile(rank, true)
+		assert.NoError(t, err)
+		assert.InDelta(t, rank, float64(quantile)/float64(n), 2*result.GetNormalizedRankError(false))
+	}
+}
+
+func TestUnion_Empty(t *testing.T) {
+	_, err := NewUnion[int64](_MIN_K-1, Int64ItemsSketchOp{})
+	assert.Error(t, err)
+	_, err = NewUnion[int64](_DEFAULT_K, Int64ItemsSketchOp{}, WithWorkers(-1))
+	assert.Error(t, err)
+
+	union, err := NewUnion[int64](_DEFAULT_K, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	assert.Error(t, union.Update(nil))
+	empty, err := NewItemsSketch[int64](_DEFAULT_K, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	assert.NoError(t, union.Update(empty))
+	result, err := union.GetResult()
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+	assert.Equal(t, _DEFAULT_K, result.GetK())
+}
+
+func TestUnion_ConcurrentUpdates(t *testing.T) {
+	numShards, shardSize := 1000, 100
+	shards := newShards(t, numShards, shardSize, _DEFAULT_K)
+	union, err := NewUnion[int64](_DEFAULT_K, Int64ItemsSketchOp{}, WithWorkers(4))
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < numShards; i += 8 {
+				assert.NoError(t, union.Update(shards[i]))
+			}
+		}(g)
+	}
+	// results can be taken while the union is fed
+	_, err = union.GetResult()
+	assert.NoError(t, err)
+	wg.Wait()
+
+	result, err := union.GetResult()
+	assert.NoError(t, err)
+	assert.Equal(t, _DEFAULT_K, result.GetK())
+	assert.Equal(t, getNormalizedRankError(_DEFAULT_K, false), result.GetNormalizedRankError(false))
+	checkUniformResult(t, result, int64(numShards*shardSize))
+
+	// the result is a copy, the union keeps on merging
+	result.Update(-1)
+	assert.NoError(t, union.Update(shards[0]))
+	result, err = union.GetResult()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(numShards*shardSize+shardSize), result.GetN())
+
+	union.Reset()
+	result, err = union.GetResult()
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+}
+
+func TestUnion_FailedMerge(t *testing.T) {
+	numShards, shardSize := _UNION_BATCH_SIZE, 100
+	shards := newShards(t, numShards, shardSize, _DEFAULT_K)
+	union, err := NewUnion[int64](_DEFAULT_K, Int64ItemsSketchOp{}, WithWorkers(4))
+	assert.NoError(t, err)
+	for _, shard := range shards[:numShards-1] {
+		assert.NoError(t, union.Update(shard))
+	}
+	// a buffered sketch without its min and max fails its merge when the batch is merged
+	broken := union.sketches[5]
+	minItem := broken.minItem
+	broken.minItem = nil
+	assert.Error(t, union.Update(shards[numShards-1]))
+	// the other pairs of the first round are merged, the sketches of the batch are kept
+	assert.Len(t, union.sketches, numShards/2+1)
+	assert.Contains(t, union.sketches, broken)
+	n := uint64(0)
+	for _, sketch := range union.sketches {
+		n += sketch.GetN()
+	}
+	assert.Equal(t, uint64(numShards*shardSize), n)
+	_, err = union.GetResult()
+	assert.Error(t, err)
+
+	broken.minItem = minItem
+	result, err := union.GetResult()
+	assert.NoError(t, err)
+	checkUniformResult(t, result, int64(numShards*shardSize))
+}
+
+func TestUnion_LowerK(t *testing.T) {
+	shards := newShards(t, 100, 1000, _DEFAULT_K)
+	var err error
+	shards[50], err = NewItemsSketch[int64](_DEFAULT_K/2, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	for j := 0; j < 1000; j++ {
+		shards[50].Update(int64(j*100 + 50))
+	}
+	union, err := NewUnion[int64](_DEFAULT_K, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	for _, shard := range shards {
+		assert.NoError(t, union.Update(shard))
+	}
+	result, err := union.GetResult()
+	assert.NoError(t, err)
+	assert.Equal(t, _DEFAULT_K, result.GetK())
+	assert.Equal(t, getNormalizedRankError(_DEFAULT_K/2, false), result.GetNormalizedRankError(false))
+	checkUniformResult(t, result, 100000)
+}
+
+func TestUnion_RandSeed(t *testing.T) {
+	shards := newShards(t, 300, 1000, _DEFAULT_K)
+	image := func(opts ...SketchOption) []byte {
+		union, err := NewUnion[int64](_DEFAULT_K, Int64ItemsSketchOp{}, opts...)
+		assert.NoError(t, err)
+		for _, shard := range shards {
+			assert.NoError(t, union.Update(shard))
+		}
+		result, err := union.GetResult()
+		assert.NoError(t, err)
+		sl, err := result.ToSlice()
+		assert.NoError(t, err)
+		return sl
+	}
+	// the tree of merges does not depend on the scheduling of the workers
+	assert.Equal(t, image(WithRandSeed(1), WithWorkers(1)), image(WithRandSeed(1), WithWorkers(8)))
+}
+
+func TestItemsSketch_MergeAll(t *testing.T) {
+	numShards, shardSize := 501, 200
+	shards := newShards(t, numShards, shardSize, _DEFAULT_K)
+	images := make([][]byte, numShards)
+	for i, shard := range shards {
+		sl, err := shard.ToSlice()
+		assert.NoError(t, err)
+		images[i] = sl
+	}
+	sketch, err := NewItemsSketch[int64](_DEFAULT_K, Int64ItemsSketchOp{})
+	assert.NoError(t, err)
+	assert.Error(t, sketch.MergeAll(shards[0], nil))
+	assert.True(t, sketch.IsEmpty())
+	assert.NoError(t, sketch.MergeAll())
+	assert.NoError(t, sketch.MergeAll(shards...))
+	checkUniformResult(t, sketch, int64(numShards*shardSize))
+
+	// the merged sketches are not modified
+	for i, shard := range shards {
+		sl, err := shard.ToSlice()
+		assert.NoError(t, err)
+		assert.Equal(t, images[i], sl)
+	}
+}
@@ -118,9 +118,10 @@ func characterPad(s string, fieldLength int, padChar byte, postpend bool) string
 	return s
 }
 
+// ubOnNumLevels returns the upper bound of the number of levels of a sketch of n items, 1 +
+// floor(log2(n)), computed on the unsigned n since weighted updates can take it beyond 2^63.
 func ubOnNumLevels(n uint64) int {
-	v := internal.FloorPowerOf2(int64(n))
-	return 1 + bits.TrailingZeros64(uint64(v))
+	return max(1, bits.Len64(n))
 }
 
 func getNumRetainedAboveLevelZero(numLevels uint8, levels []uint32) uint32 {
@@ -141,6 +142,17 @@ func getNormalizedRankError(k uint16, pmf bool) float64 {
 	return _CDF_COEF / math.Pow(float64(k), _CDF_EXP)
 }
 
+// getRankLowerBound returns the lower bound of the given rank at a 99% confidence, for the rank error
+// of a sketch of the given min K.
+func getRankLowerBound(rank float64, minK uint16) float64 {
+	return max(0, rank-getNormalizedRankError(minK, false))
+}
+
+// getRankUpperBound returns the upper bound of the given rank at a 99% confidence, see getRankLowerBound.
+func getRankUpperBound(rank float64, minK uint16) float64 {
+	return min(1, rank+getNormalizedRankError(minK, false))
+}
+
 func checkBounds(offset int, reqLen int, memCap int) bool {
 	return !((offset | reqLen | (offset + reqLen) | (memCap - (offset + reqLen))) < 0)
 }
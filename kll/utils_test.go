@@ -19,6 +19,7 @@ package kll
 
 import (
 	"github.com/stretchr/testify/assert"
+	"math"
 	"testing"
 )
 
@@ -28,4 +29,6 @@ func TestUbOnNumLevels(t *testing.T) {
 	assert.Equal(t, ubOnNumLevels(2), 2)
 	assert.Equal(t, ubOnNumLevels(10), 4)
 	assert.Equal(t, ubOnNumLevels(1000), 10)
+	assert.Equal(t, ubOnNumLevels(1<<63), 64)
+	assert.Equal(t, ubOnNumLevels(math.MaxUint64), 64)
 }
@@ -0,0 +1,46 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sketchinfo
+
+import (
+	"github.com/apache/datasketches-go/frequencies"
+)
+
+// frequencyFlagNames are the frequent items flags by bit position, both bits 0 and 2 mark an empty
+// sketch, see frequencies/preamble_utils.go.
+var frequencyFlagNames = []string{"EMPTY", "", "EMPTY"}
+
+func inspectFrequency(image []byte, info *Info) {
+	freqInfo, err := frequencies.InspectImage(image)
+	info.Err = err
+	info.PreambleBytes = freqInfo.PreambleBytes
+	info.Flags = freqInfo.Flags
+	info.FlagNames = flagNames(info.Flags, frequencyFlagNames)
+	info.addField("Lg Max Map Size", "%d", freqInfo.LgMaxMapSize)
+	info.addField("Lg Cur Map Size", "%d", freqInfo.LgCurMapSize)
+	if freqInfo.FullPreamble {
+		info.addField("Active Items", "%d", freqInfo.ActiveItems)
+		info.addField("Stream Weight", "%d", freqInfo.StreamWeight)
+		info.addField("Offset", "%d", freqInfo.Offset)
+	}
+	if err == nil && freqInfo.ActiveItems > 0 {
+		// a LongsSketch and an ItemsSketch write the same preamble, the serialization of the items
+		// depends on the sketch
+		info.addField("Item Bytes", "%d", len(image)-freqInfo.ItemsOffsetBytes)
+	}
+}
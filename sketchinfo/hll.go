@@ -0,0 +1,53 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sketchinfo
+
+import (
+	"github.com/apache/datasketches-go/hll"
+)
+
+// hllFlagNames are the HLL flags by bit position, see hll/preamble_utils.go.
+var hllFlagNames = []string{"BIG_ENDIAN", "READ_ONLY", "EMPTY", "COMPACT", "OUT_OF_ORDER", "REBUILD_KXQ"}
+
+func inspectHll(image []byte, info *Info) {
+	hllInfo, err := hll.InspectImage(image)
+	info.Err = err
+	info.PreambleBytes = hllInfo.PreambleBytes
+	info.Flags = hllInfo.Flags
+	info.FlagNames = flagNames(info.Flags, hllFlagNames)
+	info.addField("LgK", "%d", hllInfo.LgK)
+	info.addField("LgArr", "%d", hllInfo.LgArr)
+	info.addField("Cur Mode", "%s", hllInfo.CurMode)
+	info.addField("Tgt Hll Type", "%s", hllInfo.TgtHllType)
+	if !hllInfo.FullPreamble {
+		return
+	}
+	switch hllInfo.CurMode {
+	case hll.CurModeList:
+		info.addField("List Count", "%d", hllInfo.ListCount)
+	case hll.CurModeSet:
+		info.addField("Hash Set Count", "%d", hllInfo.HashSetCount)
+	case hll.CurModeHll:
+		info.addField("Cur Min", "%d", hllInfo.CurMin)
+		info.addField("Hip Accum", "%f", hllInfo.HipAccum)
+		info.addField("KxQ0", "%f", hllInfo.KxQ0)
+		info.addField("KxQ1", "%f", hllInfo.KxQ1)
+		info.addField("Num At Cur Min", "%d", hllInfo.NumAtCurMin)
+		info.addField("Aux Count", "%d", hllInfo.AuxCount)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sketchinfo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/datasketches-go/kll"
+)
+
+// kllFlagNames are the KLL flags by bit position, see kll/preamble_utils.go.
+var kllFlagNames = []string{"EMPTY", "LEVEL_ZERO_SORTED", "SINGLE_ITEM"}
+
+func inspectKll(image []byte, info *Info) {
+	kllInfo, err := kll.InspectImage(image)
+	info.Err = err
+	info.PreambleBytes = kllInfo.PreambleBytes
+	info.Flags = kllInfo.Flags
+	info.FlagNames = flagNames(info.Flags, kllFlagNames)
+	info.addField("K", "%d", kllInfo.K)
+	info.addField("M", "%d", kllInfo.M)
+	if kllInfo.Structure != "" {
+		info.addField("Structure", "%s", kllInfo.Structure)
+	}
+	if kllInfo.FullPreamble {
+		info.addField("N", "%d", kllInfo.N)
+		info.addField("Min K", "%d", kllInfo.MinK)
+		info.addField("Num Levels", "%d", kllInfo.NumLevels)
+		levels := make([]string, len(kllInfo.Levels))
+		for i, level := range kllInfo.Levels {
+			levels[i] = fmt.Sprint(level)
+		}
+		info.addField("Levels", "%s", strings.Join(levels, " "))
+	}
+	if err == nil && kllInfo.NumItems > 0 {
+		// the serialization of the items depends on the ItemSketchOp of the sketch
+		info.addField("Num Items", "%d", kllInfo.NumItems)
+		info.addField("Item Bytes", "%d", len(image)-kllInfo.ItemsOffsetBytes)
+	}
+}
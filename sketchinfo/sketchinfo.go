@@ -0,0 +1,121 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sketchinfo identifies serialized sketch images, as written by this library or by the
+// Java and C++ DataSketches libraries, and reports the content of their preamble.
+package sketchinfo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/datasketches-go/internal"
+)
+
+// Field is a named field decoded from the preamble of an image.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Info describes a serialized sketch image, see Inspect.
+type Info struct {
+	// Family is the name of the sketch family: HLL, FREQUENCY or KLL.
+	Family string
+	// FamilyID is the family ID of byte 2 of the preamble.
+	FamilyID int
+	// SerVer is the serial version of the image.
+	SerVer int
+	// PreambleBytes is the size in bytes of the preamble announced by its first byte.
+	PreambleBytes int
+	// Flags is the flags byte of the preamble, FlagNames lists the flags that are set.
+	Flags     int
+	FlagNames []string
+	// Fields are the family specific fields of the preamble, in their order in the image.
+	Fields []Field
+	// SizeBytes is the size of the inspected image.
+	SizeBytes int
+	// Err is nil if the image is valid, otherwise it explains why it cannot be deserialized.
+	Err error
+}
+
+// Valid returns true if the image passed the validity check of its family.
+func (i Info) Valid() bool {
+	return i.Err == nil
+}
+
+// String returns a human-readable listing of the information.
+func (i Info) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "  Family         : %s (%d)\n", i.Family, i.FamilyID)
+	fmt.Fprintf(&sb, "  Ser Ver        : %d\n", i.SerVer)
+	fmt.Fprintf(&sb, "  Preamble Bytes : %d\n", i.PreambleBytes)
+	fmt.Fprintf(&sb, "  Flags          : 0x%02x %s\n", i.Flags, strings.Join(i.FlagNames, "|"))
+	for _, f := range i.Fields {
+		fmt.Fprintf(&sb, "  %-15s: %s\n", f.Name, f.Value)
+	}
+	fmt.Fprintf(&sb, "  Size Bytes     : %d\n", i.SizeBytes)
+	if i.Err != nil {
+		fmt.Fprintf(&sb, "  Valid          : false, %v\n", i.Err)
+	} else {
+		sb.WriteString("  Valid          : true\n")
+	}
+	return sb.String()
+}
+
+// Inspect identifies the family of the given image from its family ID and decodes its preamble.
+// An error is returned only if the image is too small to hold a preamble or if its family is unknown,
+// otherwise the result of the validity check of the family is reported in Info.Err.
+func Inspect(image []byte) (Info, error) {
+	if len(image) < 8 {
+		return Info{}, fmt.Errorf("image too small to hold a preamble: %d bytes", len(image))
+	}
+	info := Info{
+		FamilyID:  int(image[2]),
+		SerVer:    int(image[1]),
+		SizeBytes: len(image),
+	}
+	switch info.FamilyID {
+	case internal.FamilyEnum.HLL.Id:
+		info.Family = "HLL"
+		inspectHll(image, &info)
+	case internal.FamilyEnum.Frequency.Id:
+		info.Family = "FREQUENCY"
+		inspectFrequency(image, &info)
+	case internal.FamilyEnum.Kll.Id:
+		info.Family = "KLL"
+		inspectKll(image, &info)
+	default:
+		return Info{}, fmt.Errorf("unknown family ID: %d", info.FamilyID)
+	}
+	return info, nil
+}
+
+// flagNames returns the names of the flags set, names being indexed by bit position.
+func flagNames(flags int, names []string) []string {
+	var set []string
+	for bit, name := range names {
+		if flags&(1<<bit) != 0 && name != "" {
+			set = append(set, name)
+		}
+	}
+	return set
+}
+
+func (i *Info) addField(name string, format string, args ...any) {
+	i.Fields = append(i.Fields, Field{Name: name, Value: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,166 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sketchinfo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/datasketches-go/frequencies"
+	"github.com/apache/datasketches-go/hll"
+	"github.com/apache/datasketches-go/internal"
+	"github.com/apache/datasketches-go/kll"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspectTestData(t *testing.T) {
+	for _, dir := range []string{internal.JavaPath, internal.CppPath} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.sk"))
+		assert.NoError(t, err)
+		for _, file := range files {
+			name := filepath.Base(file)
+			var family string
+			switch {
+			case strings.HasPrefix(name, "hll"):
+				family = "HLL"
+			case strings.HasPrefix(name, "frequent"):
+				family = "FREQUENCY"
+			case strings.HasPrefix(name, "kll"):
+				family = "KLL"
+			default:
+				continue
+			}
+			bytes, err := os.ReadFile(file)
+			assert.NoError(t, err)
+			info, err := Inspect(bytes)
+			assert.NoError(t, err, name)
+			assert.Equal(t, family, info.Family, name)
+			assert.True(t, info.Valid(), "%s: %v", name, info.Err)
+			assert.Equal(t, len(bytes), info.SizeBytes)
+		}
+	}
+}
+
+func TestInspectHll(t *testing.T) {
+	sketch, err := hll.NewHllSketch(12, hll.TgtHllTypeHll8)
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		assert.NoError(t, sketch.UpdateUInt64(uint64(i)))
+	}
+	bytes, err := sketch.ToCompactSlice()
+	assert.NoError(t, err)
+
+	info, err := Inspect(bytes)
+	assert.NoError(t, err)
+	assert.True(t, info.Valid())
+	assert.Equal(t, 7, info.FamilyID)
+	assert.Equal(t, 1, info.SerVer)
+	assert.Equal(t, 40, info.PreambleBytes)
+	assert.Equal(t, "HLL", info.Fields[2].Value)
+	assert.Equal(t, Field{Name: "LgK", Value: "12"}, info.Fields[0])
+	assert.Contains(t, info.String(), "Valid          : true")
+
+	// a truncated image keeps its family but fails the validity check
+	info, err = Inspect(bytes[:len(bytes)/2])
+	assert.NoError(t, err)
+	assert.Equal(t, "HLL", info.Family)
+	assert.False(t, info.Valid())
+}
+
+func TestInspectKll(t *testing.T) {
+	sketch, err := kll.NewDoublesSketch(200)
+	assert.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		sketch.Update(float64(i))
+	}
+	bytes, err := sketch.ToSlice()
+	assert.NoError(t, err)
+
+	info, err := Inspect(bytes)
+	assert.NoError(t, err)
+	assert.True(t, info.Valid(), "%v", info.Err)
+	assert.Equal(t, "KLL", info.Family)
+	assert.Equal(t, 20, info.PreambleBytes)
+	assert.Equal(t, Field{Name: "Structure", Value: "COMPACT_FULL"}, info.Fields[2])
+	assert.Equal(t, Field{Name: "N", Value: "1000"}, info.Fields[3])
+
+	// levels that do not add up to N fail the validity check of the kll package
+	bytes[8]++
+	info, err = Inspect(bytes)
+	assert.NoError(t, err)
+	assert.False(t, info.Valid())
+	assert.ErrorIs(t, info.Err, kll.ErrCorruptImage)
+	assert.Equal(t, Field{Name: "N", Value: "1001"}, info.Fields[3])
+}
+
+func TestInspectFrequency(t *testing.T) {
+	// an ItemsSketch of 4 byte strings has the image size of a LongsSketch, its items are not guessed
+	sketch, err := frequencies.NewItemsSketchWithMaxMapSize[string](64, frequencies.StringItemsSketchOp{})
+	assert.NoError(t, err)
+	for _, item := range []string{"abcd", "efgh", "ijkl"} {
+		assert.NoError(t, sketch.Update(item))
+	}
+	bytes := sketch.ToSlice()
+
+	info, err := Inspect(bytes)
+	assert.NoError(t, err)
+	assert.True(t, info.Valid(), "%v", info.Err)
+	assert.Equal(t, "FREQUENCY", info.Family)
+	assert.Equal(t, 32, info.PreambleBytes)
+	assert.Equal(t, Field{Name: "Active Items", Value: "3"}, info.Fields[2])
+	assert.Equal(t, Field{Name: "Item Bytes", Value: "24"}, info.Fields[5])
+	assert.NotContains(t, info.String(), "long")
+
+	// a count larger than the stream weight fails the validity check of the frequencies package
+	bytes[16] = 1
+	info, err = Inspect(bytes)
+	assert.NoError(t, err)
+	assert.False(t, info.Valid())
+	assert.Equal(t, Field{Name: "Stream Weight", Value: "1"}, info.Fields[3])
+}
+
+func TestInspectInvalid(t *testing.T) {
+	_, err := Inspect([]byte{1, 2, 3})
+	assert.Error(t, err)
+
+	_, err = Inspect([]byte{1, 1, 99, 0, 0, 0, 0, 0})
+	assert.Error(t, err)
+}
+
+func FuzzInspect(f *testing.F) {
+	for _, dir := range []string{internal.JavaPath, internal.CppPath} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.sk"))
+		assert.NoError(f, err)
+		for _, file := range files {
+			bytes, err := os.ReadFile(file)
+			assert.NoError(f, err)
+			if len(bytes) < 1<<12 {
+				f.Add(bytes)
+			}
+		}
+	}
+	f.Fuzz(func(t *testing.T, image []byte) {
+		// any image is reported without a panic
+		info, err := Inspect(image)
+		if err == nil {
+			_ = info.String()
+		}
+	})
+}